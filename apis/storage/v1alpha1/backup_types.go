@@ -17,8 +17,6 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"time"
-
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -33,15 +31,65 @@ type BackupSpec struct {
 
 	// The number of backup to be retained. Value must be non-negative interger.
 	// Default to 0, and means keep all backups.
+	// It's the same as `restic forget --keep-last`, and applied to the snapshots
+	// of every persistentvolumeclaim separately.
 	// +optional
 	Retention uint64 `json:"retention"`
 
+	// RetentionPolicy is the richer retention policy applied together with Retention,
+	// the snapshots matched any of the policies are kept.
+	// +optional
+	RetentionPolicy *RetentionPolicy `json:"retentionPolicy,omitempty"`
+
 	// BackupFrom specifies where the data should be backup from
 	// currently supported: pod, deployment, statefulset, daemonset,
 	// persistentvolume and persistentvolumeclaim.
 	// It's ignore case.
 	BackupFrom *BackupFrom `json:"backupFrom"`
 
+	// PVCFilter filters the persistentvolumeclaims mounted by the backup targets.
+	// +optional
+	PVCFilter *PVCFilter `json:"pvcFilter,omitempty"`
+
+	// Excludes are the restic exclude options applied to the persistentvolumeclaims
+	// matched, the files matched are not stored in the restic repository.
+	// +optional
+	Excludes []PathExclude `json:"excludes,omitempty"`
+
+	// Hooks are the commands executed in the pods to backup before and after
+	// backup, such as to flush and lock the database tables, which makes the
+	// backup application-consistent.
+	// +optional
+	Hooks *BackupHooks `json:"hooks,omitempty"`
+
+	// Dump backups the database running in the backup targets by the logical
+	// dump tool, such as mysqldump, instead of the persistentvolume data.
+	// The dump is streamed into `restic backup --stdin`.
+	// +optional
+	Dump *DatabaseDump `json:"dump,omitempty"`
+
+	// VolumeSnapshot backups the persistentvolumeclaims from the CSI VolumeSnapshot
+	// instead of the persistentvolume data directory in k8s node, see VolumeSnapshotOptions.
+	// +optional
+	VolumeSnapshot *VolumeSnapshotOptions `json:"volumeSnapshot,omitempty"`
+
+	// MaxConcurrency is the max number of persistentvolumeclaims backed up concurrently.
+	// Every persistentvolumeclaim is backed up to every storage by its own executor, and
+	// they are backed up concurrently, except that the backups to the pvc storage are
+	// one by one, and the backups from VolumeSnapshot of the same persistentvolumeclaim
+	// are one by one.
+	// Default to 1, which backup the persistentvolumeclaims one by one.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrency int32 `json:"maxConcurrency,omitempty"`
+
+	// Manifests backups the manifests of the backup target and its persistentvolumeclaims,
+	// persistentvolumes, configmaps, secrets, services and serviceaccount alongside
+	// the data, so the application can be recreated by Restore in an empty cluster.
+	// +optional
+	Manifests *ManifestOptions `json:"manifests,omitempty"`
+
 	// BackupTo specifies where the data shoud be backup to
 	// currently supported: cephfs, nfs, persistentVolumeClaim,
 	// S3, Minio, Server, RestServer
@@ -81,19 +129,247 @@ type BackupSpec struct {
 	LogFormat string `json:"logFormat"`
 
 	// The number of successful finished jobs to retain. Value must be non-negative integer.
-	// Defaults to 3.
+	// Defaults to 3, set to 0 to keep no successful jobs.
+	// +kubebuilder:validation:Minimum=0
 	// +optional
-	SuccessfulJobsHistoryLimit uint32 `json:"successfulJobsHistoryLimit"`
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
 	// The number of failed finished jobs to retain. Value must be non-negative integer.
-	// Defaults to 1.
+	// Defaults to 1, set to 0 to keep no failed jobs.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+}
+
+// RetentionPolicy defines which restic snapshots are kept, see
+// https://restic.readthedocs.io/en/stable/060_forget.html#removing-snapshots-according-to-a-policy
+type RetentionPolicy struct {
+	// KeepHourly keeps the last n hourly snapshots.
+	// +optional
+	KeepHourly uint32 `json:"keepHourly,omitempty"`
+	// KeepDaily keeps the last n daily snapshots.
+	// +optional
+	KeepDaily uint32 `json:"keepDaily,omitempty"`
+	// KeepWeekly keeps the last n weekly snapshots.
+	// +optional
+	KeepWeekly uint32 `json:"keepWeekly,omitempty"`
+	// KeepMonthly keeps the last n monthly snapshots.
+	// +optional
+	KeepMonthly uint32 `json:"keepMonthly,omitempty"`
+	// KeepYearly keeps the last n yearly snapshots.
+	// +optional
+	KeepYearly uint32 `json:"keepYearly,omitempty"`
+	// KeepWithin keeps the snapshots newer than the duration relative to the
+	// latest snapshot, such as "1y5m7d2h".
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithin string `json:"keepWithin,omitempty"`
+}
+
+// PVCFilter defines which persistentvolumeclaims to backup. Every item is a
+// persistentvolumeclaim name or the volume name in pod spec, shell pattern
+// such as "data-*" is supported.
+// The persistentvolumeclaim is backed up if it matches any of Include (or Include
+// is empty) and doesn't match any of Exclude.
+type PVCFilter struct {
+	// +optional
+	Include []string `json:"include,omitempty"`
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// PathExclude defines the files excluded when backup the persistentvolumeclaims, see
+// https://restic.readthedocs.io/en/stable/040_backup.html#excluding-files
+//
+// The pattern starts with "/" is relative to the root directory of the persistentvolume.
+type PathExclude struct {
+	// PVCs are the persistentvolumeclaim names or the volume names in pod spec
+	// the excludes applied to, shell pattern is supported. Default to all.
+	// +optional
+	PVCs []string `json:"pvcs,omitempty"`
+	// Exclude excludes the files matched the patterns.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+	// Iexclude is the same as Exclude but ignores the casing of filenames.
+	// +optional
+	Iexclude []string `json:"iexclude,omitempty"`
+	// ExcludeFile are the files in the persistentvolume which contains the
+	// exclude patterns, such as "/.resticignore".
+	// +optional
+	ExcludeFile []string `json:"excludeFile,omitempty"`
+	// ExcludeLargerThan excludes the files larger than the size, such as "512M", "1G".
+	// +kubebuilder:validation:Pattern=`^[0-9]+[kKmMgGtT]?$`
+	// +optional
+	ExcludeLargerThan string `json:"excludeLargerThan,omitempty"`
+}
+
+// BackupHooks defines the hooks executed in every pod mounts the persistentvolumeclaims
+// to backup. The pre backup hooks are executed before backup all persistentvolumeclaims
+// of the pod, and the post backup hooks are executed after backup, even if the backup failed.
+// The hooks defined by pod annotations "pre.hook.backup.hybfkuf.io/*" and
+// "post.hook.backup.hybfkuf.io/*" override them.
+type BackupHooks struct {
 	// +optional
-	FailedJobsHistoryLimit uint32 `json:"failedJobsHistoryLimit"`
+	PreBackup []BackupHook `json:"preBackup,omitempty"`
+	// +optional
+	PostBackup []BackupHook `json:"postBackup,omitempty"`
+}
+
+// BackupHook is a command executed in the container of the pod to backup.
+type BackupHook struct {
+	// Container is the container the command executed in, default to the first container.
+	// +optional
+	Container string `json:"container,omitempty"`
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+	// Timeout of the command, default to 30s. The command is executed by
+	// `timeout -s KILL` and killed when timeout. If the container has no `timeout`
+	// command, such as the distroless images, the command is executed directly
+	// and the backup stops waiting for it when timeout, but it's not killed.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// OnError is the policy when the command failed or timeout, Fail fails the backup
+	// and Continue ignores the error. Default to Fail.
+	// +optional
+	OnError HookErrorMode `json:"onError,omitempty"`
 }
 
+// HookErrorMode is the policy when the hook failed.
+// +kubebuilder:validation:Enum=Fail;Continue
+type HookErrorMode string
+
+const (
+	HookErrorModeFail     HookErrorMode = "Fail"
+	HookErrorModeContinue HookErrorMode = "Continue"
+)
+
+// DatabaseDump defines how to dump the database. The dump tool is executed in
+// the container of a running pod of every backup target, so the tool must exist
+// in the container image:
+//   mysql:      mysqldump, restored by mysql
+//   postgresql: pg_dump or pg_dumpall, restored by psql
+//   mongodb:    mongodump, restored by mongorestore, requires mongodb database tools
+//               100.3.0+, the password is passed by --config.
+//   redis:      redis-cli --rdb, requires redis 7.0+ and can't be restored by Restore.
+// If the credential has password, the container must have sh, which reads the
+// password from stdin, so the password is never in the exec arguments.
+type DatabaseDump struct {
+	Type DatabaseType `json:"type"`
+	// Container is the container the dump tool executed in, default to the first container.
+	// +optional
+	Container string `json:"container,omitempty"`
+	// CredentialName is the secret contains the key "username" and "password" used
+	// to connect to the database.
+	// +optional
+	CredentialName string `json:"credentialName,omitempty"`
+	// CredentialNamespace is the namespace of the credential secret, default to
+	// the namespace of the backup target.
+	// +optional
+	CredentialNamespace string `json:"credentialNamespace,omitempty"`
+	// Databases are the databases to dump, default to all databases.
+	// postgresql and mongodb only support dump one database or all databases.
+	// +optional
+	Databases []string `json:"databases,omitempty"`
+	// Args are the extra arguments passed to the dump tool.
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// DatabaseType is the type of the database to dump.
+// +kubebuilder:validation:Enum=mysql;postgresql;mongodb;redis
+type DatabaseType string
+
+const (
+	DatabaseMySQL      DatabaseType = "mysql"
+	DatabasePostgreSQL DatabaseType = "postgresql"
+	DatabaseMongoDB    DatabaseType = "mongodb"
+	DatabaseRedis      DatabaseType = "redis"
+)
+
+// VolumeSnapshotOptions defines how to backup the persistentvolumeclaim from the CSI VolumeSnapshot.
+// A VolumeSnapshot of the persistentvolumeclaim is taken and a temporary persistentvolumeclaim
+// is provisioned from it, the executor mounts the temporary persistentvolumeclaim read-only in
+// the namespace of the persistentvolumeclaim and backups it. All the temporary objects are
+// deleted after backup. It gives point-in-time consistency and doesn't require to mount the
+// k8s node root directory.
+// The persistentvolumeclaim in block volume mode is backed up as the file "<pvc>.img".
+type VolumeSnapshotOptions struct {
+	// VolumeSnapshotClassName is the VolumeSnapshotClass used to take the VolumeSnapshot,
+	// default to the default VolumeSnapshotClass of the CSI driver.
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// StorageClassName is the storageclass of the temporary persistentvolumeclaim,
+	// default to the storageclass of the persistentvolumeclaim to backup.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// ReadyTimeout is the max time to wait for the VolumeSnapshot ready to use, default to 10m.
+	// +optional
+	ReadyTimeout *metav1.Duration `json:"readyTimeout,omitempty"`
+}
+
+// ManifestOptions defines how to backup the manifests. The manifests are saved as the
+// file "manifests.yaml" in a restic snapshot with the same tags as the persistentvolumeclaim
+// snapshots, except the last tag is "manifests".
+type ManifestOptions struct {
+	// Secrets is the policy to backup the secrets referenced by the backup target.
+	// Exclude doesn't backup the secrets, Include backups the secrets as they are,
+	// and Encrypt backups the secrets with the data encrypted by AES-256-GCM.
+	// Default to Exclude.
+	// +optional
+	Secrets SecretPolicy `json:"secrets,omitempty"`
+	// EncryptionCredentialName is the secret in the same namespace as the horus-operator
+	// contains the key "MANIFEST_ENCRYPTION_KEY", which is the passphrase to encrypt
+	// the secrets. It's required if Secrets is Encrypt.
+	// +optional
+	EncryptionCredentialName string `json:"encryptionCredentialName,omitempty"`
+}
+
+// SecretPolicy is the policy to backup the secrets in manifests.
+// +kubebuilder:validation:Enum=Exclude;Include;Encrypt
+type SecretPolicy string
+
+const (
+	SecretPolicyExclude SecretPolicy = "Exclude"
+	SecretPolicyInclude SecretPolicy = "Include"
+	SecretPolicyEncrypt SecretPolicy = "Encrypt"
+)
+
 // BackupFrom defines where the data should backup from
+//
+// The targets to backup are the union of the resource specified by Name and Resource,
+// the resources in Targets, and the resources matched Selector in the namespaces
+// matched NamespaceSelector.
 type BackupFrom struct {
-	Name     string   `json:"name"`
+	// +optional
+	Name string `json:"name"`
+	// Resource is one of pod, deployment, statefulset, daemonset, persistentvolumeclaim
+	// and persistentvolume. The persistentvolumeclaim not mounted by any running pod
+	// is mounted temporarily when backup, and the persistentvolume not bound to any
+	// persistentvolumeclaim must be a local persistentvolume.
+	// +optional
 	Resource Resource `json:"resource"`
+	// Targets is the list of resources to backup.
+	// +optional
+	Targets []BackupTarget `json:"targets,omitempty"`
+	// Selector selects the deployments, statefulsets, daemonsets and pods to backup
+	// by labels, the pods owned by other resources are ignored.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// NamespaceSelector selects the namespaces where to find the resources matched Selector.
+	// Default to the namespace of Backup object. Only the Backup in the namespace of
+	// the horus-operator can select other namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// BackupTarget is a resource to backup.
+type BackupTarget struct {
+	// Namespace of the resource, default to the namespace of Backup object. Only the
+	// Backup in the namespace of the horus-operator can backup the resources in
+	// other namespaces.
+	// +optional
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Resource  Resource `json:"resource"`
 }
 
 type Resource string
@@ -153,6 +429,12 @@ type PVC struct {
 	//VolumeName string `json:"volumeName"`
 	//// AccessModes contains the desired access modes the volume should have.
 	//AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes"`
+
+	// The persistentvolumeclaim used as the restic repository, it's always in the
+	// namespace operator deployed. The persistentvolumeclaim will be created if
+	// not exist, otherwise the existing one is used and its spec is ignored.
+	// The ReadWriteMany persistentvolumeclaim is recommended, the ReadWriteOnce
+	// persistentvolumeclaim can only be mounted on one k8s node at the same time.
 	corev1.PersistentVolumeClaim `json:"persistentVolumeClaim"`
 }
 
@@ -171,31 +453,53 @@ type CephFS struct {
 	// Optional: SecretFile is the path to key ring for User, default is
 	// /etc/ceph/user.secret More info:
 	// https://examples.k8s.io/volumes/cephfs/README.md#how-to-use-it
+	// It's ignored, the key ring is always read from the secret.
 	// +optional
 	SecretFile string `json:"secretFile"`
 	// Optional: SecretRef is reference to the authentication secret for User,
 	// default is empty. More info:
 	// https://examples.k8s.io/volumes/cephfs/README.md#how-to-use-it
+	// The secret is in CredentialNamespace, it takes precedence over CredentialName.
 	// +optional
 	SecretRef string `json:"secretRef"`
 	// Optional: User is the rados user name, default is admin More info:
 	// https://examples.k8s.io/volumes/cephfs/README.md#how-to-use-it
 	// +optional
 	User string `json:"user"`
-	// secet.data should containe three field: user, keyring, clusterID
-	CredentialName      string `json:"credentialName"`
+	// secret.data should contain the field "key", which is the key ring of User,
+	// the output of "ceph auth get-key client.<user>".
+	// +optional
+	CredentialName string `json:"credentialName"`
+	// The namespace of the secret containing the key ring.
+	// Default to the namespace operator deployed.
+	// +optional
 	CredentialNamespace string `json:"credentialNamespace"`
 }
 
 type S3 struct {
+	// The endpoint of S3 compatible object storage, such as "s3.amazonaws.com"
+	// or "https://s3.example.com:9000", the scheme defaults to "https".
+	// Default to "s3.amazonaws.com".
+	// +optional
 	Endpoint string `json:"endpoint"`
-	Bucket   string `json:"bucket"`
-	Folder   string `json:"folder"`
-	// secret.data should contain two field: accessKey, secretKey
-	CredentialName        string `json:"credentialName"`
-	CredentialNamespace   string `json:"credentialNamespace"`
-	InsecureTLSSkipVerify bool   `json:"insecureTLSSkipVerify"`
-	Region                string `json:"region"`
+	// The name of bucket where the restic repository stored, it will be created if not exist.
+	Bucket string `json:"bucket"`
+	// The folder in the bucket where the restic repository stored.
+	// +optional
+	Folder string `json:"folder"`
+	// secret.data should contain two field: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+	// and an optional field AWS_SESSION_TOKEN.
+	CredentialName string `json:"credentialName"`
+	// The namespace of the secret containing the AWS credential.
+	// Default to the namespace operator deployed.
+	// +optional
+	CredentialNamespace string `json:"credentialNamespace"`
+	// Skip the TLS certificate verification of the endpoint.
+	// +optional
+	InsecureTLSSkipVerify bool `json:"insecureTLSSkipVerify"`
+	// The region where the bucket located, such as "us-east-1".
+	// +optional
+	Region string `json:"region"`
 }
 
 type MinIO struct {
@@ -221,12 +525,35 @@ type MinioEndpoint struct {
 }
 
 type RestServer struct {
+	// HTTP scheme use for connect to rest server, default to `http`.
+	// +kubebuilder:validation:Enum=http;https
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+	// rest server domain name or ip address.
 	Address string `json:"address"`
-	Port    uint32 `json:"port"`
-	Path    string `json:"path"`
-	// secret.data should contain two field: username, password
-	CredentialName      string `json:"credentialName"`
+	// rest server port, default to 8000.
+	// +optional
+	Port uint32 `json:"port"`
+	// The path of restic repository in rest server.
+	// +optional
+	Path string `json:"path"`
+	// secret.data should contain two field: RESTIC_REST_USERNAME, RESTIC_REST_PASSWORD,
+	// they're passed to restic by the environment variables, not percent-encoded.
+	// Leave it empty if the rest server started with --no-auth.
+	// +optional
+	CredentialName string `json:"credentialName"`
+	// The namespace of the secret containing the rest server credential.
+	// Default to the namespace operator deployed.
+	// +optional
 	CredentialNamespace string `json:"credentialNamespace"`
+	// AppendOnly should be true if the rest server started with --append-only,
+	// snapshots can't be removed from the repository, so Backup.spec.retention
+	// and Backup.spec.retentionPolicy are ignored.
+	// +optional
+	AppendOnly bool `json:"appendOnly,omitempty"`
+	// Skip the TLS certificate verification of the rest server.
+	// +optional
+	InsecureTLSSkipVerify bool `json:"insecureTLSSkipVerify,omitempty"`
 }
 
 type SFTP struct {
@@ -240,82 +567,153 @@ type SFTP struct {
 }
 
 type Rclone struct {
+	// The name of the remote defined in rclone config, such as "gdrive".
 	Address string `json:"address"`
-	Path    string `json:"path"`
+	// The path in the remote where the restic repository stored.
+	// +optional
+	Path string `json:"path"`
+	// secret.data should contain the field "rclone.conf", which is the rclone
+	// config contains the remote, see "rclone config file".
+	CredentialName string `json:"credentialName"`
+	// The namespace of the secret containing the rclone config.
+	// Default to the namespace operator deployed.
+	// +optional
+	CredentialNamespace string `json:"credentialNamespace"`
 }
 
 // BackupStatus defines the observed state of Backup
 type BackupStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-	Conditions         []BackupCondition `json:"conditions"`
-	LastBackupTime     time.Time         `json:"lastBackupTime"`
-	NextBackupTime     time.Time         `json:"nextBackupTime"`
-	ObservedGeneration uint64            `json:"observedGeneration"`
-	Storage            []string          `json:"storage"`
-	Message            string            `json:"message"`
-	Reason             string            `json:"reason"`
-	ResourceType       string            `json:"resourceType"`
-	ResourceName       string            `json:"resourceName"`
+	// Phase is the phase of the last backup.
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+	// Conditions records the schedule, the restic repository and the result of the last backup.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastBackupTime is the time the last backup started.
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+	// LastSuccessfulTime is the time the last successful backup finished.
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// NextBackupTime is the next time the backup scheduled.
+	// +optional
+	NextBackupTime *metav1.Time `json:"nextBackupTime,omitempty"`
+	// Duration is how long the last backup took.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// BytesAdded is the total bytes added to the restic repositories by the last backup.
+	// +optional
+	BytesAdded int64 `json:"bytesAdded,omitempty"`
+	// PVCs contains the result of every persistentvolumeclaim backed up to every storage by the last backup.
+	// +optional
+	PVCs []BackupPVCStatus `json:"pvcs,omitempty"`
+	// ObservedGeneration is the generation of the Backup object observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Storage is the storages the last backup backed up to.
+	// +optional
+	Storage []string `json:"storage,omitempty"`
+	// Human-readable message indicating details about the last backup.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Unique, one-word, CamelCase reason for the failure of the last backup.
+	// +optional
+	Reason       string `json:"reason,omitempty"`
+	ResourceType string `json:"resourceType,omitempty"`
+	ResourceName string `json:"resourceName,omitempty"`
+	// Targets contains the result of every target backed up by the last backup.
+	// +optional
+	Targets []BackupTargetStatus `json:"targets,omitempty"`
 }
 
-// BackupCondition contains details for the current condition of this pod.
-type BackupCondition struct {
-	// Type is the type of the condition.
-	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#pod-conditions
-	Type BackupConditionType `json:"type" protobuf:"bytes,1,opt,name=type,casttype=BackupConditionType"`
-	// Status is the status of the condition.
-	// Can be True, False, Unknown.
-	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#pod-conditions
-	Status ConditionStatus `json:"status" protobuf:"bytes,2,opt,name=status,casttype=ConditionStatus"`
-	// Last time we probed the condition.
+// BackupTargetStatus is the result of backup one target.
+type BackupTargetStatus struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Resource  Resource `json:"resource"`
+	// Phase is Succeeded or Failed.
+	Phase BackupPhase `json:"phase"`
+	// Snapshots is the number of restic snapshots taken for the target.
+	// +optional
+	Snapshots int `json:"snapshots,omitempty"`
+	// Error is the failure reason, empty if succeeded.
 	// +optional
-	LastProbeTime metav1.Time `json:"lastProbeTime,omitempty" protobuf:"bytes,3,opt,name=lastProbeTime"`
-	// Last time the condition transitioned from one status to another.
+	Error string `json:"error,omitempty"`
+}
+
+// BackupPVCStatus is the result of backup one persistentvolumeclaim to one storage.
+type BackupPVCStatus struct {
+	// Name is the persistentvolumeclaim name.
+	Name string `json:"name"`
+	// Namespace is the namespace of the target the persistentvolumeclaim belongs to.
 	// +optional
-	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty" protobuf:"bytes,4,opt,name=lastTransitionTime"`
-	// Unique, one-word, CamelCase reason for the condition's last transition.
+	Namespace string `json:"namespace,omitempty"`
+	// Target is the resource the persistentvolumeclaim belongs to, such as "deployment/nginx".
 	// +optional
-	Reason string `json:"reason,omitempty" protobuf:"bytes,5,opt,name=reason"`
-	// Human-readable message indicating details about last transition.
+	Target string `json:"target,omitempty"`
+	// Storage is the storage the persistentvolumeclaim backed up to.
+	Storage string `json:"storage"`
+	// Snapshot is the restic snapshot id.
 	// +optional
-	Message string `json:"message,omitempty" protobuf:"bytes,6,opt,name=message"`
+	Snapshot string `json:"snapshot,omitempty"`
+	// BytesAdded is the bytes added to the restic repository.
+	// +optional
+	BytesAdded int64 `json:"bytesAdded,omitempty"`
+	// FilesNew is the number of new files backed up.
+	// +optional
+	FilesNew int64 `json:"filesNew,omitempty"`
+	// FilesChanged is the number of changed files backed up.
+	// +optional
+	FilesChanged int64 `json:"filesChanged,omitempty"`
+	// Duration is how long the backup took.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Error is the failure reason, empty if succeeded.
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
-// BackupConditionType is a valid value for PodCondition.Type
-type BackupConditionType string
+// BackupPhase is a label for the condition of the last backup.
+type BackupPhase string
 
-// These are built-in conditions of pod. An application may use a custom condition not listed here.
 const (
-	// ContainersReady indicates whether all containers in the pod are ready.
-	ContainersReady BackupConditionType = "ContainersReady"
-	// PodInitialized means that all init containers in the pod have started successfully.
-	PodInitialized BackupConditionType = "Initialized"
-	// PodReady means the pod is able to service requests and should be added to the
-	// load balancing pools of all matching services.
-	PodReady BackupConditionType = "Ready"
-	// PodScheduled represents status of the scheduling process for this pod.
-	PodScheduled BackupConditionType = "PodScheduled"
-	// AlphaNoCompatGuaranteeDisruptionTarget indicates the pod is about to be deleted due to a
-	// disruption (such as preemption, eviction API or garbage-collection).
-	// The constant is to be renamed once the name is accepted within the KEP-3329.
-	AlphaNoCompatGuaranteeDisruptionTarget BackupConditionType = "DisruptionTarget"
+	// BackupScheduled means the cronjob has been created, but the backup has never run.
+	BackupScheduled BackupPhase = "Scheduled"
+	// BackupRunning means the backup is running.
+	BackupRunning BackupPhase = "Running"
+	// BackupSucceeded means the last backup succeeded.
+	BackupSucceeded BackupPhase = "Succeeded"
+	// BackupFailed means the last backup failed.
+	BackupFailed BackupPhase = "Failed"
 )
 
-type ConditionStatus string
+// BackupConditionType is a valid value for Backup condition type.
+type BackupConditionType string
 
-// These are valid condition statuses. "ConditionTrue" means a resource is in the condition.
-// "ConditionFalse" means a resource is not in the condition. "ConditionUnknown" means kubernetes
-// can't decide if a resource is in the condition or not. In the future, we could add other
-// intermediate conditions, e.g. ConditionDegraded.
+// These are the condition types of Backup.
 const (
-	ConditionTrue    ConditionStatus = "True"
-	ConditionFalse   ConditionStatus = "False"
-	ConditionUnknown ConditionStatus = "Unknown"
+	// BackupConditionScheduled indicates whether the cronjob for backup has been created.
+	BackupConditionScheduled BackupConditionType = "Scheduled"
+	// BackupConditionRunning indicates whether a backup is running.
+	BackupConditionRunning BackupConditionType = "Running"
+	// BackupConditionSucceeded indicates whether the last backup succeeded.
+	BackupConditionSucceeded BackupConditionType = "Succeeded"
+	// BackupConditionFailed indicates whether the last backup failed.
+	BackupConditionFailed BackupConditionType = "Failed"
+	// BackupConditionRepositoryReady indicates whether the restic repositories
+	// are accessible and initialized.
+	BackupConditionRepositoryReady BackupConditionType = "RepositoryReady"
 )
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Last Backup",type=date,JSONPath=`.status.lastBackupTime`
+//+kubebuilder:printcolumn:name="Next Backup",type=date,JSONPath=`.status.nextBackupTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Backup is the Schema for the backups API
 type Backup struct {
@@ -20,26 +20,136 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // CloneSpec defines the desired state of Clone
 type CloneSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// CloneFrom is the deployment or statefulset in the same namespace as the Clone
+	// object, all persistentvolumeclaims mounted by it will be cloned.
+	CloneFrom *BackupFrom `json:"cloneFrom"`
+
+	// CloneTo specifies where the persistentvolumeclaims cloned to.
+	CloneTo *CloneTo `json:"cloneTo"`
+
+	// Method is the way to copy the persistentvolumeclaim data, support "copy" and "restic",
+	// default to "copy".
+	// "copy" streams the data from the source persistentvolumeclaim to the new
+	// persistentvolumeclaim directly, the source workload keeps running during copy.
+	// "restic" takes a fresh restic snapshot by the Backup object and restores it
+	// to the new persistentvolumeclaim.
+	// +kubebuilder:validation:Enum=copy;restic
+	// +optional
+	Method CloneMethod `json:"method"`
+
+	// Backup is the name of Backup object in the same namespace as the Clone object,
+	// it's required if Method is "restic". Backup.spec.backupFrom must be the same as
+	// Clone.spec.cloneFrom.
+	// +optional
+	Backup string `json:"backup"`
+	// Storage specifies which storage of Backup.spec.backupTo to restore from,
+	// default to the first storage. It's only used when Method is "restic".
+	// +optional
+	Storage string `json:"storage"`
+
+	// Clone timeout
+	// +optional
+	Timeout metav1.Duration `json:"timeout"`
+
+	// TimeZone
+	// +optional
+	TimeZone string `json:"timezone"`
+
+	// Log level for clone pvc, support "info", "debug", default to "info".
+	// +optional
+	LogLevel string `json:"logLevel"`
+	// Log format for clone pvc, support "text", "json", default to "text".
+	// +optional
+	LogFormat string `json:"logFormat"`
+}
 
-	// Foo is an example field of Clone. Edit clone_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+// CloneTo defines where the persistentvolumeclaims cloned to.
+// The new persistentvolumeclaim name:
+//   - persistentvolumeclaim created from statefulset volumeClaimTemplates:
+//     "<volumeClaimTemplate name>-<Name>-<ordinal>", so the cloned statefulset uses it.
+//   - other persistentvolumeclaim: the same as the source persistentvolumeclaim if Namespace
+//     is different from the source namespace, otherwise "<Name>-<source pvc name>".
+type CloneTo struct {
+	// Namespace is the namespace cloned to, default to the namespace of the Clone object.
+	// The namespace will be created if not exist.
+	// +optional
+	Namespace string `json:"namespace"`
+	// Name is the name of the cloned deployment or statefulset, default to the CloneFrom.name.
+	// Either Namespace or Name must be different from the source.
+	// +optional
+	Name string `json:"name"`
+	// StorageClassName is the storageclass of the new persistentvolumeclaims,
+	// default to the storageclass of the source persistentvolumeclaims.
+	// +optional
+	StorageClassName string `json:"storageClassName"`
+	// CopyWorkload will copy the deployment or statefulset manifest to the Namespace
+	// with the Name, and the persistentvolumeclaim references rewritten to the new
+	// persistentvolumeclaims. The label app.kubernetes.io/instance of the copied one,
+	// its selector and pod template is set to the Name. The clone in the same namespace
+	// is rejected if the selector of the source selects the pods of the copied one.
+	// +optional
+	CopyWorkload bool `json:"copyWorkload"`
 }
 
+// CloneMethod is the way to copy the persistentvolumeclaim data.
+type CloneMethod string
+
+const (
+	// CloneMethodCopy streams the persistentvolumeclaim data by tar.
+	CloneMethodCopy CloneMethod = "copy"
+	// CloneMethodRestic takes a restic snapshot and restores it.
+	CloneMethodRestic CloneMethod = "restic"
+)
+
+// ClonePhase is a label for the condition of a Clone at the current time.
+type ClonePhase string
+
+const (
+	// ClonePending means the Clone has been accepted by the operator,
+	// but the clone job has not been started.
+	ClonePending ClonePhase = "Pending"
+	// CloneRunning means the clone job is running.
+	CloneRunning ClonePhase = "Running"
+	// CloneSucceeded means all persistentvolumeclaims have been cloned successfully.
+	CloneSucceeded ClonePhase = "Succeeded"
+	// CloneFailed means the clone has been terminated because of failure.
+	CloneFailed ClonePhase = "Failed"
+)
+
 // CloneStatus defines the observed state of Clone
 type CloneStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Phase is the current phase of the Clone.
+	// +optional
+	Phase ClonePhase `json:"phase,omitempty"`
+	// StartTime is the time the clone started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the clone finished, successfully or not.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// PVCs contains the source and the cloned persistentvolumeclaim pairs.
+	// +optional
+	PVCs []ClonedPVC `json:"pvcs,omitempty"`
+	// Human-readable message indicating details about the clone.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ClonedPVC is the clone result of one persistentvolumeclaim.
+type ClonedPVC struct {
+	// Source is the source persistentvolumeclaim name.
+	Source string `json:"source"`
+	// Target is the cloned persistentvolumeclaim name in CloneTo.namespace.
+	Target string `json:"target"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Method",type=string,JSONPath=`.spec.method`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Clone is the Schema for the clones API
 type Clone struct {
@@ -20,26 +20,139 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // MigrationSpec defines the desired state of Migration
 type MigrationSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// MigrateFrom is the deployment or statefulset in the same namespace as the Migration
+	// object, all persistentvolumeclaims mounted by it will be migrated.
+	MigrateFrom *BackupFrom `json:"migrateFrom"`
+
+	// MigrateTo specifies where the persistentvolumeclaims migrated to.
+	MigrateTo *MigrateTo `json:"migrateTo"`
+
+	// Backup is the name of Backup object in the same namespace as the Migration object,
+	// it takes the final backup after the workload scaled down, and the data is
+	// restored from it. Backup.spec.backupFrom must be the same as Migration.spec.migrateFrom.
+	// The persistentvolumeclaims are mounted by temporary pods to backup, so the
+	// Backup.spec.hooks are not executed, and the Backup with Backup.spec.dump is not supported.
+	Backup string `json:"backup"`
+	// Storage specifies which storage of Backup.spec.backupTo to restore from,
+	// default to the first storage.
+	// +optional
+	Storage string `json:"storage"`
+
+	// Migration timeout
+	// +optional
+	Timeout metav1.Duration `json:"timeout"`
+
+	// TimeZone
+	// +optional
+	TimeZone string `json:"timezone"`
+
+	// Log level for migrate pvc, support "info", "debug", default to "info".
+	// +optional
+	LogLevel string `json:"logLevel"`
+	// Log format for migrate pvc, support "text", "json", default to "text".
+	// +optional
+	LogFormat string `json:"logFormat"`
+}
 
-	// Foo is an example field of Migration. Edit migration_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+// MigrateTo defines where the persistentvolumeclaims migrated to.
+// The migrated persistentvolumeclaims keep the same name, so the deployment or
+// statefulset doesn't need to be changed. The volumeClaimTemplates of statefulset
+// is immutable, new replicas still create persistentvolumeclaims with the old storageclass.
+type MigrateTo struct {
+	// StorageClassName is the storageclass of the new persistentvolumes,
+	// default to the storageclass of the source persistentvolumeclaims.
+	// +optional
+	StorageClassName string `json:"storageClassName"`
+	// NodeName is the node the new persistentvolumes provisioned on, it only works
+	// for the storageclass with "WaitForFirstConsumer" volumeBindingMode, such as
+	// local-path and local volumes.
+	// +optional
+	NodeName string `json:"nodeName"`
 }
 
+// MigrationPhase is a label for the condition of a Migration at the current time.
+type MigrationPhase string
+
+const (
+	// MigrationPending means the Migration has been accepted by the operator,
+	// but the migration job has not been started.
+	MigrationPending MigrationPhase = "Pending"
+	// MigrationRunning means the migration job is running.
+	MigrationRunning MigrationPhase = "Running"
+	// MigrationSucceeded means all persistentvolumeclaims have been migrated successfully.
+	MigrationSucceeded MigrationPhase = "Succeeded"
+	// MigrationFailed means the migration has been terminated because of failure,
+	// the persistentvolumeclaims have been rolled back if the condition RolledBack is true.
+	MigrationFailed MigrationPhase = "Failed"
+)
+
+// These are the condition types of Migration, every step of the migration
+// is recorded as a condition.
+const (
+	// MigrationScaledDown means the deployment/statefulset has been scaled down to zero.
+	MigrationScaledDown = "ScaledDown"
+	// MigrationBackedUp means the final backup has been taken.
+	MigrationBackedUp = "BackedUp"
+	// MigrationProvisioned means the new persistentvolumeclaims have been created.
+	MigrationProvisioned = "Provisioned"
+	// MigrationRestored means the data has been restored to the new persistentvolumes.
+	MigrationRestored = "Restored"
+	// MigrationSwapped means the persistentvolumeclaims have been bound to the new persistentvolumes.
+	MigrationSwapped = "Swapped"
+	// MigrationScaledUp means the deployment/statefulset has been scaled up to the original replicas.
+	MigrationScaledUp = "ScaledUp"
+	// MigrationRolledBack means the persistentvolumeclaims have been bound back to
+	// the old persistentvolumes after failure.
+	MigrationRolledBack = "RolledBack"
+)
+
 // MigrationStatus defines the observed state of Migration
 type MigrationStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Phase is the current phase of the Migration.
+	// +optional
+	Phase MigrationPhase `json:"phase,omitempty"`
+	// StartTime is the time the migration started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the migration finished, successfully or not.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// PVCs contains the migration result of every persistentvolumeclaim.
+	// +optional
+	PVCs []MigratedPVC `json:"pvcs,omitempty"`
+	// Conditions records every step of the migration.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Human-readable message indicating details about the migration.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// MigratedPVC is the migration result of one persistentvolumeclaim.
+type MigratedPVC struct {
+	// Name is the persistentvolumeclaim name.
+	Name string `json:"name"`
+	// SourceVolume is the persistentvolume bound before migration, it's retained
+	// after migration and should be deleted manually once the data is verified.
+	// +optional
+	SourceVolume string `json:"sourceVolume,omitempty"`
+	// TargetVolume is the persistentvolume bound after migration.
+	// +optional
+	TargetVolume string `json:"targetVolume,omitempty"`
+	// Swapped indicates the persistentvolumeclaim has been bound to TargetVolume.
+	// +optional
+	Swapped bool `json:"swapped,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="StorageClass",type=string,JSONPath=`.spec.migrateTo.storageClassName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Migration is the Schema for the migrations API
 type Migration struct {
@@ -20,26 +20,147 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // RestoreSpec defines the desired state of Restore
 type RestoreSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// RestoreFrom specifies where the data should be restored from,
+	// a Backup object or a restic repository.
+	RestoreFrom *RestoreFrom `json:"restoreFrom"`
+
+	// RestoreTo specifies where the data should be restored to
+	// currently supported: deployment, statefulset and persistentvolumeclaim.
+	// The deployment or statefulset will be scaled down before restore
+	// and scaled up after restore.
+	RestoreTo *RestoreTo `json:"restoreTo"`
+
+	// Snapshot is the restic snapshot ID(or short ID) to restore from.
+	// Default to "latest", and means the latest snapshot matched the host and tags.
+	// +optional
+	Snapshot string `json:"snapshot"`
+
+	// Host filters the restic snapshots by hostname, the hostname is the cluster
+	// name of the Backup object. Default to Backup.spec.cluster.
+	// +optional
+	Host string `json:"host"`
+
+	// Tags filters the restic snapshots by tags.
+	// Default to the tags set by Backup: resource, namespace, name and pvc name.
+	// It's usually used together with a persistentvolumeclaim as the restore target.
+	// +optional
+	Tags []string `json:"tags"`
+
+	// Manifests recreates the k8s objects backed up by Backup.spec.manifests in the
+	// namespace of the Restore object before restore the data, so the application can
+	// be restored in an empty cluster. The existing objects are not changed.
+	// +optional
+	Manifests *RestoreManifests `json:"manifests,omitempty"`
+
+	// Restore timeout
+	// +optional
+	Timeout metav1.Duration `json:"timeout"`
+
+	// TimeZone
+	// +optional
+	TimeZone string `json:"timezone"`
+
+	// Log level for restore pvc, support "info", "debug", default to "info".
+	// +optional
+	LogLevel string `json:"logLevel"`
+	// Log format for restore pvc, support "text", "json", default to "text".
+	// +optional
+	LogFormat string `json:"logFormat"`
+}
+
+// RestoreManifests defines how to restore the manifests.
+type RestoreManifests struct {
+	// EncryptionCredentialName is the secret in the same namespace as the horus-operator
+	// contains the key "MANIFEST_ENCRYPTION_KEY" to decrypt the secrets.
+	// Default to Backup.spec.manifests.encryptionCredentialName.
+	// +optional
+	EncryptionCredentialName string `json:"encryptionCredentialName,omitempty"`
+}
+
+// RestoreFrom defines where the data should be restored from.
+// Either Backup or Repository must be specified.
+type RestoreFrom struct {
+	// Backup is the name of Backup object in the same namespace as the Restore object.
+	// The restic repository and credential will be the same as the Backup object.
+	// +optional
+	Backup string `json:"backup"`
+
+	// Storage specifies which storage of Backup.spec.backupTo to restore from,
+	// such as "nfs", "minio", "sftp". Default to the first storage.
+	// +optional
+	Storage string `json:"storage"`
+
+	// Repository specifies the restic repository to restore from directly
+	// if no Backup object is specified. Only one storage should be set.
+	// +optional
+	Repository *BackupTo `json:"repository"`
+
+	// CredentialName is a k8s secret name and must exist in the same namespace
+	// as the horus-operator. It's required if Repository is specified.
+	// +optional
+	CredentialName string `json:"credentialName"`
+}
 
-	// Foo is an example field of Restore. Edit restore_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+// RestoreTo defines where the data should be restored to.
+type RestoreTo struct {
+	Name     string   `json:"name"`
+	Resource Resource `json:"resource"`
 }
 
+// RestorePhase is a label for the condition of a Restore at the current time.
+type RestorePhase string
+
+const (
+	// RestorePending means the Restore has been accepted by the operator,
+	// but the restore job has not been started.
+	RestorePending RestorePhase = "Pending"
+	// RestoreRunning means the restore job is running.
+	RestoreRunning RestorePhase = "Running"
+	// RestoreSucceeded means all persistentvolumeclaims have been restored successfully.
+	RestoreSucceeded RestorePhase = "Succeeded"
+	// RestoreFailed means the restore has been terminated because of failure.
+	RestoreFailed RestorePhase = "Failed"
+)
+
 // RestoreStatus defines the observed state of Restore
 type RestoreStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Phase is the current phase of the Restore.
+	// +optional
+	Phase RestorePhase `json:"phase,omitempty"`
+	// StartTime is the time the restore started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the restore finished, successfully or not.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// RestoredBytes is the total size of the data restored to all persistentvolumeclaims.
+	// +optional
+	RestoredBytes int64 `json:"restoredBytes,omitempty"`
+	// PVCs contains the restore result of every persistentvolumeclaim.
+	// +optional
+	PVCs []RestoredPVC `json:"pvcs,omitempty"`
+	// Human-readable message indicating details about the restore.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RestoredPVC is the restore result of one persistentvolumeclaim.
+type RestoredPVC struct {
+	// Name is the persistentvolumeclaim name.
+	Name string `json:"name"`
+	// Snapshot is the restic snapshot ID the persistentvolumeclaim restored from.
+	Snapshot string `json:"snapshot"`
+	// RestoredBytes is the size of the data restored to the persistentvolumeclaim.
+	RestoredBytes int64 `json:"restoredBytes"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Restored",type=integer,JSONPath=`.status.restoredBytes`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Restore is the Schema for the restores API
 type Restore struct {
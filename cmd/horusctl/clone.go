@@ -0,0 +1,40 @@
+package horusctl
+
+import (
+	"os"
+
+	"github.com/forbearing/horus-operator/pkg/clone"
+	"github.com/forbearing/horus-operator/pkg/logger"
+	"github.com/forbearing/k8s/util/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloneCmd = &cobra.Command{
+		Use:   "clone",
+		Short: "clone k8s resource",
+		Long:  "clone the persistentvolumeclaims of k8s deployment/statefulset to the new persistentvolumeclaims",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			builder.SetLogLevel(logLevel)
+			builder.SetLogFormat(logFormat)
+			logger.Init()
+
+			// exit with non-zero code if clone failed, so the Clone controller
+			// knows the job is failed.
+			var failed bool
+			for _, cloneObj := range args {
+				if err := clone.Do(signals.NewSignalContext(), namespace, cloneObj); err != nil {
+					failed = true
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+}
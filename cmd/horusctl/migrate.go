@@ -0,0 +1,40 @@
+package horusctl
+
+import (
+	"os"
+
+	"github.com/forbearing/horus-operator/pkg/logger"
+	"github.com/forbearing/horus-operator/pkg/migration"
+	"github.com/forbearing/k8s/util/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "migrate k8s resource",
+		Long:  "migrate the persistentvolumeclaims of k8s deployment/statefulset to the new storageclass or node",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			builder.SetLogLevel(logLevel)
+			builder.SetLogFormat(logFormat)
+			logger.Init()
+
+			// exit with non-zero code if migration failed, so the Migration controller
+			// knows the job is failed.
+			var failed bool
+			for _, migrationObj := range args {
+				if err := migration.Do(signals.NewSignalContext(), namespace, migrationObj); err != nil {
+					failed = true
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
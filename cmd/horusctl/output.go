@@ -0,0 +1,55 @@
+package horusctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormats are the output formats supported by flag --output.
+var outputFormats = []string{outputTable, outputWide, outputJSON, outputYAML}
+
+// validateOutput returns error if the output format is not supported.
+func validateOutput(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("not support output format: %s, must be one of %s", format, strings.Join(outputFormats, "|"))
+}
+
+// printObject writes the object in the output format. In format "table" and "wide",
+// the object is written as a table by printTable, wide is true for format "wide".
+func printObject(w io.Writer, obj interface{}, printTable func(tw io.Writer, wide bool)) error {
+	switch output {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(obj)
+	case outputYAML:
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case outputTable, outputWide:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		printTable(tw, output == outputWide)
+		return tw.Flush()
+	default:
+		return validateOutput(output)
+	}
+}
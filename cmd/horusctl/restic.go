@@ -1,8 +1,21 @@
 package horusctl
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+
+	"github.com/forbearing/horus-operator/pkg/backup"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
 
 var (
+	storage    string
+	cluster    []string
+	tags       []string
+	backupName string
+
 	resticCmd = &cobra.Command{
 		Use:   "restic",
 		Short: "restic command",
@@ -11,5 +24,32 @@ var (
 )
 
 func init() {
+	resticCmd.PersistentFlags().StringVarP(&storage, "storage", "s", "", "storage type, it's optional if the Backup specified by --backup backs up to only one storage")
+	resticCmd.PersistentFlags().StringSliceVarP(&cluster, "cluster", "c", []string{}, "filte restic snapshots by kubernetes cluster name, separated by comma")
+	resticCmd.PersistentFlags().StringSliceVarP(&tags, "tags", "t", []string{}, "filter restic snapshots by tag name, separated by comma")
+	resticCmd.PersistentFlags().StringVarP(&backupName, "backup", "b", "", "the Backup in the namespace, the restic repository and credentials are derived from it")
 	rootCmd.AddCommand(resticCmd)
 }
+
+// withExecPod calls fn with the pod to execute restic commands within.
+// If --backup is specified, a short-lived executor pod is created from the Backup
+// object and deleted after fn returned, otherwise a running executor pod of the
+// storage is used, which only exists while the backup is running.
+func withExecPod(fn func(execPod *corev1.Pod) error) error {
+	if len(backupName) != 0 {
+		execPod, cleanup, err := backup.LookupExecutor(namespace, backupName, types.Storage(storage))
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		return fn(execPod)
+	}
+	if len(storage) == 0 {
+		return fmt.Errorf("--storage or --backup is required")
+	}
+	execPod, err := restic.FindExecPod(types.Storage(storage))
+	if err != nil {
+		return fmt.Errorf("%s, specify the Backup by --backup to create the executor", err.Error())
+	}
+	return fn(execPod)
+}
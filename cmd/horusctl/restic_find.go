@@ -0,0 +1,65 @@
+package horusctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/forbearing/horus-operator/pkg/logger"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/k8s/util/signals"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	findCmd = &cobra.Command{
+		Use:   "find <pattern...>",
+		Short: "Find files in snapshots",
+		Long:  "Find the files and directories matched the patterns in all snapshots filtered by cluster and tags",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			builder.SetLogLevel(logLevel)
+			builder.SetLogFormat(logFormat)
+			logger.Init()
+
+			ctx := signals.NewSignalContext()
+			if err := withExecPod(func(execPod *corev1.Pod) error {
+				finds, err := restic.Find(ctx, execPod, cluster, tags, args...)
+				if err != nil {
+					return err
+				}
+				return printFind(os.Stdout, finds)
+			}); err != nil {
+				logrus.Fatal(err)
+			}
+		},
+	}
+)
+
+func init() {
+	resticCmd.AddCommand(findCmd)
+}
+
+// printFind writes the matched files and directories of every snapshot in the output format.
+func printFind(w io.Writer, finds []restic.NodeFind) error {
+	return printObject(w, finds, func(tw io.Writer, wide bool) {
+		if wide {
+			fmt.Fprintln(tw, "SNAPSHOT\tTYPE\tPERMISSIONS\tUSER\tGROUP\tSIZE\tMODIFIED\tCHANGED\tPATH")
+		} else {
+			fmt.Fprintln(tw, "SNAPSHOT\tTYPE\tPERMISSIONS\tSIZE\tMODIFIED\tPATH")
+		}
+		for _, find := range finds {
+			for _, match := range find.Matches {
+				if wide {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", find.Snapshot, match.Type, match.Permissions, match.User, match.Group,
+						match.Size, match.Mtime.Format(time.RFC3339), match.Ctime.Format(time.RFC3339), match.Path)
+					continue
+				}
+				fmt.Fprintf(tw, "%.8s\t%s\t%s\t%d\t%s\t%s\n", find.Snapshot, match.Type, match.Permissions, match.Size, match.Mtime.Format(time.RFC3339), match.Path)
+			}
+		}
+	})
+}
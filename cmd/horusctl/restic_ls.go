@@ -0,0 +1,66 @@
+package horusctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/forbearing/horus-operator/pkg/logger"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/k8s/util/signals"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	lsCmd = &cobra.Command{
+		Use:   "ls <snapshot> [path...]",
+		Short: "List files in a snapshot",
+		Long:  "List files and directories in a snapshot, the snapshot can be \"latest\" filtered by cluster and tags",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			builder.SetLogLevel(logLevel)
+			builder.SetLogFormat(logFormat)
+			logger.Init()
+
+			ctx := signals.NewSignalContext()
+			if err := withExecPod(func(execPod *corev1.Pod) error {
+				nodes, err := restic.Ls(ctx, execPod, cluster, tags, args[0], args[1:]...)
+				if err != nil {
+					return err
+				}
+				return printLs(os.Stdout, nodes)
+			}); err != nil {
+				logrus.Fatal(err)
+			}
+		},
+	}
+)
+
+func init() {
+	resticCmd.AddCommand(lsCmd)
+}
+
+// printLs writes the files and directories in the snapshot in the output format.
+func printLs(w io.Writer, nodes []restic.NodeLs) error {
+	return printObject(w, nodes, func(tw io.Writer, wide bool) {
+		if wide {
+			fmt.Fprintln(tw, "TYPE\tPERMISSIONS\tUID\tGID\tSIZE\tMODIFIED\tCHANGED\tPATH")
+		} else {
+			fmt.Fprintln(tw, "TYPE\tPERMISSIONS\tSIZE\tMODIFIED\tPATH")
+		}
+		for _, node := range nodes {
+			if node.StructType != "node" {
+				continue
+			}
+			if wide {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", node.Type, node.Permissions, node.UID, node.GID, node.Size,
+					node.ModTime.Format(time.RFC3339), node.ChangeTime.Format(time.RFC3339), node.Path)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", node.Type, node.Permissions, node.Size, node.ModTime.Format(time.RFC3339), node.Path)
+		}
+	})
+}
@@ -1,20 +1,21 @@
 package horusctl
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/forbearing/horus-operator/pkg/logger"
 	"github.com/forbearing/horus-operator/pkg/restic"
-	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/k8s/util/signals"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var (
-	storage string
-	cluster []string
-	tags    []string
-
 	snapshotsCmd = &cobra.Command{
 		Use:   "snapshots",
 		Short: "List all snapshots",
@@ -24,15 +25,42 @@ var (
 			builder.SetLogFormat(logFormat)
 			logger.Init()
 
-			restic.Snapshots(signals.NewSignalContext(), types.Storage(storage), cluster, tags, os.Stdout, false)
+			ctx := signals.NewSignalContext()
+			if err := withExecPod(func(execPod *corev1.Pod) error {
+				snapshots, err := restic.Snapshots(ctx, execPod, cluster, tags)
+				if err != nil {
+					return err
+				}
+				return printSnapshots(os.Stdout, snapshots)
+			}); err != nil {
+				logrus.Fatal(err)
+			}
 		},
 	}
 )
 
 func init() {
-	snapshotsCmd.Flags().StringVarP(&storage, "storage", "s", "", "storage type")
-	snapshotsCmd.Flags().StringSliceVarP(&cluster, "cluster", "c", []string{}, "filte restic snapshots by kubernetes cluster name, separated by comma")
-	snapshotsCmd.Flags().StringSliceVarP(&tags, "tags", "t", []string{}, "filter restic snapshots by tag name, separated by comma")
-	snapshotsCmd.MarkFlagRequired("storage")
 	resticCmd.AddCommand(snapshotsCmd)
 }
+
+// printSnapshots writes the snapshots in the output format, the backup target of
+// every snapshot is decoded from its tags in format "table" and "wide".
+func printSnapshots(w io.Writer, snapshots []restic.NodeSnapshot) error {
+	return printObject(w, snapshots, func(tw io.Writer, wide bool) {
+		if wide {
+			fmt.Fprintln(tw, "ID\tTIME\tCLUSTER\tNAMESPACE\tRESOURCE\tNAME\tPVC\tPATHS\tTAGS")
+		} else {
+			fmt.Fprintln(tw, "ID\tTIME\tCLUSTER\tNAMESPACE\tRESOURCE\tNAME\tPVC")
+		}
+		for _, snapshot := range snapshots {
+			target := snapshot.Target()
+			if wide {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", snapshot.ID, snapshot.Time.Format(time.RFC3339), snapshot.Hostname,
+					target.Namespace, target.Resource, target.Name, target.PVC, strings.Join(snapshot.Paths, ","), strings.Join(snapshot.Tags, ","))
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", snapshot.ShortID, snapshot.Time.Format(time.RFC3339), snapshot.Hostname,
+				target.Namespace, target.Resource, target.Name, target.PVC)
+		}
+	})
+}
@@ -1 +1,74 @@
 package horusctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/forbearing/horus-operator/pkg/logger"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/k8s/util/signals"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	statsMode string
+
+	statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show repository statistics",
+		Long:  "Show the statistics of the snapshots stored in the repository",
+		Run: func(cmd *cobra.Command, args []string) {
+			builder.SetLogLevel(logLevel)
+			builder.SetLogFormat(logFormat)
+			logger.Init()
+
+			ctx := signals.NewSignalContext()
+			if err := withExecPod(func(execPod *corev1.Pod) error {
+				stat, err := restic.Stats(ctx, execPod, cluster, tags, statsMode)
+				if err != nil {
+					return err
+				}
+				return printStats(os.Stdout, stat)
+			}); err != nil {
+				logrus.Fatal(err)
+			}
+		},
+	}
+)
+
+func init() {
+	statsCmd.Flags().StringVar(&statsMode, "mode", restic.StatsModes[0], fmt.Sprintf("counting mode, (%s)", strings.Join(restic.StatsModes, ", ")))
+	resticCmd.AddCommand(statsCmd)
+}
+
+// printStats writes the restic statistics in the output format.
+func printStats(w io.Writer, stat *restic.NodeStat) error {
+	return printObject(w, stat, func(tw io.Writer, wide bool) {
+		fmt.Fprintf(tw, "MODE\t%s\n", statsMode)
+		fmt.Fprintf(tw, "SNAPSHOTS\t%d\n", stat.SnapshotsCount)
+		if stat.TotalFileCount != 0 {
+			fmt.Fprintf(tw, "TOTAL FILES\t%d\n", stat.TotalFileCount)
+		}
+		if stat.TotalBlobCount != 0 {
+			fmt.Fprintf(tw, "TOTAL BLOBS\t%d\n", stat.TotalBlobCount)
+		}
+		if wide {
+			fmt.Fprintf(tw, "TOTAL SIZE\t%d\n", stat.TotalSize)
+		} else {
+			fmt.Fprintf(tw, "TOTAL SIZE\t%s\n", resource.NewQuantity(stat.TotalSize, resource.BinarySI))
+		}
+		if stat.TotalUncompressedSize != 0 {
+			if wide {
+				fmt.Fprintf(tw, "UNCOMPRESSED SIZE\t%d\n", stat.TotalUncompressedSize)
+			} else {
+				fmt.Fprintf(tw, "UNCOMPRESSED SIZE\t%s\n", resource.NewQuantity(stat.TotalUncompressedSize, resource.BinarySI))
+			}
+			fmt.Fprintf(tw, "COMPRESSION RATIO\t%.2fx\n", stat.CompressionRatio)
+		}
+	})
+}
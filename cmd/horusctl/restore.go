@@ -0,0 +1,40 @@
+package horusctl
+
+import (
+	"os"
+
+	"github.com/forbearing/horus-operator/pkg/logger"
+	"github.com/forbearing/horus-operator/pkg/restore"
+	"github.com/forbearing/k8s/util/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreCmd = &cobra.Command{
+		Use:   "restore",
+		Short: "restore k8s resource",
+		Long:  "restore k8s deployment/statefulset/persistentvolumeclaim from the restic repository",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			builder.SetLogLevel(logLevel)
+			builder.SetLogFormat(logFormat)
+			logger.Init()
+
+			// exit with non-zero code if restore failed, so the Restore controller
+			// knows the job is failed.
+			var failed bool
+			for _, restoreObj := range args {
+				if err := restore.Do(signals.NewSignalContext(), namespace, restoreObj); err != nil {
+					failed = true
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -1,6 +1,9 @@
 package horusctl
 
 import (
+	"fmt"
+	"strings"
+
 	pkgargs "github.com/forbearing/horus-operator/pkg/args"
 	"github.com/forbearing/horus-operator/pkg/logger"
 	"github.com/forbearing/horus-operator/pkg/types"
@@ -12,6 +15,8 @@ var (
 	logLevel  string
 	logFormat string
 	namespace string
+	// output is the output format of the commands print objects, such as `horusctl restic snapshots`.
+	output string
 )
 
 var rootCmd = &cobra.Command{
@@ -23,12 +28,16 @@ var rootCmd = &cobra.Command{
 		builder.SetLogFormat(logFormat)
 		logger.Init()
 	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateOutput(output)
+	},
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "set log level, ('info' or 'debug')")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log encoding ('text' or 'json')")
 	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", types.DefaultNamespace, "the namespace of 'Backup|Restore|Clone|Migration|Traffic' CustomResource")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", outputTable, fmt.Sprintf("output format, (%s)", strings.Join(outputFormats, "|")))
 }
 
 func Execute() {
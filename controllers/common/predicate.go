@@ -3,6 +3,8 @@ package common
 import (
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/k8s/util/labels"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
@@ -84,3 +86,33 @@ func ClusterRoleBindingPredicate() predicate.Predicate {
 		//DeleteFunc: func(e event.DeleteEvent) bool { return !e.DeleteStateUnknown },
 	}
 }
+
+// JobPredicate
+// Backup object doesn't own the jobs created by the cronjob, the job is watched
+// only when it turns to failed, so the Backup object killed by the job deadline
+// can be marked as "Failed".
+func JobPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldJob, ok := e.ObjectOld.(*batchv1.Job)
+			if !ok {
+				return false
+			}
+			newJob, ok := e.ObjectNew.(*batchv1.Job)
+			if !ok {
+				return false
+			}
+			return !jobFailed(oldJob) && jobFailed(newJob)
+		},
+	}
+}
+
+// jobFailed returns true if the job has the "Failed" condition.
+func jobFailed(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
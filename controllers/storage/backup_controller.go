@@ -18,7 +18,6 @@ package storage
 
 import (
 	"context"
-	"fmt"
 	"os"
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
@@ -26,11 +25,9 @@ import (
 	"github.com/forbearing/horus-operator/pkg/template"
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/horus-operator/pkg/util"
-	"github.com/forbearing/k8s/clusterrolebinding"
 	"github.com/forbearing/k8s/cronjob"
 	"github.com/forbearing/k8s/namespace"
 	"github.com/go-logr/logr"
-	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -42,7 +39,9 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 /*
@@ -115,88 +114,16 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	}
 
 	// =========================
-	// reconcile ServiceAccount
+	// reconcile ServiceAccount, ClusterRole, ClusterRoleBinding and RoleBindings
+	// NOTE: Backup object as namespace-scoped resource doesn't have ability to control/own ClusterRole resource,
+	// and they are shared with the Restore, Clone and Migration objects in the same namespace.
+	// They are deleted by the finalizer, don't recreate them when the Backup object is being deleted.
 	// =========================
-	// Construct a serviceaccount object.
-	serviceAccount := r.serviceAccountForBackup(backupObj)
-	//r.withNamespace(ctx, serviceAccount, types.DefaultBackupJobNamespace)
-	//namespacedName := apitypes.NamespacedName{Namespace: types.DefaultBackupJobNamespace, Name: types.DefaultServiceAccountName}
-	namespacedName := apitypes.NamespacedName{Namespace: req.NamespacedName.Namespace, Name: "horusctl"}
-	// get the serviceaccount resource.
-	if err := r.Get(ctx, namespacedName, &corev1.ServiceAccount{}); err != nil {
-		if apierrors.IsNotFound(err) {
-			if err := r.Create(ctx, serviceAccount); err != nil {
-				logger.Error(err, "create serviceaccount failed")
-				return ctrl.Result{}, err
-			}
-			logger.Info("Successfully create serviceaccount/" + serviceAccount.GetName())
-			return ctrl.Result{Requeue: true}, nil
-		} else {
-			logger.Error(err, "get serviceaccount failed")
+	if backupObj.GetDeletionTimestamp().IsZero() {
+		if err := ensureHorusctlRBAC(ctx, r.Client, backupObj); err != nil {
+			logger.Error(err, "ensure horusctl rbac failed")
 			return ctrl.Result{}, err
 		}
-	} else {
-		if r.Update(ctx, serviceAccount); err != nil {
-			logger.Error(err, "update serviceaccount failed")
-			return ctrl.Result{}, err
-		}
-		//logger.Info("Successfully update serviceaccount/" + serviceAccount.GetName())
-	}
-
-	// =========================
-	// reconcile ClusterRole
-	// NOTE: Backup object as namespace-scoped resource doesn't have ability to control/own ClusterRole resource.
-	// =========================
-	// Construct a clusterrole object.
-	clusterRole := r.clusterRoleForBackup(backupObj)
-	namespacedName = apitypes.NamespacedName{Namespace: req.NamespacedName.Namespace, Name: "horusctl-role"}
-	// get the clusterrole resource.
-	if err := r.Get(ctx, namespacedName, &rbacv1.ClusterRole{}); err != nil {
-		if apierrors.IsNotFound(err) {
-			if err := r.Create(ctx, clusterRole); err != nil {
-				logger.Error(err, "create clusterrole failed")
-				return ctrl.Result{}, err
-			}
-			logger.Info("Successfully create clusterrole/" + clusterRole.GetName())
-			return ctrl.Result{Requeue: true}, nil
-		} else {
-			logger.Error(err, "get clusterrole failed")
-			return ctrl.Result{}, err
-		}
-	} else {
-		if r.Update(ctx, clusterRole); err != nil {
-			logger.Error(err, "update clusterrole failed")
-			return ctrl.Result{}, err
-		}
-		//logger.Info("Successfully update clusterrole/" + clusterRole.GetName())
-	}
-
-	// =========================
-	// reconcile ClusterRoleBinding
-	// NOTE: Backup object as namespace-scoped resource doesn't have ability to control/own ClusterRoleBinding resource.
-	// =========================
-	// Construct a clusterrolebinding object.
-	clusterRoleBinding := r.clusterRoleBindingForBackup(backupObj)
-	namespacedName = apitypes.NamespacedName{Namespace: req.NamespacedName.Namespace, Name: fmt.Sprintf("horusctl-%s-binding", req.NamespacedName.Namespace)}
-	// get the clusterrolebinding resource.
-	if err := r.Get(ctx, namespacedName, &rbacv1.ClusterRoleBinding{}); err != nil {
-		if apierrors.IsNotFound(err) {
-			if err := r.Create(ctx, clusterRoleBinding); err != nil {
-				logger.Error(err, "create clusterrolebinding failed")
-				return ctrl.Result{}, err
-			}
-			logger.Info("Successfully create clusterrolebinding/" + clusterRoleBinding.GetName())
-			return ctrl.Result{Requeue: true}, nil
-		} else {
-			logger.Error(err, "get clusterrolebinding failed")
-			return ctrl.Result{}, err
-		}
-	} else {
-		if r.Update(ctx, clusterRoleBinding); err != nil {
-			logger.Error(err, "update clusterrolebinding failed")
-			return ctrl.Result{}, err
-		}
-		//logger.Info("Successfully update clusterrolebinding/" + clusterRoleBinding.GetName())
 	}
 
 	// =========================
@@ -206,9 +133,10 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	cronJob := r.cronJobForBackup(ctx, backupObj)
 	//r.withNamespace(ctx, cronJob, types.DefaultBackupJobNamespace)
 	//namespacedName = apitypes.NamespacedName{Namespace: types.DefaultBackupJobNamespace, Name: "backup" + "-" + req.NamespacedName.Name}
-	namespacedName = apitypes.NamespacedName{Namespace: req.NamespacedName.Namespace, Name: "backup" + "-" + req.NamespacedName.Name}
+	namespacedName := apitypes.NamespacedName{Namespace: req.NamespacedName.Namespace, Name: "backup" + "-" + req.NamespacedName.Name}
 	// get the cronjob resource.
-	if err := r.Get(ctx, namespacedName, &batchv1.CronJob{}); err != nil {
+	existingCronJob := &batchv1.CronJob{}
+	if err := r.Get(ctx, namespacedName, existingCronJob); err != nil {
 		// if cronjob resource not exits, create it.
 		if apierrors.IsNotFound(err) {
 			if err := r.Create(ctx, cronJob); err != nil {
@@ -225,6 +153,8 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 	} else {
 		// if cronjob resource already exist, update it.
+		// Update requires the resourceVersion of the existing cronjob.
+		cronJob.SetResourceVersion(existingCronJob.GetResourceVersion())
 		if err := r.Update(ctx, cronJob); err != nil {
 			logger.Error(err, "update cronjob failed")
 			return ctrl.Result{}, err
@@ -236,11 +166,21 @@ func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	// otherwise ClusterRoleBinding resources will be recreated.
 	//
 	// Add finalizers when add Backup object
-	// Delete finalizers and delete external clusterrolebinding when delete Backup object.
+	// Delete finalizers and delete external clusterrolebinding and rolebindings when delete Backup object.
 	if err := r.handleFinalizer(ctx, backupObj); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// =========================
+	// reconcile Backup status
+	// =========================
+	if backupObj.GetDeletionTimestamp().IsZero() {
+		if err := r.updateBackupStatus(ctx, backupObj, cronJob); err != nil {
+			logger.Error(err, "update backup status failed")
+			return ctrl.Result{}, err
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -252,18 +192,42 @@ func (r *BackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&corev1.ServiceAccount{}).
 		Owns(&rbacv1.ClusterRole{}).
 		Owns(&rbacv1.ClusterRoleBinding{}).
+		// The jobs are owned by the cronjob, watch them to find out the killed backup.
+		Watches(&source.Kind{Type: &batchv1.Job{}}, handler.EnqueueRequestsFromMapFunc(backupForJob)).
 		WithEventFilter(predicate.Or(
 			common.BackupPredicate(),
 			common.ServiceAccountPredicate(),
 			common.ClusterRolePredicate(),
 			common.ClusterRoleBindingPredicate(),
+			common.JobPredicate(),
 		)).
 		Complete(r)
 }
 
 // cronJobForBackup construct a *batch1.CronJob resource that owned/controlled by the Backup resource.
+// The Backup.spec.schedule, timeout, successfulJobsHistoryLimit, failedJobsHistoryLimit
+// and env are all passed to the cronjob, the unset fields fall back to the default values.
 func (r *BackupReconciler) cronJobForBackup(ctx context.Context, backupObj *storagev1alpha1.Backup) *batchv1.CronJob {
-	cjData, err := template.Parse(template.CronJobForBackup, backupObj)
+	// Don't modify the Backup object in the cache, set default values on a copy.
+	backupCopy := backupObj.DeepCopy()
+	if len(backupCopy.Spec.Schedule) == 0 {
+		backupCopy.Spec.Schedule = types.DefaultBackupSchedule
+	}
+	// The history limits of 0 means keep no finished jobs, only default the unset ones.
+	if backupCopy.Spec.SuccessfulJobsHistoryLimit == nil {
+		successfulJobsHistoryLimit := types.DefaultBackupSuccessfulJobsHistoryLimit
+		backupCopy.Spec.SuccessfulJobsHistoryLimit = &successfulJobsHistoryLimit
+	}
+	if backupCopy.Spec.FailedJobsHistoryLimit == nil {
+		failedJobsHistoryLimit := types.DefaultBackupFailedJobsHistoryLimit
+		backupCopy.Spec.FailedJobsHistoryLimit = &failedJobsHistoryLimit
+	}
+	timeout := backupCopy.Spec.Timeout.Duration
+	if timeout <= 0 {
+		timeout = types.DefaultBackupTimeout
+	}
+
+	cjData, err := template.Parse(template.CronJobForBackup, backupCopy)
 	if err != nil {
 		r.Log.Error(err, "parse cronjob template failed")
 		os.Exit(1)
@@ -273,6 +237,21 @@ func (r *BackupReconciler) cronJobForBackup(ctx context.Context, backupObj *stor
 		r.Log.Error(err, "unmarshal cronjob failed")
 		os.Exit(1)
 	}
+
+	// The job will be terminated by kubernetes if the backup costs more time than Backup.spec.timeout.
+	activeDeadlineSeconds := int64(timeout.Seconds())
+	if activeDeadlineSeconds < 1 {
+		activeDeadlineSeconds = 1
+	}
+	cronjob.Spec.JobTemplate.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+	// Pass the environment variables defined in Backup.spec.env to horusctl.
+	containers := cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == "horusctl" {
+			containers[i].Env = append(containers[i].Env, backupCopy.Spec.Env...)
+		}
+	}
+
 	ctrl.SetControllerReference(backupObj, cronjob, r.Scheme)
 	util.SetRecommendedLabels(cronjob)
 
@@ -307,55 +286,6 @@ func (r *BackupReconciler) cronJobForBackup(ctx context.Context, backupObj *stor
 	return cronjob
 }
 
-// serviceAccountForBackup construct a *corev1.ServiceAccount resource that owned/controlled by the Backup resource.
-func (r *BackupReconciler) serviceAccountForBackup(backupObj *storagev1alpha1.Backup) *corev1.ServiceAccount {
-	serviceAccount := &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "horusctl",
-			Namespace: backupObj.GetNamespace(),
-		},
-	}
-	ctrl.SetControllerReference(backupObj, serviceAccount, r.Scheme)
-	util.SetRecommendedLabels(serviceAccount)
-	return serviceAccount
-}
-
-// clusterRoleForBackup construct a *rbacv1.ServiceAccount resource that owned/controlled by the Backup resource.
-func (r *BackupReconciler) clusterRoleForBackup(backupObj *storagev1alpha1.Backup) *rbacv1.ClusterRole {
-	crData, err := template.Parse(template.ClusterRoleForBackup, backupObj)
-	if err != nil {
-		r.Log.Error(err, "parse clusterrole template failed")
-		os.Exit(1)
-	}
-	crObj := &rbacv1.ClusterRole{}
-	if err := yaml.Unmarshal(crData, crObj); err != nil {
-		r.Log.Error(err, "unmarshal clusterrole failed")
-		os.Exit(1)
-	}
-	// Backup as namespace-scoped resource cannot owns/control cluster-scoped resource.
-	ctrl.SetControllerReference(backupObj, crObj, r.Scheme)
-	util.SetRecommendedLabels(crObj)
-	return crObj
-}
-
-// clusterRoleBindingForBackup construct a *rbacv1.ServiceAccount resource that owned/controlled by the Backup resource.
-func (r *BackupReconciler) clusterRoleBindingForBackup(backupObj *storagev1alpha1.Backup) *rbacv1.ClusterRoleBinding {
-	crbData, err := template.Parse(template.ClusterRoleBindingForBackup, backupObj)
-	if err != nil {
-		r.Log.Error(err, "parse clusterrolebinding template failed")
-		os.Exit(1)
-	}
-	crbObj := &rbacv1.ClusterRoleBinding{}
-	if err := yaml.Unmarshal(crbData, crbObj); err != nil {
-		r.Log.Error(err, "unmarshal clusterrolebinding failed")
-		os.Exit(1)
-	}
-	// Backup as namespace-scoped resource cannot owns/control cluster-scoped resource.
-	ctrl.SetControllerReference(backupObj, crbObj, r.Scheme)
-	util.SetRecommendedLabels(crbObj)
-	return crbObj
-}
-
 // handleFinalizer add finalizer when create/update Backup object, and remove
 // finalizer when delete Backup Object
 func (r *BackupReconciler) handleFinalizer(ctx context.Context, backupObj *storagev1alpha1.Backup) error {
@@ -394,22 +324,15 @@ func (r *BackupReconciler) handleFinalizer(ctx context.Context, backupObj *stora
 	return nil
 }
 
-// deleteExternalResources
+// deleteExternalResources deletes the serviceaccount, clusterrolebinding and rolebindings
+// of horusctl if no other Backup, Restore, Clone or Migration object left in the namespace.
 func (r *BackupReconciler) deleteExternalResources(ctx context.Context, backupObj *storagev1alpha1.Backup) error {
 	//
 	// delete any external resources associated with the cronJob
 	//
 	// Ensure that delete implementation is idempotent and safe to invoke
 	// multiple times for same object.
-	crbHandler := clusterrolebinding.NewOrDie(ctx, "")
-	crbName := fmt.Sprintf("horusctl-%s-binding", backupObj.GetNamespace())
-	// if clusterrolebinding resources not found, Delete method will return "NotFound" error,
-	// we should ignore the "NotFound" eror.
-	//if err := crbHandler.Delete(crbName); k8serrors.IgnoreNotFound(err) != nil {
-	if err := crbHandler.Delete(crbName); err != nil {
-		return errors.Wrapf(err, "clusterrolebinding handler delete clusterrolebinding/%s failed", crbName)
-	}
-	return nil
+	return deleteHorusctlRBAC(ctx, r.Client, backupObj)
 }
 
 // withNamespace set the object namespace to the provided namespace.
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// updateBackupStatus records the schedule of the Backup object in its status.
+// The result of every backup is patched by horusctl, but horusctl couldn't do it
+// if the job is killed, such as exceeding the activeDeadlineSeconds, so the
+// Backup object will be marked as "Failed" here if its last job failed.
+func (r *BackupReconciler) updateBackupStatus(ctx context.Context, backupObj *storagev1alpha1.Backup, cronJob *batchv1.CronJob) error {
+	original := backupObj.DeepCopy()
+	status := &backupObj.Status
+
+	scheduled := metav1.Condition{
+		Type:               string(storagev1alpha1.BackupConditionScheduled),
+		Status:             metav1.ConditionTrue,
+		Reason:             "CronJobCreated",
+		Message:            fmt.Sprintf("cronjob/%s is scheduled at %q", cronJob.GetName(), cronJob.Spec.Schedule),
+		ObservedGeneration: backupObj.GetGeneration(),
+	}
+	next, err := util.NextBackupTime(cronJob.Spec.Schedule, time.Now())
+	if err != nil {
+		scheduled.Status = metav1.ConditionFalse
+		scheduled.Reason = "InvalidSchedule"
+		scheduled.Message = err.Error()
+		status.NextBackupTime = nil
+	} else if status.NextBackupTime == nil || status.NextBackupTime.Time.Before(time.Now()) || status.ObservedGeneration != backupObj.GetGeneration() {
+		nextTime := metav1.NewTime(next)
+		status.NextBackupTime = &nextTime
+	}
+	meta.SetStatusCondition(&status.Conditions, scheduled)
+	status.ObservedGeneration = backupObj.GetGeneration()
+	if len(status.Phase) == 0 {
+		status.Phase = storagev1alpha1.BackupScheduled
+	}
+
+	if status.Phase == storagev1alpha1.BackupRunning {
+		job, err := r.lastJobForBackup(ctx, cronJob)
+		if err != nil {
+			return err
+		}
+		if job != nil {
+			if cond := jobFailedCondition(job); cond != nil {
+				message := fmt.Sprintf("job/%s failed: %s", job.GetName(), cond.Message)
+				status.Phase = storagev1alpha1.BackupFailed
+				status.Reason = cond.Reason
+				status.Message = message
+				for _, c := range []metav1.Condition{
+					{Type: string(storagev1alpha1.BackupConditionRunning), Status: metav1.ConditionFalse, Reason: cond.Reason},
+					{Type: string(storagev1alpha1.BackupConditionSucceeded), Status: metav1.ConditionFalse, Reason: cond.Reason, Message: message},
+					{Type: string(storagev1alpha1.BackupConditionFailed), Status: metav1.ConditionTrue, Reason: cond.Reason, Message: message},
+				} {
+					c.ObservedGeneration = backupObj.GetGeneration()
+					meta.SetStatusCondition(&status.Conditions, c)
+				}
+			}
+		}
+	}
+
+	if equality.Semantic.DeepEqual(original.Status, backupObj.Status) {
+		return nil
+	}
+	if err := r.Status().Patch(ctx, backupObj, client.MergeFrom(original)); err != nil {
+		return errors.Wrap(err, "patch backup status failed")
+	}
+	return nil
+}
+
+// lastJobForBackup returns the latest job created by the cronjob, returns nil if not found.
+func (r *BackupReconciler) lastJobForBackup(ctx context.Context, cronJob *batchv1.CronJob) (*batchv1.Job, error) {
+	jobList := &batchv1.JobList{}
+	if err := r.List(ctx, jobList, client.InNamespace(cronJob.GetNamespace())); err != nil {
+		return nil, errors.Wrap(err, "list jobs failed")
+	}
+	var last *batchv1.Job
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.Kind != "CronJob" || owner.Name != cronJob.GetName() {
+			continue
+		}
+		if last == nil || job.CreationTimestamp.After(last.CreationTimestamp.Time) {
+			last = job
+		}
+	}
+	return last, nil
+}
+
+// backupForJob maps the job created by the backup cronjob to the Backup object.
+func backupForJob(object client.Object) []reconcile.Request {
+	owner := metav1.GetControllerOf(object)
+	if owner == nil || owner.Kind != "CronJob" || !strings.HasPrefix(owner.Name, "backup-") {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: apitypes.NamespacedName{
+		Namespace: object.GetNamespace(),
+		Name:      strings.TrimPrefix(owner.Name, "backup-"),
+	}}}
+}
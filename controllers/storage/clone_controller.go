@@ -19,13 +19,19 @@ package storage
 import (
 	"context"
 
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/controllers/common"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
-
-	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
-	"github.com/go-logr/logr"
 )
 
 // CloneReconciler reconciles a Clone object
@@ -38,20 +44,78 @@ type CloneReconciler struct {
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=clones,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=clones/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=clones/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Clone object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile creates a job that runs horusctl to clone the persistentvolumeclaims defined
+// in Clone object. A Clone object is a one-shot task, the job will only be
+// created once, and the Clone object will be marked as "Failed" if the job failed.
+// The Clone status is updated by horusctl during the clone.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.12.1/pkg/reconcile
 func (r *CloneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
 
-	// TODO(user): your logic here
+	// Get clone object and ignore "NotFound" error.
+	cloneObj := &storagev1alpha1.Clone{}
+	if err := r.Get(ctx, req.NamespacedName, cloneObj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// The clone already finished, nothing to do.
+	switch cloneObj.Status.Phase {
+	case storagev1alpha1.CloneSucceeded, storagev1alpha1.CloneFailed:
+		return ctrl.Result{}, nil
+	}
+
+	// =========================
+	// reconcile ServiceAccount, ClusterRole and ClusterRoleBinding
+	// =========================
+	if err := ensureHorusctlRBAC(ctx, r.Client, cloneObj); err != nil {
+		logger.Error(err, "ensure horusctl rbac failed")
+		return ctrl.Result{}, err
+	}
+
+	// =========================
+	// reconcile Job
+	// =========================
+	namespacedName := apitypes.NamespacedName{Namespace: req.Namespace, Name: "clone" + "-" + req.Name}
+	existingJob := &batchv1.Job{}
+	if err := r.Get(ctx, namespacedName, existingJob); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "get job failed")
+			return ctrl.Result{}, err
+		}
+		job, err := r.jobForClone(cloneObj)
+		if err != nil {
+			logger.Error(err, "construct job failed")
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			logger.Error(err, "create job failed")
+			return ctrl.Result{}, err
+		}
+		logger.Info("Successfully create job/" + job.GetName())
+		if len(cloneObj.Status.Phase) == 0 {
+			cloneObj.Status.Phase = storagev1alpha1.ClonePending
+			if err := r.Status().Update(ctx, cloneObj); err != nil {
+				logger.Error(err, "update clone status failed")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	failed := cloneObj.Status.Phase == storagev1alpha1.CloneFailed
+	if err := syncJobFailed(ctx, r.Client, logger, cloneObj, existingJob, failed, func(now metav1.Time, message string) {
+		cloneObj.Status.Phase = storagev1alpha1.CloneFailed
+		cloneObj.Status.CompletionTime = &now
+		if len(cloneObj.Status.Message) == 0 {
+			cloneObj.Status.Message = message
+		}
+	}); err != nil {
+		logger.Error(err, "update clone status failed")
+		return ctrl.Result{}, err
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -59,6 +123,13 @@ func (r *CloneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 // SetupWithManager sets up the controller with the Manager.
 func (r *CloneReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&storagev1alpha1.Clone{}).
+		For(&storagev1alpha1.Clone{}, builder.WithPredicates(common.ClonePredicate())).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
+
+// jobForClone construct a *batchv1.Job resource that owned/controlled by the Clone resource.
+// The job will be terminated by kubernetes if the clone costs more time than Clone.spec.timeout.
+func (r *CloneReconciler) jobForClone(cloneObj *storagev1alpha1.Clone) (*batchv1.Job, error) {
+	return horusctlJob(cloneObj, template.JobForClone, cloneObj.Spec.Timeout.Duration, types.DefaultCloneTimeout, r.Scheme)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// horusctlJob construct a *batchv1.Job resource that run horusctl from the job
+// template, the job is owned/controlled by the object.
+// The job will be terminated by kubernetes if it costs more time than timeout,
+// defaultTimeout is used if timeout is not positive.
+func horusctlJob(object client.Object, jobTemplate string, timeout, defaultTimeout time.Duration, scheme *runtime.Scheme) (*batchv1.Job, error) {
+	jobData, err := template.Parse(jobTemplate, object)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse job template failed")
+	}
+	job := &batchv1.Job{}
+	if err := yaml.Unmarshal(jobData, job); err != nil {
+		return nil, errors.Wrap(err, "unmarshal job failed")
+	}
+
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	activeDeadlineSeconds := int64(timeout.Seconds())
+	if activeDeadlineSeconds < 1 {
+		activeDeadlineSeconds = 1
+	}
+	job.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+
+	if err := ctrl.SetControllerReference(object, job, scheme); err != nil {
+		return nil, errors.Wrap(err, "set controller reference failed")
+	}
+	util.SetRecommendedLabels(job)
+	return job, nil
+}
+
+// jobFailedCondition returns the "Failed" condition of the job, returns nil if the job not failed.
+func jobFailedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		cond := &job.Status.Conditions[i]
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			return cond
+		}
+	}
+	return nil
+}
+
+// syncJobFailed marks the object "Failed" by markFailed if the horusctl job failed.
+// horusctl marks the object "Failed" if it failed, but it couldn't if the job is
+// killed, such as exceeding the activeDeadlineSeconds.
+// failed is true if the object is already "Failed", its status is kept, so the
+// completion time isn't reset by the following reconciles.
+func syncJobFailed(ctx context.Context, c client.Client, logger logr.Logger, object client.Object, job *batchv1.Job,
+	failed bool, markFailed func(now metav1.Time, message string)) error {
+	cond := jobFailedCondition(job)
+	if cond == nil || failed {
+		return nil
+	}
+	markFailed(metav1.Now(), fmt.Sprintf("job/%s failed: %s", job.GetName(), cond.Message))
+	if err := c.Status().Update(ctx, object); err != nil {
+		return err
+	}
+	logger.Info("horusctl job failed", "job", job.GetName(), "reason", cond.Reason)
+	return nil
+}
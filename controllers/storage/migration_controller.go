@@ -19,13 +19,19 @@ package storage
 import (
 	"context"
 
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/controllers/common"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
-
-	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
-	"github.com/go-logr/logr"
 )
 
 // MigrationReconciler reconciles a Migration object
@@ -38,20 +44,78 @@ type MigrationReconciler struct {
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=migrations,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=migrations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=migrations/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Migration object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile creates a job that runs horusctl to migrate the persistentvolumeclaims defined
+// in Migration object. A Migration object is a one-shot task, the job will only be
+// created once, and the Migration object will be marked as "Failed" if the job failed.
+// The Migration status is updated by horusctl during the migration.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.12.1/pkg/reconcile
 func (r *MigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
 
-	// TODO(user): your logic here
+	// Get migration object and ignore "NotFound" error.
+	migrationObj := &storagev1alpha1.Migration{}
+	if err := r.Get(ctx, req.NamespacedName, migrationObj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// The migration already finished, nothing to do.
+	switch migrationObj.Status.Phase {
+	case storagev1alpha1.MigrationSucceeded, storagev1alpha1.MigrationFailed:
+		return ctrl.Result{}, nil
+	}
+
+	// =========================
+	// reconcile ServiceAccount, ClusterRole and ClusterRoleBinding
+	// =========================
+	if err := ensureHorusctlRBAC(ctx, r.Client, migrationObj); err != nil {
+		logger.Error(err, "ensure horusctl rbac failed")
+		return ctrl.Result{}, err
+	}
+
+	// =========================
+	// reconcile Job
+	// =========================
+	namespacedName := apitypes.NamespacedName{Namespace: req.Namespace, Name: "migration" + "-" + req.Name}
+	existingJob := &batchv1.Job{}
+	if err := r.Get(ctx, namespacedName, existingJob); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "get job failed")
+			return ctrl.Result{}, err
+		}
+		job, err := r.jobForMigration(migrationObj)
+		if err != nil {
+			logger.Error(err, "construct job failed")
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			logger.Error(err, "create job failed")
+			return ctrl.Result{}, err
+		}
+		logger.Info("Successfully create job/" + job.GetName())
+		if len(migrationObj.Status.Phase) == 0 {
+			migrationObj.Status.Phase = storagev1alpha1.MigrationPending
+			if err := r.Status().Update(ctx, migrationObj); err != nil {
+				logger.Error(err, "update migration status failed")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	failed := migrationObj.Status.Phase == storagev1alpha1.MigrationFailed
+	if err := syncJobFailed(ctx, r.Client, logger, migrationObj, existingJob, failed, func(now metav1.Time, message string) {
+		migrationObj.Status.Phase = storagev1alpha1.MigrationFailed
+		migrationObj.Status.CompletionTime = &now
+		if len(migrationObj.Status.Message) == 0 {
+			migrationObj.Status.Message = message
+		}
+	}); err != nil {
+		logger.Error(err, "update migration status failed")
+		return ctrl.Result{}, err
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -59,6 +123,13 @@ func (r *MigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 // SetupWithManager sets up the controller with the Manager.
 func (r *MigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&storagev1alpha1.Migration{}).
+		For(&storagev1alpha1.Migration{}, builder.WithPredicates(common.MigrationPredicate())).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
+
+// jobForMigration construct a *batchv1.Job resource that owned/controlled by the Migration resource.
+// The job will be terminated by kubernetes if the migration costs more time than Migration.spec.timeout.
+func (r *MigrationReconciler) jobForMigration(migrationObj *storagev1alpha1.Migration) (*batchv1.Job, error) {
+	return horusctlJob(migrationObj, template.JobForMigration, migrationObj.Spec.Timeout.Duration, types.DefaultMigrationTimeout, r.Scheme)
+}
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ensureHorusctlRBAC creates the serviceaccount, clusterroles, clusterrolebinding and
+// rolebindings required by horusctl in the namespace of the object if they don't exist.
+// The clusterrolebinding only grants the permissions of the cluster-scoped resources,
+// the permissions of the namespaced resources are granted by a rolebinding in every
+// namespace horusctl works in, see horusctlNamespaces.
+// The object doesn't own them, because they are shared by all the Backup, Restore,
+// Clone and Migration objects in the same namespace, see deleteHorusctlRBAC.
+func ensureHorusctlRBAC(ctx context.Context, c client.Client, object client.Object) error {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "horusctl",
+			Namespace: object.GetNamespace(),
+		},
+	}
+	util.SetRecommendedLabels(serviceAccount)
+
+	var clusterRoles []*rbacv1.ClusterRole
+	for _, crTemplate := range []string{template.ClusterRoleForBackup, template.NamespacedRoleForBackup} {
+		crData, err := template.Parse(crTemplate, object)
+		if err != nil {
+			return errors.Wrap(err, "parse clusterrole template failed")
+		}
+		clusterRole := &rbacv1.ClusterRole{}
+		if err := yaml.Unmarshal(crData, clusterRole); err != nil {
+			return errors.Wrap(err, "unmarshal clusterrole failed")
+		}
+		util.SetRecommendedLabels(clusterRole)
+		clusterRoles = append(clusterRoles, clusterRole)
+	}
+
+	crbData, err := template.Parse(template.ClusterRoleBindingForBackup, object)
+	if err != nil {
+		return errors.Wrap(err, "parse clusterrolebinding template failed")
+	}
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+	if err := yaml.Unmarshal(crbData, clusterRoleBinding); err != nil {
+		return errors.Wrap(err, "unmarshal clusterrolebinding failed")
+	}
+	util.SetRecommendedLabels(clusterRoleBinding)
+
+	for _, obj := range []client.Object{serviceAccount, clusterRoles[0], clusterRoles[1], clusterRoleBinding} {
+		if err := c.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "create %T %s failed", obj, obj.GetName())
+		}
+	}
+	// The clusterroles may be created by an old version operator, keep their rules up to date.
+	for _, clusterRole := range clusterRoles {
+		existingClusterRole := &rbacv1.ClusterRole{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(clusterRole), existingClusterRole); err != nil {
+			return errors.Wrapf(err, "get clusterrole %s failed", clusterRole.GetName())
+		}
+		if !equality.Semantic.DeepEqual(existingClusterRole.Rules, clusterRole.Rules) {
+			existingClusterRole.Rules = clusterRole.Rules
+			if err := c.Update(ctx, existingClusterRole); err != nil {
+				return errors.Wrapf(err, "update clusterrole %s failed", clusterRole.GetName())
+			}
+		}
+	}
+
+	// The Clone object clones the persistentvolumeclaims to a namespace that may not exist,
+	// create it so that the rolebinding can be created in it.
+	if cloneObj, ok := object.(*storagev1alpha1.Clone); ok && cloneObj.Spec.CloneTo != nil && len(cloneObj.Spec.CloneTo.Namespace) != 0 {
+		nsObj := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: cloneObj.Spec.CloneTo.Namespace}}
+		if err := c.Create(ctx, nsObj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "create namespace %s failed", nsObj.GetName())
+		}
+	}
+
+	rbData, err := template.Parse(template.RoleBindingForBackup, object)
+	if err != nil {
+		return errors.Wrap(err, "parse rolebinding template failed")
+	}
+	namespaces, err := horusctlNamespaces(ctx, c, object)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		roleBinding := &rbacv1.RoleBinding{}
+		if err := yaml.Unmarshal(rbData, roleBinding); err != nil {
+			return errors.Wrap(err, "unmarshal rolebinding failed")
+		}
+		roleBinding.SetNamespace(namespace)
+		util.SetRecommendedLabels(roleBinding)
+		labels := roleBinding.GetLabels()
+		labels[types.LabelHorusctlNamespace] = object.GetNamespace()
+		roleBinding.SetLabels(labels)
+		// The namespace of the backup target may be deleted, horusctl will report it.
+		if err := c.Create(ctx, roleBinding); err != nil && !apierrors.IsAlreadyExists(err) && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "create rolebinding %s in namespace %s failed", roleBinding.GetName(), namespace)
+		}
+	}
+	return nil
+}
+
+// horusctlNamespaces returns the namespaces horusctl works in for the object:
+// the namespace of the object, the namespace of the horus-operator where the
+// executors run and the credentials stored, the namespaces of the backup targets,
+// the namespace cloned to and the namespaces of the credential secrets.
+func horusctlNamespaces(ctx context.Context, c client.Client, object client.Object) ([]string, error) {
+	namespaces := []string{object.GetNamespace(), util.GetOperatorNamespace()}
+
+	var backupName string
+	switch obj := object.(type) {
+	case *storagev1alpha1.Backup:
+		backupNamespaces, err := backupTargetNamespaces(ctx, c, obj)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, backupNamespaces...)
+		namespaces = append(namespaces, credentialNamespaces(obj.Spec.BackupTo)...)
+	case *storagev1alpha1.Restore:
+		if restoreFrom := obj.Spec.RestoreFrom; restoreFrom != nil {
+			backupName = restoreFrom.Backup
+			namespaces = append(namespaces, credentialNamespaces(restoreFrom.Repository)...)
+		}
+	case *storagev1alpha1.Clone:
+		if obj.Spec.CloneTo != nil && len(obj.Spec.CloneTo.Namespace) != 0 {
+			namespaces = append(namespaces, obj.Spec.CloneTo.Namespace)
+		}
+		backupName = obj.Spec.Backup
+	case *storagev1alpha1.Migration:
+		backupName = obj.Spec.Backup
+	}
+	// The Restore, Clone and Migration use the restic repository and credentials of the Backup object.
+	if len(backupName) != 0 {
+		backupObj := &storagev1alpha1.Backup{}
+		err := c.Get(ctx, client.ObjectKey{Namespace: object.GetNamespace(), Name: backupName}, backupObj)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, errors.Wrapf(err, "get backup %s failed", backupName)
+		}
+		if err == nil {
+			namespaces = append(namespaces, credentialNamespaces(backupObj.Spec.BackupTo)...)
+		}
+	}
+
+	var (
+		result []string
+		seen   = make(map[string]bool)
+	)
+	for _, namespace := range namespaces {
+		if len(namespace) != 0 && !seen[namespace] {
+			seen[namespace] = true
+			result = append(result, namespace)
+		}
+	}
+	return result, nil
+}
+
+// backupTargetNamespaces returns the namespaces of Backup.spec.backupFrom.targets and
+// the namespaces matched Backup.spec.backupFrom.namespaceSelector, nil returned if
+// the Backup object is not in the operator namespace.
+func backupTargetNamespaces(ctx context.Context, c client.Client, backupObj *storagev1alpha1.Backup) ([]string, error) {
+	backupFrom := backupObj.Spec.BackupFrom
+	// only the Backup objects in the operator namespace can backup the resources
+	// in other namespaces, horusctl rejects the others.
+	if backupFrom == nil || backupObj.GetNamespace() != util.GetOperatorNamespace() {
+		return nil, nil
+	}
+	var namespaces []string
+	for _, target := range backupFrom.Targets {
+		namespaces = append(namespaces, target.Namespace)
+	}
+	if backupFrom.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(backupFrom.NamespaceSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse Backup.spec.backupFrom.namespaceSelector failed")
+		}
+		nsList := &corev1.NamespaceList{}
+		if err := c.List(ctx, nsList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, errors.Wrap(err, "list namespaces failed")
+		}
+		for _, nsObj := range nsList.Items {
+			namespaces = append(namespaces, nsObj.GetName())
+		}
+	}
+	return namespaces, nil
+}
+
+// credentialNamespaces returns the namespaces of the credential secrets of the storages.
+func credentialNamespaces(backupTo *storagev1alpha1.BackupTo) []string {
+	if backupTo == nil {
+		return nil
+	}
+	var namespaces []string
+	if backupTo.S3 != nil {
+		namespaces = append(namespaces, backupTo.S3.CredentialNamespace)
+	}
+	if backupTo.RestServer != nil {
+		namespaces = append(namespaces, backupTo.RestServer.CredentialNamespace)
+	}
+	if backupTo.CephFS != nil {
+		namespaces = append(namespaces, backupTo.CephFS.CredentialNamespace)
+	}
+	if backupTo.Rclone != nil {
+		namespaces = append(namespaces, backupTo.Rclone.CredentialNamespace)
+	}
+	return namespaces
+}
+
+// deleteHorusctlRBAC deletes the serviceaccount, clusterrolebinding and rolebindings
+// created by ensureHorusctlRBAC for the namespace of the object, but only if there
+// is no other Backup, Restore, Clone or Migration object left in the namespace,
+// otherwise the running horusctl of them will lose the permissions.
+func deleteHorusctlRBAC(ctx context.Context, c client.Client, object client.Object) error {
+	namespace := object.GetNamespace()
+	for _, list := range []client.ObjectList{
+		&storagev1alpha1.BackupList{},
+		&storagev1alpha1.RestoreList{},
+		&storagev1alpha1.CloneList{},
+		&storagev1alpha1.MigrationList{},
+	} {
+		if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return errors.Wrapf(err, "list %T in namespace %s failed", list, namespace)
+		}
+		var inUse bool
+		if err := apimeta.EachListItem(list, func(obj runtime.Object) error {
+			inUse = inUse || obj.(client.Object).GetUID() != object.GetUID()
+			return nil
+		}); err != nil {
+			return err
+		}
+		if inUse {
+			return nil
+		}
+	}
+
+	rbList := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, rbList, client.MatchingLabels{types.LabelHorusctlNamespace: namespace}); err != nil {
+		return errors.Wrap(err, "list rolebindings failed")
+	}
+	objects := []client.Object{
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("horusctl-%s-binding", namespace)}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "horusctl", Namespace: namespace}},
+	}
+	for i := range rbList.Items {
+		objects = append(objects, &rbList.Items[i])
+	}
+	for _, obj := range objects {
+		if err := c.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, "delete %T %s failed", obj, obj.GetName())
+		}
+	}
+	return nil
+}
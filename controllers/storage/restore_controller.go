@@ -19,12 +19,19 @@ package storage
 import (
 	"context"
 
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/controllers/common"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-
-	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
-	"github.com/go-logr/logr"
 )
 
 // RestoreReconciler reconciles a Restore object
@@ -37,22 +44,78 @@ type RestoreReconciler struct {
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=restores,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=restores/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=storage.hybfkuf.io,resources=restores/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Restore object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile creates a job that runs horusctl to restore the k8s resource defined
+// in Restore object. A Restore object is a one-shot task, the job will only be
+// created once, and the Restore object will be marked as "Failed" if the job failed.
+// The Restore status is updated by horusctl during the restore.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.12.1/pkg/reconcile
 func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	//_ = log.FromContext(ctx)
-	reqLogger := r.Log.WithValues("Request.Namespace", req.Namespace, "Request.Name", req.Name)
-	reqLogger.Info("Reconciling restore controller")
+	logger := r.Log.WithValues("namespace", req.Namespace, "name", req.Name)
 
-	// TODO(user): your logic here
+	// Get restore object and ignore "NotFound" error.
+	restoreObj := &storagev1alpha1.Restore{}
+	if err := r.Get(ctx, req.NamespacedName, restoreObj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// The restore already finished, nothing to do.
+	switch restoreObj.Status.Phase {
+	case storagev1alpha1.RestoreSucceeded, storagev1alpha1.RestoreFailed:
+		return ctrl.Result{}, nil
+	}
+
+	// =========================
+	// reconcile ServiceAccount, ClusterRole and ClusterRoleBinding
+	// =========================
+	if err := ensureHorusctlRBAC(ctx, r.Client, restoreObj); err != nil {
+		logger.Error(err, "ensure horusctl rbac failed")
+		return ctrl.Result{}, err
+	}
+
+	// =========================
+	// reconcile Job
+	// =========================
+	namespacedName := apitypes.NamespacedName{Namespace: req.Namespace, Name: "restore" + "-" + req.Name}
+	existingJob := &batchv1.Job{}
+	if err := r.Get(ctx, namespacedName, existingJob); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "get job failed")
+			return ctrl.Result{}, err
+		}
+		job, err := r.jobForRestore(restoreObj)
+		if err != nil {
+			logger.Error(err, "construct job failed")
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			logger.Error(err, "create job failed")
+			return ctrl.Result{}, err
+		}
+		logger.Info("Successfully create job/" + job.GetName())
+		if len(restoreObj.Status.Phase) == 0 {
+			restoreObj.Status.Phase = storagev1alpha1.RestorePending
+			if err := r.Status().Update(ctx, restoreObj); err != nil {
+				logger.Error(err, "update restore status failed")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	failed := restoreObj.Status.Phase == storagev1alpha1.RestoreFailed
+	if err := syncJobFailed(ctx, r.Client, logger, restoreObj, existingJob, failed, func(now metav1.Time, message string) {
+		restoreObj.Status.Phase = storagev1alpha1.RestoreFailed
+		restoreObj.Status.CompletionTime = &now
+		if len(restoreObj.Status.Message) == 0 {
+			restoreObj.Status.Message = message
+		}
+	}); err != nil {
+		logger.Error(err, "update restore status failed")
+		return ctrl.Result{}, err
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -60,6 +123,13 @@ func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 // SetupWithManager sets up the controller with the Manager.
 func (r *RestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&storagev1alpha1.Restore{}).
+		For(&storagev1alpha1.Restore{}, builder.WithPredicates(common.RestorePredicate())).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
+
+// jobForRestore construct a *batchv1.Job resource that owned/controlled by the Restore resource.
+// The job will be terminated by kubernetes if the restore costs more time than Restore.spec.timeout.
+func (r *RestoreReconciler) jobForRestore(restoreObj *storagev1alpha1.Restore) (*batchv1.Job, error) {
+	return horusctlJob(restoreObj, template.JobForRestore, restoreObj.Spec.Timeout.Duration, types.DefaultRestoreTimeout, r.Scheme)
+}
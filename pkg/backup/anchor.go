@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"os"
+	"time"
+
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// executorAnchorName is the name prefix of the executor anchor configmap.
+	executorAnchorName = "horusctl-executors-"
+	// the keys of the executor anchor configmap data.
+	anchorPodNamespace = "podNamespace"
+	anchorPodName      = "podName"
+	anchorPodUID       = "podUID"
+	anchorExpires      = "expires"
+)
+
+// executorOwner returns the owner references of the executor created in the namespace.
+// The executor created in the namespace of horusctl is owned by the horusctl pod,
+// so it's garbage collected by kubernetes with the horusctl job. Owner reference
+// can't cross namespaces, the executor created in the other namespaces, such as
+// the namespace of the horus-operator, is owned by the executor anchor of the run
+// in that namespace, see executorAnchor. nil returned if neither is available.
+//
+// deadline is the max seconds the executor runs.
+func (r *backupRun) executorOwner(namespace string, deadline int64) []metav1.OwnerReference {
+	podObj := horusctlPod()
+	if podObj != nil && podObj.GetNamespace() == namespace {
+		return controllerReference("Pod", podObj.GetName(), podObj.GetUID())
+	}
+	cmObj, err := r.executorAnchor(namespace, podObj, deadline)
+	if err != nil {
+		r.logger.Warnf("create the executor anchor in namespace/%s failed: %s", namespace, err.Error())
+		return nil
+	}
+	return controllerReference("ConfigMap", cmObj.GetName(), cmObj.GetUID())
+}
+
+// controllerReference returns the owner references to the core/v1 object.
+// blockOwnerDeletion is not set, it requires the permission to update the finalizers of the owner.
+func controllerReference(kind, name string, uid k8stypes.UID) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       kind,
+		Name:       name,
+		UID:        uid,
+		Controller: &controller,
+	}}
+}
+
+// horusctlPod returns the pod horusctl running in, nil returned if horusctl is not
+// running in pod.
+func horusctlPod() *corev1.Pod {
+	name, namespace := os.Getenv(util.EnvPodName), os.Getenv(util.EnvPodNamespace)
+	if len(name) == 0 || len(namespace) == 0 {
+		return nil
+	}
+	podObj, err := podHandler.WithNamespace(namespace).Get(name)
+	if err != nil {
+		logger.Warnf("pod handler get pod/%s failed: %s", name, err.Error())
+		return nil
+	}
+	return podObj
+}
+
+// executorAnchor returns the configmap owns the executors created by the run in
+// the namespace, it's created for the first executor in the namespace, and deleted
+// by cleanup, kubernetes deletes the executors it owns.
+// If horusctl is killed before cleanup, the anchor is left, so the anchors left by
+// the previous runs are swept before the anchor created, see sweepExecutorAnchors.
+func (r *backupRun) executorAnchor(namespace string, horusctl *corev1.Pod, deadline int64) (*corev1.ConfigMap, error) {
+	r.state.anchorMu.Lock()
+	defer r.state.anchorMu.Unlock()
+	if cmObj, ok := r.state.anchors[namespace]; ok {
+		return cmObj, nil
+	}
+	r.sweepExecutorAnchors(namespace)
+
+	// the run never lasts longer than the executors, give kubernetes more time to terminate them.
+	expires := time.Now().Add(time.Duration(deadline)*time.Second + time.Minute)
+	cmObj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: executorAnchorName,
+			Namespace:    namespace,
+			Labels: map[string]string{
+				types.LabelExecutorAnchor: "true",
+				types.LabelPartOf:         "horus",
+				types.LabelManagedBy:      "horus-operator",
+			},
+		},
+		Data: map[string]string{anchorExpires: expires.Format(time.RFC3339)},
+	}
+	if horusctl != nil {
+		cmObj.Data[anchorPodNamespace] = horusctl.GetNamespace()
+		cmObj.Data[anchorPodName] = horusctl.GetName()
+		cmObj.Data[anchorPodUID] = string(horusctl.GetUID())
+	}
+	cmObj, err := cmHandler.WithNamespace(namespace).Create(cmObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "configmap handler create configmap failed")
+	}
+	r.state.anchors[namespace] = cmObj
+	r.logger.Debugf("Created executor anchor configmap/%s in namespace/%s", cmObj.GetName(), namespace)
+	return cmObj, nil
+}
+
+// sweepExecutorAnchors deletes the executor anchors in the namespace left by the
+// runs whose horusctl pod is gone or terminated, or expired, so the executors
+// they own are garbage collected by kubernetes.
+func (r *backupRun) sweepExecutorAnchors(namespace string) {
+	handler := cmHandler.WithNamespace(namespace)
+	cmObjs, err := handler.ListByLabel(types.LabelExecutorAnchor + "=true")
+	if err != nil {
+		r.logger.Warnf("configmap handler list executor anchors in namespace/%s failed: %s", namespace, err.Error())
+		return
+	}
+	for _, cmObj := range cmObjs {
+		if !anchorOrphaned(cmObj) {
+			continue
+		}
+		if err := handler.Delete(cmObj.GetName()); err != nil && !apierrors.IsNotFound(err) {
+			r.logger.Warnf("delete executor anchor configmap/%s failed: %s", cmObj.GetName(), err.Error())
+			continue
+		}
+		r.logger.Infof("Deleted executor anchor configmap/%s left by the previous run", cmObj.GetName())
+	}
+}
+
+// anchorOrphaned returns true if the horusctl pod created the anchor is gone or
+// terminated, or the anchor expired. If the horusctl pod can't be checked, such
+// as horusctl has no permission to get pods in that namespace, only the expiration
+// time is checked.
+func anchorOrphaned(cmObj *corev1.ConfigMap) bool {
+	if expires, err := time.Parse(time.RFC3339, cmObj.Data[anchorExpires]); err != nil || time.Now().After(expires) {
+		return true
+	}
+	namespace, name := cmObj.Data[anchorPodNamespace], cmObj.Data[anchorPodName]
+	if len(namespace) == 0 || len(name) == 0 {
+		return false
+	}
+	podObj, err := podHandler.WithNamespace(namespace).Get(name)
+	if err != nil {
+		return apierrors.IsNotFound(err)
+	}
+	if string(podObj.GetUID()) != cmObj.Data[anchorPodUID] {
+		return true
+	}
+	return podObj.Status.Phase == corev1.PodSucceeded || podObj.Status.Phase == corev1.PodFailed
+}
+
+// deleteExecutorAnchors deletes the executor anchors created by the run.
+func (r *backupRun) deleteExecutorAnchors() {
+	r.state.anchorMu.Lock()
+	defer r.state.anchorMu.Unlock()
+	for namespace, cmObj := range r.state.anchors {
+		if err := cmHandler.WithNamespace(namespace).Delete(cmObj.GetName()); err != nil && !apierrors.IsNotFound(err) {
+			r.logger.Warnf("delete executor anchor configmap/%s failed: %s", cmObj.GetName(), err.Error())
+		}
+		delete(r.state.anchors, namespace)
+	}
+}
@@ -3,13 +3,18 @@ package backup
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/forbearing/k8s/configmap"
 	"github.com/forbearing/k8s/daemonset"
 	"github.com/forbearing/k8s/deployment"
+	"github.com/forbearing/k8s/job"
 	"github.com/forbearing/k8s/dynamic"
 	"github.com/forbearing/k8s/persistentvolume"
 	"github.com/forbearing/k8s/persistentvolumeclaim"
@@ -21,26 +26,20 @@ import (
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-var (
-	DeployNameFindpvdir         string
-	DeployNameBackup2minio      string
-	DeployNameBackup2nfs        string
-	DeployNameBackup2s3         string
-	DeployNameBackup2cephfs     string
-	DeployNameBackup2sftp       string
-	DeployNameBackup2rclone     string
-	DeployNameBackup2restserver string
-)
-
 const (
 	resticRepo        = "/restic-repo"
 	resticPasswd      = "mypass"
 	mountHostRootPath = "/host-root"
 
+	// repositoryReleaseTimeout is the max time to wait for the ReadWriteOnce
+	// persistentvolumeclaim used as restic repository released by other k8s nodes.
+	repositoryReleaseTimeout = 5 * time.Minute
+
 	HostBackupToNFS   = "backup-to-nfs"
 	HostBackupToS3    = "backup-to-s3"
 	HostBackupToMinio = "backup-to-minio"
@@ -53,46 +52,68 @@ const (
 	backup2minioImage = backup2nfsImage
 	backup2sftpName   = "backup-to-sftp"
 	backup2sftpImage  = backup2nfsImage
+	backup2s3Name     = "backup-to-s3"
+	backup2s3Image    = backup2nfsImage
+	defaultS3Endpoint = "s3.amazonaws.com"
+
+	backup2restserverName  = "backup-to-restserver"
+	backup2restserverImage = backup2nfsImage
+	defaultRestServerPort  = 8000
+	backup2cephfsName      = "backup-to-cephfs"
+	backup2cephfsImage     = backup2nfsImage
+	defaultCephfsUser      = "admin"
+	cephfsKeyringKey       = "key"
+	backup2rcloneName      = "backup-to-rclone"
+	backup2rcloneImage     = backup2nfsImage
+	rcloneConfigPath       = "/etc/rclone"
+	rcloneConfigKey        = "rclone.conf"
+	backup2pvcName         = "backup-to-pvc"
+	backup2pvcImage        = backup2nfsImage
+	pvcMounterName         = "backup-mount-pvc"
+	pvcMounterImage        = backup2nfsImage
+
 	envMinioAccessKey = "MINIO_ACCESS_KEY"
 	envMinioSecretKey = "MINIO_SECRET_KEY"
 	envSftpUsername   = "SFTP_USERNAME"
 	envSftpPassword   = "SFTP_PASSWORD"
+
+	envAwsAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	envAwsSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	envAwsSessionToken    = "AWS_SESSION_TOKEN"
+	envRestUsername       = "RESTIC_REST_USERNAME"
+	envRestPassword       = "RESTIC_REST_PASSWORD"
 )
 
 var (
 	ctx        = context.TODO()
 	podHandler = pod.NewOrDie(ctx, "", "")
 	depHandler = deployment.NewOrDie(ctx, "", "")
+	jobHandler = job.NewOrDie(ctx, "", "")
 	rsHandler  = replicaset.NewOrDie(ctx, "", "")
 	stsHandler = statefulset.NewOrDie(ctx, "", "")
 	dsHandler  = daemonset.NewOrDie(ctx, "", "")
 	pvHandler  = persistentvolume.NewOrDie(ctx, "")
 	pvcHandler = persistentvolumeclaim.NewOrDie(ctx, "", "")
 	secHandler = secret.NewOrDie(ctx, "", "")
+	cmHandler  = configmap.NewOrDie(ctx, "", "")
 	dynHandler = dynamic.NewOrDie(ctx, "", "")
 )
 
-var (
-	logger     = logrus.WithFields(logrus.Fields{})
-	costedTime time.Duration
-)
+// logger is the base logger, it's never changed, every run of Do
+// uses its own logger, see backupRun.
+var logger = logrus.WithFields(logrus.Fields{})
 
 // Do start to backup k8s pod/deployment/statefulset/daemonset defined in Backup object
 // namespace is the k8s resource namespace
 // name is the k8s resource name
-func Do(ctx context.Context, namespace, name string) error {
-	// clean deployment
-	defer func() {
-		depHandler.ResetNamespace(util.GetOperatorNamespace())
-		depHandler.Delete(DeployNameFindpvdir)
-		depHandler.Delete(DeployNameBackup2nfs)
-		depHandler.Delete(DeployNameBackup2minio)
-		depHandler.Delete(DeployNameBackup2s3)
-		depHandler.Delete(DeployNameBackup2cephfs)
-		depHandler.Delete(DeployNameBackup2sftp)
-		depHandler.Delete(DeployNameBackup2rclone)
-		depHandler.Delete(DeployNameBackup2restserver)
-	}()
+func Do(ctx context.Context, namespace, name string) (err error) {
+	logger := logger.WithFields(logrus.Fields{
+		"name":      name,
+		"namespace": namespace,
+	})
+	r := newBackupRun(logger)
+	// clean the deployments created by the run.
+	defer r.cleanup()
 
 	// ==============================
 	// 1. dynamic handler get Backup object
@@ -115,47 +136,267 @@ func Do(ctx context.Context, namespace, name string) error {
 		logger.Error(err)
 		return err
 	}
-	// setup logger
-	backupFrom := backupObj.Spec.BackupFrom
-	logger = logger.WithFields(logrus.Fields{
-		"name":      name,
-		"namespace": namespace,
-		"resource":  backupFrom.Resource,
-	})
 	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully get Backup object")
 
+	// The Backup status is updated when backup begin and finish, whether success or failure.
+	status := backupObj.Status.DeepCopy()
+	startTime := metav1.Now()
+	status.Phase = storagev1alpha1.BackupRunning
+	status.LastBackupTime = &startTime
+	status.Duration = nil
+	status.BytesAdded = 0
+	status.PVCs = nil
+	status.Storage = nil
+	status.Message = ""
+	status.Reason = ""
+	status.Targets = nil
+	setCondition(backupObj, status, storagev1alpha1.BackupConditionRunning, metav1.ConditionTrue, reasonBackupStarted, "")
+	if err := patchStatus(backupObj, status); err != nil {
+		logger.Warn(err)
+	}
+	reason := reasonBackupFailed
+	defer func() {
+		duration := metav1.Duration{Duration: time.Now().Sub(startTime.Time)}
+		status.Duration = &duration
+		if next, err := util.NextBackupTime(backupObj.Spec.Schedule, time.Now()); err == nil {
+			nextTime := metav1.NewTime(next)
+			status.NextBackupTime = &nextTime
+		}
+		setCondition(backupObj, status, storagev1alpha1.BackupConditionRunning, metav1.ConditionFalse, reasonBackupFinished, "")
+		if err != nil {
+			status.Phase = storagev1alpha1.BackupFailed
+			status.Reason = reason
+			status.Message = err.Error()
+			setCondition(backupObj, status, storagev1alpha1.BackupConditionSucceeded, metav1.ConditionFalse, reason, err.Error())
+			setCondition(backupObj, status, storagev1alpha1.BackupConditionFailed, metav1.ConditionTrue, reason, err.Error())
+		} else {
+			completionTime := metav1.Now()
+			status.Phase = storagev1alpha1.BackupSucceeded
+			status.LastSuccessfulTime = &completionTime
+			if len(status.Targets) == 1 {
+				status.Message = fmt.Sprintf("Successfully take %d snapshot(s) of %s/%s", len(status.PVCs), status.ResourceType, status.ResourceName)
+			} else {
+				status.Message = fmt.Sprintf("Successfully take %d snapshot(s) of %d targets", len(status.PVCs), len(status.Targets))
+			}
+			setCondition(backupObj, status, storagev1alpha1.BackupConditionSucceeded, metav1.ConditionTrue, reasonBackupSucceeded, status.Message)
+			setCondition(backupObj, status, storagev1alpha1.BackupConditionFailed, metav1.ConditionFalse, reasonBackupSucceeded, "")
+		}
+		if err := patchStatus(backupObj, status); err != nil {
+			logger.Error(err)
+		}
+	}()
+
 	// ==============================
-	//  2. prepare pvc and pv metadata
+	//  2. resolve the resources to backup
 	// ==============================
-	begin = time.Now()
-	logger.Infof("Start backup %s/%s", backupFrom.Resource, backupFrom.Name)
-	pvcpvMap, err := constructPvcpvMap(ctx, backupObj)
+	targets, err := resolveTargets(backupObj)
 	if err != nil {
+		reason = reasonPrepareFailed
 		logger.Error(err)
 		return err
 	}
-	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully prepare pvc and pv metadata")
+	if len(targets) == 1 {
+		status.ResourceType = string(targets[0].Resource)
+		status.ResourceName = targets[0].Name
+	} else {
+		status.ResourceType = ""
+		status.ResourceName = ""
+	}
+	for _, storage := range ParseStorage(backupObj) {
+		status.Storage = append(status.Storage, string(storage))
+	}
 
 	// ==============================
-	// 3. backup to remote storage
+	//  3. backup every target to remote storage
 	// ==============================
-	for _, storage := range parseStorage(backupObj) {
-		begin := time.Now()
-		for pvc, meta := range pvcpvMap {
-			if err := backupFactory(storage)(backupObj, pvc, meta); err != nil {
-				err = errors.Wrapf(err, "Backup pvc/%s to %s failed", pvc, storage)
-				logger.Error(err)
-				return err
-			}
-			logger.WithField("cost", costedTime.String()).Infof("Successfully backup pvc/%s", pvc)
+	// The failure of one target doesn't stop backup other targets,
+	// the Backup is failed if any target failed.
+	var failed []string
+	for _, target := range targets {
+		targetStatus := storagev1alpha1.BackupTargetStatus{
+			Namespace: target.Namespace,
+			Name:      target.Name,
+			Resource:  target.Resource,
+			Phase:     storagev1alpha1.BackupSucceeded,
+		}
+		snapshots, targetReason, targetErr := r.backupTarget(targetBackup(backupObj, target), status)
+		targetStatus.Snapshots = snapshots
+		if targetErr != nil {
+			targetStatus.Phase = storagev1alpha1.BackupFailed
+			targetStatus.Error = targetErr.Error()
+			failed = append(failed, fmt.Sprintf("%s/%s/%s", target.Namespace, target.Resource, target.Name))
+			reason = targetReason
+			err = targetErr
+		}
+		status.Targets = append(status.Targets, targetStatus)
+		if err := patchStatus(backupObj, status); err != nil {
+			logger.Warn(err)
 		}
-		logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully backup all pvc to %s", storage)
 	}
+	if len(failed) != 0 {
+		if len(targets) != 1 {
+			err = fmt.Errorf("backup %d of %d target(s) failed: %s", len(failed), len(targets), strings.Join(failed, ", "))
+		}
+		return err
+	}
+	setCondition(backupObj, status, storagev1alpha1.BackupConditionRepositoryReady, metav1.ConditionTrue, reasonRepositoryReady, "")
 
-	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully backup %s/%s", backupFrom.Resource, backupFrom.Name)
+	logger.WithField("cost", time.Now().Sub(startTime.Time).String()).Infof("Successfully backup %d target(s)", len(targets))
 	return nil
 }
 
+// Run backups the persistentvolumeclaims of the only target defined in Backup.spec.backupFrom
+// to every storage, and append the result of every persistentvolumeclaim to status.
+// The Backup object is not required to exist in k8s and its status is not patched,
+// Migration constructs a Backup object to backup the persistentvolumeclaims of the
+// scaled down deployment/statefulset.
+func Run(ctx context.Context, backupObj *storagev1alpha1.Backup, status *storagev1alpha1.BackupStatus) error {
+	logger := logger.WithFields(logrus.Fields{
+		"name":      backupObj.GetName(),
+		"namespace": backupObj.GetNamespace(),
+	})
+	r := newBackupRun(logger)
+	defer r.cleanup()
+	_, _, err := r.backupTarget(backupObj, status)
+	return err
+}
+
+// backupTarget backup all persistentvolumeclaims of the only target defined in
+// targetObj.spec.backupFrom to every storage, the result of every persistentvolumeclaim
+// is appended to status. It returns the number of snapshots taken and the failure reason.
+//
+// The persistentvolumeclaims are backed up in lanes, see backupLanes, at most
+// Backup.spec.maxConcurrency lanes are executed concurrently. If any persistentvolumeclaim
+// failed, the lanes not started are skipped, and the first error is returned.
+func (r *backupRun) backupTarget(targetObj *storagev1alpha1.Backup, status *storagev1alpha1.BackupStatus) (snapshots int, reason string, err error) {
+	backupFrom := targetObj.Spec.BackupFrom
+	targetName := fmt.Sprintf("%s/%s", backupFrom.Resource, backupFrom.Name)
+	r = r.withLogger(r.logger.WithFields(logrus.Fields{
+		"target":    targetName,
+		"namespace": targetObj.GetNamespace(),
+	}))
+	// the pvc mounter pod only used by the current target.
+	defer func() {
+		if mounter := r.state.pvcMounter; mounter != nil {
+			r.deleteExecutor(*mounter)
+			r.state.pvcMounter = nil
+		}
+	}()
+
+	// ==============================
+	//  prepare pvc and pv metadata
+	// ==============================
+	begin := time.Now()
+	r.logger.Infof("Start backup %s", targetName)
+	var pvcpvMap map[string]pvdataMeta
+	if targetObj.Spec.Dump != nil {
+		pvcpvMap, err = constructDumpMap(targetObj)
+	} else {
+		pvcpvMap, err = r.constructPvcpvMap(ctx, targetObj)
+	}
+	if err != nil {
+		r.logger.Error(err)
+		return 0, reasonPrepareFailed, err
+	}
+	if targetObj.Spec.Manifests != nil {
+		if err = addManifests(targetObj, pvcpvMap); err != nil {
+			r.logger.Error(err)
+			return 0, reasonPrepareFailed, err
+		}
+	}
+	r.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully prepare pvc and pv metadata")
+
+	// ==============================
+	// execute pre backup hooks
+	// ==============================
+	// The post backup hooks are executed in the pods which pre backup hooks
+	// executed, whether backup succeeded or not, to unlock the application.
+	hookedPods, err := r.runPreHooks(targetObj, pvcpvMap)
+	defer func() {
+		for _, podObj := range hookedPods {
+			if hookErr := r.runHooks(targetObj, podObj, hookPhasePost); hookErr != nil {
+				r.logger.Error(hookErr)
+				if err == nil {
+					reason, err = reasonHookFailed, hookErr
+				}
+			}
+		}
+	}()
+	if err != nil {
+		r.logger.Error(err)
+		return 0, reasonHookFailed, err
+	}
+
+	// ==============================
+	// backup to remote storage
+	// ==============================
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed bool
+		sem    = make(chan struct{}, maxConcurrency(targetObj))
+	)
+	for _, lane := range backupLanes(targetObj, pvcpvMap) {
+		wg.Add(1)
+		go func(lane []backupTask) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			for _, task := range lane {
+				mu.Lock()
+				stop := failed
+				mu.Unlock()
+				if stop {
+					return
+				}
+				tr := r.withStorage(task.storage)
+				begin := time.Now()
+				pvcStatus := storagev1alpha1.BackupPVCStatus{
+					Name:      task.pvc,
+					Namespace: targetObj.GetNamespace(),
+					Target:    targetName,
+					Storage:   string(task.storage),
+				}
+				summary, taskErr := tr.backup(targetObj, task.pvc, task.meta)
+
+				mu.Lock()
+				if taskErr != nil {
+					taskErr = errors.Wrapf(taskErr, "Backup pvc/%s to %s failed", task.pvc, task.storage)
+					pvcStatus.Error = taskErr.Error()
+					status.PVCs = append(status.PVCs, pvcStatus)
+					tr.logger.Error(taskErr)
+					if !failed {
+						failed = true
+						err, reason = taskErr, reasonBackupFailed
+						if errors.Is(taskErr, errRepositoryNotReady) {
+							reason = reasonRepositoryNotReady
+							setCondition(targetObj, status, storagev1alpha1.BackupConditionRepositoryReady, metav1.ConditionFalse, reason, taskErr.Error())
+						}
+					}
+					mu.Unlock()
+					return
+				}
+				pvcStatus.Snapshot = summary.SnapshotID
+				pvcStatus.BytesAdded = summary.DataAdded
+				pvcStatus.FilesNew = int64(summary.FilesNew)
+				pvcStatus.FilesChanged = int64(summary.FilesChanged)
+				pvcStatus.Duration = &metav1.Duration{Duration: time.Duration(summary.TotalDuration * float64(time.Second))}
+				status.PVCs = append(status.PVCs, pvcStatus)
+				status.BytesAdded += summary.DataAdded
+				snapshots++
+				mu.Unlock()
+				tr.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully backup pvc/%s, snapshot %s saved", task.pvc, summary.SnapshotID)
+			}
+		}(lane)
+	}
+	wg.Wait()
+	if err != nil {
+		return snapshots, reason, err
+	}
+	r.logger.Infof("Successfully backup all pvc to %v", ParseStorage(targetObj))
+	return snapshots, "", nil
+}
+
 // The structured object for pv metadata is named pvdataMeta.
 // pvdataMeta.volumeSource:
 //   every persistentvolume(aka pv) has a backend valume, and the volme source
@@ -163,7 +404,7 @@ func Do(ctx context.Context, namespace, name string) error {
 //   value contains the pv backend volume source type, such as csi, "nfs", "rbd" or "hostPath".
 // pvdataMeta.nodeName:
 //   nodeName indicates the k8s node name that the pod is running. the nodeName
-//   is required by deployment/findpvdir to find the the persistentvolume data directory
+//   is required by the findpvdir job to find the the persistentvolume data directory
 //   path in the k8s node.
 // pvdataMeta.podName:
 //   The name of the deployment/statefulset/daemonset owned pod that we should to backup.
@@ -172,10 +413,17 @@ func Do(ctx context.Context, namespace, name string) error {
 //   The UID name of the deployment/statefulset/daemonset owned pod that we should to backup.
 //   To find the persistentvolume data directory path in k8s node requests it.
 // pvdataMeta.pvdir
-//   The persistentvolume data directory path in k8s node thtat found by deployment/findpvdir.
+//   The persistentvolume data directory path in k8s node thtat found by the findpvdir job.
 // pvdataMeta.pvname
 //   The persistentvolume claimed by persistentvolumeclaim for podis mounts.
 //   pod mounted pvc -> pvc claims pv -> k8s admin create pv manually or created by storageclass automatically.
+// pvdataMeta.volumeName
+//   The volume name in pod spec that refers to the persistentvolumeclaim.
+// pvdataMeta.podNamespace
+//   The namespace of the pod, the backup hooks are executed in the pod.
+// pvdataMeta.manifests
+//   The manifests of the backup target collected by pkg/manifest, it's only set
+//   for the manifests entry.
 type pvdataMeta struct {
 	volumeSource string
 	nodeName     string
@@ -183,27 +431,37 @@ type pvdataMeta struct {
 	podUID       string
 	pvdir        string
 	pvname       string
+	volumeName   string
+	podNamespace string
+	manifests    []byte
 }
 
-// constructPvcpvMap construct a map[string]pvdataMeta
-func constructPvcpvMap(ctx context.Context, backupObj *storagev1alpha1.Backup) (map[string]pvdataMeta, error) {
-	beginTime := time.Now().UTC()
-	defer func() {
-		costedTime = time.Now().UTC().Sub(beginTime)
-	}()
+// nodePath returns the path of the persistentvolume data in the k8s node.
+func (meta pvdataMeta) nodePath() string {
+	switch meta.volumeSource {
+	// if persistentvolume volume source is "hostPath" or "local", it's mean that
+	// the meta.pvdir is pvpath not pvdir, and pvpath = pvdir + pvname.
+	case types.VolumeHostPath, types.VolumeLocal:
+		return meta.pvdir
+	}
+	return filepath.Join(meta.pvdir, meta.pvname)
+}
 
+// constructPvcpvMap construct a map[string]pvdataMeta
+func (r *backupRun) constructPvcpvMap(ctx context.Context, backupObj *storagev1alpha1.Backup) (map[string]pvdataMeta, error) {
 	var (
 		err        error
 		podObjList []*corev1.Pod
 		namespace  = backupObj.GetNamespace()
 		backupFrom = backupObj.Spec.BackupFrom
+		// onlyPVC is the only persistentvolumeclaim to backup if backup from
+		// persistentvolumeclaim or persistentvolume, the other persistentvolumeclaims
+		// mounted by the same pod are ignored.
+		onlyPVC string
 	)
-	podHandler.ResetNamespace(namespace)
-	depHandler.ResetNamespace(namespace)
-	rsHandler.ResetNamespace(namespace)
-	stsHandler.ResetNamespace(namespace)
-	dsHandler.ResetNamespace(namespace)
-	pvcHandler.ResetNamespace(namespace)
+	// the handlers are shared by the concurrent backups, never change their namespace.
+	podHandler := podHandler.WithNamespace(namespace)
+	pvcHandler := pvcHandler.WithNamespace(namespace)
 	switch backupFrom.Resource {
 	case storagev1alpha1.PodResource:
 		podObj, err := podHandler.Get(backupFrom.Name)
@@ -216,34 +474,52 @@ func constructPvcpvMap(ctx context.Context, backupObj *storagev1alpha1.Backup) (
 		}
 		podObjList = append(podObjList, podObj)
 	case storagev1alpha1.DeploymentResource:
-		if podObjList, err = depHandler.GetPods(backupFrom.Name); err != nil {
+		if podObjList, err = depHandler.WithNamespace(namespace).GetPods(backupFrom.Name); err != nil {
 			if apierrors.IsNotFound(err) {
 				return nil, fmt.Errorf("deployment/%s not found in namespace %s, skip backup", backupFrom.Name, namespace)
 			}
 			return nil, errors.Wrap(err, "deployment handler get pod failed")
 		}
 	case storagev1alpha1.StatefulSetResource:
-		if podObjList, err = stsHandler.GetPods(backupFrom.Name); err != nil {
+		if podObjList, err = stsHandler.WithNamespace(namespace).GetPods(backupFrom.Name); err != nil {
 			if apierrors.IsNotFound(err) {
 				return nil, fmt.Errorf("statefulset/%s not found in namespace %s, skip backup", backupFrom.Name, namespace)
 			}
 			return nil, errors.Wrap(err, "statefulset handler get pod failed")
 		}
 	case storagev1alpha1.DaemonSetResource:
-		if podObjList, err = dsHandler.GetPods(backupFrom.Name); err != nil {
+		if podObjList, err = dsHandler.WithNamespace(namespace).GetPods(backupFrom.Name); err != nil {
 			if apierrors.IsNotFound(err) {
 				return nil, fmt.Errorf("daemonset/%s not found in namespace %s, skip backup", backupFrom.Name, namespace)
 			}
 			return nil, errors.Wrap(err, "daemonset handler get pod failed")
 		}
+	case storagev1alpha1.PersistentVolumeClaim, storagev1alpha1.PersistentVolume:
+		podObj, pvc, err := r.volumePod(backupObj)
+		if err != nil {
+			return nil, err
+		}
+		// the persistentvolume is not bound, find its data directory from the spec.
+		if podObj == nil {
+			meta, err := localVolumeMeta(backupFrom.Name)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]pvdataMeta{backupFrom.Name: meta}, nil
+		}
+		// the persistentvolumeclaim bound to the persistentvolume may be in other namespace.
+		podHandler = podHandler.WithNamespace(podObj.GetNamespace())
+		pvcHandler = pvcHandler.WithNamespace(podObj.GetNamespace())
+		podObjList = append(podObjList, podObj)
+		onlyPVC = pvc
 	default:
-		return nil, errors.New("Backup.spec.backupFrom.resource field value must be pod, deployment, statefulset or daemonset")
+		return nil, errors.New("Backup.spec.backupFrom.resource field value must be pod, deployment, statefulset, daemonset, persistentvolumeclaim or persistentvolume")
 	}
 	// podObjList contains all pods that managed/owned by the Deployment, StatefulSet or DaemonSet.
 	// we iterate over each pod to get its mounted persistentvolumeclaim(aka pvc),
 	// and the pvc as the map key, persistentvolume(aka pv) metadata as the value.
 	// pv metadata is a structured object that contains necessary info for
-	// deployment/findpvdir to find the pv data directory in the k8s node,
+	// the findpvdir job to find the pv data directory in the k8s node,
 	// and for deployment/backuptonfs to create a pod to backup the pv data to nfs server.
 	//
 	// restic command to backup persistentvolume data to remote storage(nfs/minio/s3, etc.) should
@@ -283,44 +559,58 @@ func constructPvcpvMap(ctx context.Context, backupObj *storagev1alpha1.Backup) (
 		if err != nil {
 			return nil, errors.Wrap(err, "pod handler get persistentvolumeclaim failed")
 		}
-		logger.Debugf("The persistentvolumeclaims mounted by pod/%s are: %v", podObj.Name, pvcList)
+		if len(onlyPVC) != 0 {
+			pvcList = []string{onlyPVC}
+		} else {
+			pvcList = filterPVCs(backupObj, podObj, pvcList)
+		}
+		volumes := pvcVolumes(podObj)
+		r.logger.Debugf("The persistentvolumeclaims mounted by pod/%s are: %v", podObj.Name, pvcList)
 		for _, pvc := range pvcList {
 			// get the persistentvolume name claimed by persistentvolumeclaim resource.
 			pvname, err := pvcHandler.GetPV(pvc)
 			if err != nil {
-				logger.Errorf("pvc handler get pv failed: %s", err.Error())
+				r.logger.Errorf("pvc handler get pv failed: %s", err.Error())
 				continue
 			}
 			// get the persistentvolume backend volume type, such as "nfs", "csi", "hostPath", "local", etc.
 			volumeSource, err := pvHandler.GetVolumeSource(pvname)
 			if err != nil {
-				logger.Errorf("pv handler get volume source failed: %s", err.Error())
+				r.logger.Errorf("pv handler get volume source failed: %s", err.Error())
 				continue
 			}
 			meta.volumeSource = volumeSource
 			meta.nodeName = nodeName
 			meta.podName = podObj.GetName()
+			meta.podNamespace = podObj.GetNamespace()
 			meta.podUID = podUID
 			meta.pvname = pvname
+			meta.volumeName = volumes[pvc]
 			pvcpvMap[pvc] = meta
 		}
-		// 3. create deployment/findpvdir to find the persistentvolume data directory in k8s node that mounted by pod.
-		// the deployment should meet three condition:
-		//   1.deployment should mount the k8s node root direcotry(is "/", not "/root")
-		//   2.deployment usually deploy in the same namespace to operator
-		//   3.deployment.spec.template.spec.nodeName should same to the pod,
+		// the persistentvolumeclaims are backed up from the VolumeSnapshot, the data
+		// directory in k8s node is not required.
+		if backupObj.Spec.VolumeSnapshot != nil {
+			continue
+		}
+		// 3. run the findpvdir job to find the persistentvolume data directory in k8s node that mounted by pod.
+		// the job should meet three condition:
+		//   1.job should mount the k8s node root direcotry(is "/", not "/root")
+		//   2.job usually runs in the same namespace to operator
+		//   3.job.spec.template.spec.nodeName should same to the pod,
 		var pvdir string
 		for _, pvc := range pvcList {
 			meta := pvcpvMap[pvc]
-			if pvdir, err = createFindpvdirDeployment(backupObj, meta); err != nil {
-				return nil, fmt.Errorf("create deployment/%s failed: %s", findpvdirName+"-"+meta.nodeName, err.Error())
+			begin := time.Now()
+			if pvdir, err = r.runFindpvdirJob(backupObj, meta); err != nil {
+				return nil, fmt.Errorf("run the findpvdir job on node %s failed: %s", meta.nodeName, err.Error())
 			}
-			logger.WithField("cost", costedTime.String()).Infof("Found pvc/%s in pod/%s", pvc, podObj.GetName())
+			r.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Found pvc/%s in pod/%s", pvc, podObj.GetName())
 			if len(pvdir) == 0 {
-				logger.WithField("VolumeSource", meta.volumeSource).Warnf("PVC/%s data directory not found", pvc)
+				r.logger.WithField("VolumeSource", meta.volumeSource).Warnf("PVC/%s data directory not found", pvc)
 				continue
 			}
-			logger.Debugf("The persistentvolume dir: %s", pvdir)
+			r.logger.Debugf("The persistentvolume dir: %s", pvdir)
 			meta.pvdir = pvdir
 			pvcpvMap[pvc] = meta
 		}
@@ -328,11 +618,11 @@ func constructPvcpvMap(ctx context.Context, backupObj *storagev1alpha1.Backup) (
 	// If the length of pvcpvMap is zero, it's means that no persistentvolumeclaim mounted
 	// by the backup target resource, skip backup.
 	if len(pvcpvMap) == 0 {
-		return nil, fmt.Errorf("There is no pvc mounted by the %s/%s, skip backup", backupFrom.Resource, backupFrom.Name)
+		return nil, fmt.Errorf("There is no pvc mounted by the %s/%s or all pvc are filtered out, skip backup", backupFrom.Resource, backupFrom.Name)
 	}
 	// output pvcpvMap for debug
 	for pvc, meta := range pvcpvMap {
-		logger.Debugf("%v: %v", pvc, meta)
+		r.logger.Debugf("%v: %v", pvc, meta)
 	}
 
 	return pvcpvMap, nil
@@ -1 +1,76 @@
 package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// backup2cephfsDeployment renders the deployment that run restic command against
+// the restic repository on cephfs, the cephfs is mounted as the restic repository
+// like nfs, and the key ring is read from the secret Backup.spec.backupTo.cephfs.secretRef
+// or Backup.spec.backupTo.cephfs.credentialName.
+func backup2cephfsDeployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
+	cephfs := backupObj.Spec.BackupTo.CephFS
+	if len(cephfs.Monitors) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.cephfs.monitors is empty")
+	}
+	keyringName := cephfs.SecretRef
+	if len(keyringName) == 0 {
+		keyringName = cephfs.CredentialName
+	}
+	if len(keyringName) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.cephfs.secretRef and Backup.spec.backupTo.cephfs.credentialName are both empty")
+	}
+	keyringName, secObj, err := syncCredential(backupObj, backup2cephfsName, keyringName, cephfs.CredentialNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(secObj.Data[cephfsKeyringKey]) == 0 {
+		return nil, fmt.Errorf("secret/%s should contain %s", secObj.GetName(), cephfsKeyringKey)
+	}
+	user := cephfs.User
+	if len(user) == 0 {
+		user = defaultCephfsUser
+	}
+	path := cephfs.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	// render the monitors as yaml flow sequence.
+	monitors, err := json.Marshal(cephfs.Monitors)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal cephfs monitors failed")
+	}
+
+	operatorNamespace := util.GetOperatorNamespace()
+	return []byte(fmt.Sprintf(
+		// the deployment template
+		template.TemplateBackup2cephfs,
+		// deployment.metadata.name
+		// deployment.metadata.namespace
+		// deployment name, deployment namespace
+		name, operatorNamespace,
+		// deployment.spec.template.metadata.annotations
+		// pod template annotations
+		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
+		// deployment.spec.template.spec.nodeName
+		// deployment.spec.template.spec.containers.image
+		// node name, deployment image
+		nodeName, backup2cephfsImage,
+		// deployment.spec.template.spec.containers.env
+		// the environment variables passed to pods
+		backupObj.Spec.TimeZone, types.StorageCephFS, resticRepo,
+		// restic repository mount path
+		// deployment.spec.template.containers.env
+		backupObj.Spec.CredentialName, resticRepo, cephfs.ReadOnly,
+		// deployment.spec.template.volumes
+		// the volumes mounted by pod
+		string(monitors), path, user, keyringName, cephfs.ReadOnly)), nil
+}
@@ -11,16 +11,12 @@ import (
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/horus-operator/pkg/util"
 	"github.com/pkg/errors"
-	corev1 "k8s.io/api/core/v1"
 )
 
-// createBackup2minioDepoyment create a deployment to backup persistentvolume data to minio object storage
-func createBackup2minioDepoyment(backupObj *storagev1alpha1.Backup, meta pvdataMeta) (*corev1.Pod, error) {
-	beginTime := time.Now().UTC()
-	defer func() {
-		costedTime = time.Now().UTC().Sub(beginTime)
-	}()
-
+// backup2minioDeployment renders the deployment that run restic command against
+// the restic repository on minio object storage, the minio bucket and folder
+// will be created if not exist.
+func backup2minioDeployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
 	scheme := backupObj.Spec.BackupTo.MinIO.Endpoint.Scheme
 	address := backupObj.Spec.BackupTo.MinIO.Endpoint.Address
 	port := backupObj.Spec.BackupTo.MinIO.Endpoint.Port
@@ -29,8 +25,7 @@ func createBackup2minioDepoyment(backupObj *storagev1alpha1.Backup, meta pvdataM
 	credentialName := backupObj.Spec.CredentialName
 
 	operatorNamespace := util.GetOperatorNamespace()
-	secHandler.ResetNamespace(operatorNamespace)
-	secObj, err := secHandler.Get(backupObj.Spec.CredentialName)
+	secObj, err := secHandler.WithNamespace(operatorNamespace).Get(backupObj.Spec.CredentialName)
 	if err != nil {
 		return nil, errors.Wrap(err, "secret handler get secret failed")
 	}
@@ -51,29 +46,23 @@ func createBackup2minioDepoyment(backupObj *storagev1alpha1.Backup, meta pvdataM
 		return nil, errors.Wrap(err, "make minio folder failed")
 	}
 
-	DeployNameBackup2minio = theDeployName(backup2minioName, backupObj, meta)
-	backup2minioBytes := []byte(fmt.Sprintf(
+	return []byte(fmt.Sprintf(
 		// the deployment template
 		template.TemplateBackup2minio,
 		// deployment.metadata.name
 		// deployment.metadata.namespace
 		// deployment name, deployment namespace
-		DeployNameBackup2minio, operatorNamespace,
+		name, operatorNamespace,
 		// deployment.spec.template.metadata.annotations
 		// pod template annotations
 		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
 		// deployment.spec.template.spec.nodeName
 		// deployment.spec.template.spec.containers.image
 		// node name, deployment image
-		meta.nodeName, backup2minioImage,
+		nodeName, backup2minioImage,
 		// deployment.spec.template.spec.containers.env
 		// the environment variables passed to pods
 		backupObj.Spec.TimeZone, types.StorageMinIO, resticRepo,
 		credentialName, credentialName, credentialName, credentialName, credentialName,
-	))
-	podObj, err := filterRunningPod(operatorNamespace, backup2minioBytes)
-	if err != nil {
-		return nil, err
-	}
-	return podObj, nil
+	)), nil
 }
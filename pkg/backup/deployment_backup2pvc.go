@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// backup2pvcDeployment renders the deployment that run restic command against
+// the restic repository on the persistentvolumeclaim, the persistentvolumeclaim
+// will be created in the operator namespace if not exist.
+//
+// The ReadWriteOnce persistentvolumeclaim can only be mounted on one k8s node,
+// the deployment is pinned to the k8s node where the persistentvolumeclaim is
+// attached if nodeName is empty, otherwise wait for the persistentvolumeclaim
+// to be released by the pods on other k8s nodes.
+func backup2pvcDeployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
+	pvcObj, err := ensureRepositoryPVC(backupObj)
+	if err != nil {
+		return nil, err
+	}
+	if !isReadWriteMany(pvcObj) {
+		attachedNode, err := waitRepositoryReleased(pvcObj.GetName(), name, nodeName)
+		if err != nil {
+			return nil, err
+		}
+		if len(nodeName) == 0 {
+			nodeName = attachedNode
+		}
+	}
+
+	operatorNamespace := util.GetOperatorNamespace()
+	return []byte(fmt.Sprintf(
+		// the deployment template
+		template.TemplateBackup2pvc,
+		// deployment.metadata.name
+		// deployment.metadata.namespace
+		// deployment name, deployment namespace
+		name, operatorNamespace,
+		// deployment.spec.template.metadata.annotations
+		// pod template annotations
+		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
+		// deployment.spec.template.spec.nodeName
+		// deployment.spec.template.spec.containers.image
+		// node name, deployment image
+		nodeName, backup2pvcImage,
+		// deployment.spec.template.spec.containers.env
+		// the environment variables passed to pods
+		backupObj.Spec.TimeZone, types.StoragePVC, resticRepo,
+		// restic repository mount path
+		// deployment.spec.template.containers.env
+		backupObj.Spec.CredentialName, resticRepo,
+		// deployment.spec.template.volumes
+		// the volumes mounted by pod
+		pvcObj.GetName())), nil
+}
+
+// ensureRepositoryPVC creates the persistentvolumeclaim defined in Backup.spec.backupTo.pvc
+// in the operator namespace if not exist, the existing persistentvolumeclaim is adopted.
+func ensureRepositoryPVC(backupObj *storagev1alpha1.Backup) (*corev1.PersistentVolumeClaim, error) {
+	claim := backupObj.Spec.BackupTo.PVC.PersistentVolumeClaim
+	if len(claim.GetName()) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.pvc.persistentVolumeClaim.metadata.name is empty")
+	}
+	operatorNamespace := util.GetOperatorNamespace()
+	handler := pvcHandler.WithNamespace(operatorNamespace)
+	pvcObj, err := handler.Get(claim.GetName())
+	if err == nil {
+		return pvcObj, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", claim.GetName())
+	}
+
+	pvcObj = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        claim.GetName(),
+			Namespace:   operatorNamespace,
+			Labels:      claim.GetLabels(),
+			Annotations: claim.GetAnnotations(),
+		},
+		Spec: *claim.Spec.DeepCopy(),
+	}
+	if len(pvcObj.Spec.AccessModes) == 0 {
+		pvcObj.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	util.SetRecommendedLabels(pvcObj)
+	if pvcObj, err = handler.Create(pvcObj); err != nil {
+		return nil, errors.Wrapf(err, "persistentvolumeclaim handler create pvc/%s failed", claim.GetName())
+	}
+	logger.Infof("Created pvc/%s as restic repository", pvcObj.GetName())
+	return pvcObj, nil
+}
+
+// isReadWriteMany returns true if the persistentvolumeclaim can be mounted on many k8s nodes.
+func isReadWriteMany(pvcObj *corev1.PersistentVolumeClaim) bool {
+	for _, mode := range pvcObj.Spec.AccessModes {
+		if mode == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
+
+// waitRepositoryReleased returns the k8s node where the persistentvolumeclaim is
+// attached by the pods in the operator namespace, the pods of the deployment
+// named name are ignored.
+// If nodeName is not empty, it waits until the persistentvolumeclaim is not
+// attached or attached on the k8s node nodeName.
+func waitRepositoryReleased(pvc, name, nodeName string) (string, error) {
+	var attachedNode string
+	if err := wait.PollImmediate(2*time.Second, repositoryReleaseTimeout, func() (bool, error) {
+		var err error
+		if attachedNode, err = repositoryNode(pvc, name); err != nil {
+			return false, err
+		}
+		return len(nodeName) == 0 || len(attachedNode) == 0 || attachedNode == nodeName, nil
+	}); err != nil {
+		return "", fmt.Errorf("pvc/%s is ReadWriteOnce and attached on node %s, can't be mounted on node %s", pvc, attachedNode, nodeName)
+	}
+	return attachedNode, nil
+}
+
+// repositoryNode returns the k8s node where the persistentvolumeclaim is mounted by the
+// pods in the operator namespace, the terminating pods are counted because they
+// hold the volume until they are deleted.
+func repositoryNode(pvc, name string) (string, error) {
+	podObjs, err := podHandler.WithNamespace(util.GetOperatorNamespace()).List()
+	if err != nil {
+		return "", errors.Wrap(err, "pod handler list pods failed")
+	}
+	for _, podObj := range podObjs {
+		if len(podObj.Spec.NodeName) == 0 || strings.HasPrefix(podObj.GetName(), name+"-") {
+			continue
+		}
+		if podObj.Status.Phase == corev1.PodSucceeded || podObj.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range podObj.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc {
+				return podObj.Spec.NodeName, nil
+			}
+		}
+	}
+	return "", nil
+}
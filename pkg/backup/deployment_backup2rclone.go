@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// backup2rcloneDeployment renders the deployment that run restic command against
+// the restic repository on rclone remote, the repository is "rclone:remote:path".
+// restic starts "rclone serve restic --stdio" to access the remote, so the
+// rclone binary is required in the image, and the rclone config is mounted
+// from the secret Backup.spec.backupTo.rclone.credentialName.
+func backup2rcloneDeployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
+	rclone := backupObj.Spec.BackupTo.Rclone
+	remote := strings.TrimSuffix(rclone.Address, ":")
+	if len(remote) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.rclone.address is empty")
+	}
+	if len(rclone.CredentialName) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.rclone.credentialName is empty")
+	}
+	rcloneCredentialName, secObj, err := syncCredential(backupObj, backup2rcloneName, rclone.CredentialName, rclone.CredentialNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(secObj.Data[rcloneConfigKey]) == 0 {
+		return nil, fmt.Errorf("secret/%s should contain %s", rclone.CredentialName, rcloneConfigKey)
+	}
+	resticRepo := fmt.Sprintf("rclone:%s:%s", remote, rclone.Path)
+
+	operatorNamespace := util.GetOperatorNamespace()
+	return []byte(fmt.Sprintf(
+		// the deployment template
+		template.TemplateBackup2rclone,
+		// deployment.metadata.name
+		// deployment.metadata.namespace
+		// deployment name, deployment namespace
+		name, operatorNamespace,
+		// deployment.spec.template.metadata.annotations
+		// pod template annotations
+		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
+		// deployment.spec.template.spec.nodeName
+		// deployment.spec.template.spec.containers.image
+		// node name, deployment image
+		nodeName, backup2rcloneImage,
+		// deployment.spec.template.spec.containers.env
+		// the environment variables passed to pods
+		backupObj.Spec.TimeZone, types.StorageRClone, resticRepo,
+		backupObj.Spec.CredentialName, filepath.Join(rcloneConfigPath, rcloneConfigKey),
+		// rclone config mount path
+		rcloneConfigPath,
+		// deployment.spec.template.volumes
+		// the volumes mounted by pod
+		rcloneCredentialName)), nil
+}
@@ -1 +1,75 @@
 package backup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// backup2restserverDeployment renders the deployment that run restic command against
+// the restic repository on rest server, the repository will be created by `restic init`.
+//
+// The restic repository url is "rest:http(s)://host:port/path", the username and
+// password are not in the url, restic reads them from the environment variables
+// RESTIC_REST_USERNAME and RESTIC_REST_PASSWORD referencing the credential secret,
+// so they're neither rendered into the deployment nor required to be url-encoded.
+func backup2restserverDeployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
+	restServer := backupObj.Spec.BackupTo.RestServer
+	if len(restServer.Address) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.restServer.address is empty")
+	}
+	scheme := restServer.Scheme
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+	port := restServer.Port
+	if port == 0 {
+		port = defaultRestServerPort
+	}
+
+	// the rest server started with --no-auth doesn't need credential, the credential
+	// environment variables reference the optional keys of Backup.spec.credentialName.
+	credentialName := backupObj.Spec.CredentialName
+	restCredentialName := credentialName
+	if len(restServer.CredentialName) != 0 {
+		var secObj *corev1.Secret
+		var err error
+		if restCredentialName, secObj, err = syncCredential(backupObj, backup2restserverName, restServer.CredentialName, restServer.CredentialNamespace); err != nil {
+			return nil, err
+		}
+		if len(secObj.Data[envRestUsername]) == 0 || len(secObj.Data[envRestPassword]) == 0 {
+			return nil, fmt.Errorf("secret/%s should contain %s and %s", restServer.CredentialName, envRestUsername, envRestPassword)
+		}
+	}
+	resticRepo := fmt.Sprintf("rest:%s://%s:%d/%s", scheme, restServer.Address, port, strings.TrimPrefix(restServer.Path, "/"))
+
+	operatorNamespace := util.GetOperatorNamespace()
+	return []byte(fmt.Sprintf(
+		// the deployment template
+		template.TemplateBackup2restserver,
+		// deployment.metadata.name
+		// deployment.metadata.namespace
+		// deployment name, deployment namespace
+		name, operatorNamespace,
+		// deployment.spec.template.metadata.annotations
+		// pod template annotations
+		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
+		types.AnnotationInsecureTLS, restServer.InsecureTLSSkipVerify,
+		types.AnnotationAppendOnly, restServer.AppendOnly,
+		// deployment.spec.template.spec.nodeName
+		// deployment.spec.template.spec.containers.image
+		// node name, deployment image
+		nodeName, backup2restserverImage,
+		// deployment.spec.template.spec.containers.env
+		// the environment variables passed to pods
+		backupObj.Spec.TimeZone, types.StorageRestServer,
+		restCredentialName, restCredentialName, resticRepo, credentialName,
+	)), nil
+}
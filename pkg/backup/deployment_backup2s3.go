@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/minio"
+	"github.com/forbearing/horus-operator/pkg/template"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// backup2s3Deployment renders the deployment that run restic command against
+// the restic repository on S3 object storage, the bucket will be created if
+// not exist.
+//
+// The AWS credential is read from the secret Backup.spec.backupTo.s3.credentialName
+// in Backup.spec.backupTo.s3.credentialNamespace, and the restic repository
+// password is read from the secret Backup.spec.credentialName as other storages.
+func backup2s3Deployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
+	s3 := backupObj.Spec.BackupTo.S3
+	if len(s3.Bucket) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.s3.bucket is empty")
+	}
+	if len(s3.CredentialName) == 0 {
+		return nil, errors.New("Backup.spec.backupTo.s3.credentialName is empty")
+	}
+
+	operatorNamespace := util.GetOperatorNamespace()
+	s3CredentialName, secObj, err := syncCredential(backupObj, backup2s3Name, s3.CredentialName, s3.CredentialNamespace)
+	if err != nil {
+		return nil, err
+	}
+	accessKey := string(secObj.Data[envAwsAccessKeyID])
+	secretKey := string(secObj.Data[envAwsSecretAccessKey])
+	sessionToken := string(secObj.Data[envAwsSessionToken])
+	if len(accessKey) == 0 || len(secretKey) == 0 {
+		return nil, fmt.Errorf("secret/%s should contain %s and %s", s3.CredentialName, envAwsAccessKeyID, envAwsSecretAccessKey)
+	}
+
+	endpoint, secure := s3Endpoint(s3.Endpoint)
+	scheme := "https"
+	if !secure {
+		scheme = "http"
+	}
+	resticRepo := fmt.Sprintf("s3:%s://%s/%s", scheme, endpoint, s3.Bucket)
+	if folder := strings.Trim(s3.Folder, "/"); len(folder) != 0 {
+		resticRepo = resticRepo + "/" + folder
+	}
+	// create s3 bucket
+	client, err := minio.NewS3(endpoint, accessKey, secretKey, sessionToken, s3.Region, secure, s3.InsecureTLSSkipVerify)
+	if err != nil {
+		return nil, errors.Wrap(err, "create s3 client failed")
+	}
+	if err := minio.EnsureBucket(client, s3.Bucket, s3.Region); err != nil {
+		return nil, errors.Wrapf(err, "make s3 bucket %s failed", s3.Bucket)
+	}
+
+	credentialName := backupObj.Spec.CredentialName
+	return []byte(fmt.Sprintf(
+		// the deployment template
+		template.TemplateBackup2s3,
+		// deployment.metadata.name
+		// deployment.metadata.namespace
+		// deployment name, deployment namespace
+		name, operatorNamespace,
+		// deployment.spec.template.metadata.annotations
+		// pod template annotations
+		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
+		types.AnnotationInsecureTLS, s3.InsecureTLSSkipVerify,
+		// deployment.spec.template.spec.nodeName
+		// deployment.spec.template.spec.containers.image
+		// node name, deployment image
+		nodeName, backup2s3Image,
+		// deployment.spec.template.spec.containers.env
+		// the environment variables passed to pods
+		backupObj.Spec.TimeZone, types.StorageS3, resticRepo,
+		credentialName, s3CredentialName, s3CredentialName, s3CredentialName,
+		s3.Region,
+	)), nil
+}
+
+// s3Endpoint returns the host of the S3 endpoint and whether to connect it with https.
+// The endpoint defaults to AWS S3 and the scheme defaults to https.
+func s3Endpoint(endpoint string) (string, bool) {
+	secure := true
+	switch {
+	case strings.HasPrefix(endpoint, "http://"):
+		secure = false
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if len(endpoint) == 0 {
+		endpoint = defaultS3Endpoint
+	}
+	return endpoint, secure
+}
@@ -9,19 +9,14 @@ import (
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/horus-operator/pkg/util"
 	"github.com/pkg/errors"
-	corev1 "k8s.io/api/core/v1"
 )
 
-// createBackup2sftpDeployment create a deployment to backup persistentvolume data to sftp server.
-func createBackup2sftpDeployment(backupObj *storagev1alpha1.Backup, meta pvdataMeta) (*corev1.Pod, error) {
-	beginTime := time.Now().UTC()
-	defer func() {
-		costedTime = time.Now().UTC().Sub(beginTime)
-	}()
-
+// backup2sftpDeployment renders the deployment that run restic command against
+// the restic repository on sftp server, the repository directory will be created
+// if not exist.
+func backup2sftpDeployment(backupObj *storagev1alpha1.Backup, name, nodeName string) ([]byte, error) {
 	operatorNamespace := util.GetOperatorNamespace()
-	secHandler.ResetNamespace(operatorNamespace)
-	secObj, err := secHandler.Get(backupObj.Spec.CredentialName)
+	secObj, err := secHandler.WithNamespace(operatorNamespace).Get(backupObj.Spec.CredentialName)
 	if err != nil {
 		return nil, errors.Wrap(err, "secret handler get secret failed")
 	}
@@ -43,30 +38,24 @@ func createBackup2sftpDeployment(backupObj *storagev1alpha1.Backup, meta pvdataM
 		return nil, errors.Wrap(err, "mkdir on sftp server failed")
 	}
 
-	DeployNameBackup2sftp = theDeployName(backup2sftpName, backupObj, meta)
 	credentialName := backupObj.Spec.CredentialName
-	backup2sftpBytes := []byte(fmt.Sprintf(
+	return []byte(fmt.Sprintf(
 		// the deployment template
 		template.TemplateBackup2sftp,
 		// deployment.metadata.name
 		// deployment.metadata.namespace
 		// deployment name, deployment namespace
-		DeployNameBackup2sftp, operatorNamespace,
+		name, operatorNamespace,
 		// deployment.spec.template.metadata.annotations
 		// pod template annotations
 		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
 		// deployment.spec.template.spec.nodeName
 		// deployment.spec.template.spec.containers.image
 		// node name, deployment image
-		meta.nodeName, backup2sftpImage,
+		nodeName, backup2sftpImage,
 		// deployment.spec.template.spec.containers.env
 		// the environment variables passed to pods
 		backupObj.Spec.TimeZone, types.StorageSFTP, resticRepo,
 		credentialName, credentialName, credentialName,
-	))
-	podObj, err := filterRunningPod(operatorNamespace, backup2sftpBytes)
-	if err != nil {
-		return nil, err
-	}
-	return podObj, nil
+	)), nil
 }
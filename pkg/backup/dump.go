@@ -0,0 +1,24 @@
+package backup
+
+import (
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/dump"
+)
+
+// constructDumpMap construct the map like constructPvcpvMap when backup the database
+// by the dump tool, the only key is the dump key, and the value contains the pod to
+// execute the dump tool in. The executor is scheduled to the k8s node of the pod.
+func constructDumpMap(backupObj *storagev1alpha1.Backup) (map[string]pvdataMeta, error) {
+	backupFrom := backupObj.Spec.BackupFrom
+	podObj, err := dump.Pod(backupObj.GetNamespace(), backupFrom.Resource, backupFrom.Name)
+	if err != nil {
+		return nil, err
+	}
+	meta := pvdataMeta{
+		nodeName:     podObj.Spec.NodeName,
+		podName:      podObj.GetName(),
+		podUID:       string(podObj.GetUID()),
+		podNamespace: podObj.GetNamespace(),
+	}
+	return map[string]pvdataMeta{dump.Key(backupObj.Spec.Dump.Type): meta}, nil
+}
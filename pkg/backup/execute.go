@@ -1,73 +1,249 @@
 package backup
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/dump"
+	"github.com/forbearing/horus-operator/pkg/restic"
 	"github.com/forbearing/horus-operator/pkg/types"
-	"github.com/forbearing/horus-operator/pkg/util"
 	res "github.com/forbearing/restic"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
+var (
+	// errRepositoryNotReady indicates the restic repository is not accessible or can't be initialized.
+	errRepositoryNotReady = errors.New("restic repository not ready")
+	// errSummaryNotFound indicates restic didn't report the summary of `restic backup --json`.
+	errSummaryNotFound = errors.New("summary not found in the output of restic backup")
+)
+
 // executeBackupCommand
 // clusterName as the argument of flag --host.
-func executeBackupCommand(backupObj *storagev1alpha1.Backup, execPod *corev1.Pod, pvc string, meta pvdataMeta) error {
-	beginTime := time.Now().UTC()
-	defer func() {
-		costedTime = time.Now().UTC().Sub(beginTime)
-	}()
-
+// It returns the summary of `restic backup --json`, which contains the snapshot id and the bytes added.
+func (r *backupRun) executeBackupCommand(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, pvc string, meta pvdataMeta) (*restic.NodeBackupSummary, error) {
 	if len(meta.pvdir) == 0 {
-		return errors.New("persistentvolume directory is empty, skip backup")
+		return nil, errors.New("persistentvolume directory is empty, skip backup")
 	}
 	if len(meta.pvname) == 0 {
-		return errors.New("persistentvolume name is empty, skip backup")
-	}
-	clusterName := backupObj.Spec.Cluster
-	if len(clusterName) == 0 {
-		clusterName = types.DefaultClusterName
-	}
-
-	pvpath := filepath.Join(mountHostRootPath, meta.pvdir, meta.pvname)
-	switch meta.volumeSource {
-	// if persistentvolume volume source is "hostPath" or "local", it's mean that
-	// the meta.pvdir is pvpath not pvdir, and pvpath = pvdir + pvname.
-	case types.VolumeHostPath, types.VolumeLocal:
-		pvpath = filepath.Join(mountHostRootPath, meta.pvdir)
-	}
-	logger.Debugf("the path of persistentvolume data in k8s node: %s", pvpath)
-	logger.Debugf("executing restic command to backup persistentvolume data within pod/%s", execPod.GetName())
-	r := res.NewIgnoreNotFound(context.TODO(), &res.GlobalFlags{NoCache: true})
-	tags := []string{string(backupObj.Spec.BackupFrom.Resource), backupObj.Namespace, backupObj.Spec.BackupFrom.Name, pvc}
-	cmdCheckRepo := r.Command(res.List{}.SetArgs("keys")).String()
-	cmdInitRepo := r.Command(res.Init{}).String()
-	cmdBackup := r.Command(res.Backup{Tag: tags, Host: clusterName}.SetArgs(pvpath)).String()
-
-	operatorNamespace := util.GetOperatorNamespace()
-	podHandler.ResetNamespace(operatorNamespace)
+		return nil, errors.New("persistentvolume name is empty, skip backup")
+	}
+
+	pvpath := filepath.Join(mountHostRootPath, meta.nodePath())
+	r.logger.Debugf("the path of persistentvolume data in k8s node: %s", pvpath)
+	return r.resticBackup(backupObj, deployObj, pvc, meta.volumeName, pvpath)
+}
+
+// resticBackup runs `restic backup` in the executor job to backup the directory pvpath,
+// and applies the retention policy after backup succeeded. The job is converted from
+// the executor deployment deployObj.
+// volume is the volume name in pod spec refers to the persistentvolumeclaim.
+func (r *backupRun) resticBackup(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, pvc, volume, pvpath string) (*restic.NodeBackupSummary, error) {
+	clusterName := snapshotHost(backupObj)
+	tags := snapshotTags(backupObj, pvc)
+	executor := &deployObj.Spec.Template
+	rc := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(executor, false))
+	rj := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(executor, true))
+	cmdCheckRepo := shellQuote(strings.Split(rc.Command(res.List{}.SetArgs("keys")).String(), " "))
+	cmdInitRepo := shellQuote(strings.Split(rc.Command(res.Init{}).String(), " "))
+	cmdBackup := rj.Command(res.Backup{Tag: tags, Host: clusterName}.SetArgs(pvpath)).String()
+
+	// the exclude flags are inserted before the backup path, which is the last argument.
+	argsBackup := strings.Split(cmdBackup, " ")
+	if excludes := excludeArgs(backupObj, pvc, volume, pvpath); len(excludes) != 0 {
+		last := len(argsBackup) - 1
+		argsBackup = append(append(argsBackup[:last:last], excludes...), argsBackup[last])
+		r.logger.Debugf("restic backup excludes: %v", excludes)
+	}
+	r.logger.Debug(strings.Join(argsBackup, " "))
+	// The job inits the restic repository if `restic list keys` failed, the repository
+	// may be initialized by the concurrent jobs at the same time, so check it again
+	// if `restic init` failed. The output of `restic backup --json` is written to
+	// the job logs to report the progress, and the summary message, which is the
+	// last line of the output, is written to the termination message of the job.
+	// restic exits with 3 if the snapshot is saved but some files can't be read.
+	command := shellCommand(
+		fmt.Sprintf("export RESTIC_PROGRESS_FPS=%s", progressFPS),
+		fmt.Sprintf("%s >/dev/null 2>&1 || %s >/dev/null || %s >/dev/null 2>&1 || exit %d",
+			cmdCheckRepo, cmdInitRepo, cmdCheckRepo, exitRepositoryNotReady),
+		fmt.Sprintf("{ %s; echo $? >/tmp/restic-backup.rc; } | tee /tmp/restic-backup.json", shellQuote(argsBackup)),
+		"rc=$(cat /tmp/restic-backup.rc)",
+		`if [ "$rc" != 0 ] && [ "$rc" != 3 ]; then exit "$rc"; fi`,
+		"tail -n 1 /tmp/restic-backup.json >/dev/termination-log",
+	)
+
+	// `restic backup` only requires the shared repository lock, the backups to the
+	// same storage run concurrently, but not with `restic forget --prune`.
+	progress := newBackupProgress(r.logger, "pvc/"+pvc)
+	lock := r.repoLock()
+	lock.RLock()
+	message, err := r.runJob(executorJob(backupObj, deployObj, command), progress)
+	lock.RUnlock()
+	progress.Close()
+	if err != nil {
+		// the restic errors parsed from the job logs are more readable than the
+		// termination message, which is the tail of the job logs.
+		if stderr := progress.Stderr(); len(stderr) != 0 {
+			if errors.Is(err, errRepositoryNotReady) {
+				err = errors.Wrap(errRepositoryNotReady, stderr)
+			} else {
+				err = errors.New(stderr)
+			}
+		}
+		return nil, errors.Wrapf(err, "restic backup pvc/%s failed", pvc)
+	}
+
+	summary, err := parseBackupSummary([]byte(message))
+	if err != nil {
+		if summary = progress.Summary(); summary == nil {
+			return nil, err
+		}
+	}
+	if stderr := progress.Stderr(); len(stderr) != 0 {
+		r.logger.Warnf("backup pvc/%s finished with errors: %s", pvc, stderr)
+	}
+
+	// The backup already succeeded, failing to remove the old snapshots only
+	// makes the repository grow, so it doesn't fail the backup.
+	if err := r.applyRetention(backupObj, deployObj, clusterName, tags); err != nil {
+		r.logger.Warnf("apply retention for pvc/%s failed: %s", pvc, err.Error())
+	}
+	return summary, nil
+}
+
+// executeDumpCommand streams the output of the database dump tool executed in the pod
+// to backup to `restic backup --stdin` executed in the executor pod.
+// key is the identity of the dump, see dump.Key.
+func (r *backupRun) executeDumpCommand(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, execPod *corev1.Pod, key string, meta pvdataMeta) (*restic.NodeBackupSummary, error) {
+	dumpSpec := backupObj.Spec.Dump
+	username, password, err := dump.Credential(dumpSpec, backupObj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	cmdDump, err := dump.Command(dumpSpec, username, password)
+	if err != nil {
+		return nil, err
+	}
+	podObj, err := podHandler.WithNamespace(meta.podNamespace).Get(meta.podName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "pod handler get pod/%s failed", meta.podName)
+	}
+	summary, err := r.resticBackupStream(backupObj, deployObj, execPod, key, podObj, dumpSpec.Container, cmdDump, dump.Filename(dumpSpec.Type))
+	if err != nil {
+		return nil, errors.Wrapf(err, "dump %s database in pod/%s failed", dumpSpec.Type, podObj.GetName())
+	}
+	return summary, nil
+}
+
+// resticBackupStream streams the stdout of src executed in the container of
+// srcPod to `restic backup --stdin` executed in the executor pod, the data is saved
+// as the file filename in the restic snapshot. The retention policy is applied
+// after backup succeeded. The executor pod is created from the executor deployment deployObj.
+func (r *backupRun) resticBackupStream(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, execPod *corev1.Pod, key string,
+	srcPod *corev1.Pod, srcContainer string, src dump.Exec, filename string) (*restic.NodeBackupSummary, error) {
+	clusterName := snapshotHost(backupObj)
+	tags := snapshotTags(backupObj, key)
+	rj := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, true))
+	cmdBackup := rj.Command(res.Backup{Tag: tags, Host: clusterName, Stdin: true, StdinFilename: filename}).String()
+	if err := r.ensureRepository(execPod); err != nil {
+		return nil, err
+	}
+	r.logger.Debug(cmdBackup)
+	progress := newBackupProgress(r.logger, key)
+	lock := r.repoLock()
+	lock.RLock()
+	err := dump.Stream(srcPod, srcContainer, src, execPod, "", dump.Exec{Command: withProgressFPS(strings.Split(cmdBackup, " "))}, progress)
+	lock.RUnlock()
+	progress.Close()
+	if err != nil {
+		// restic saves the snapshot when stdin closed even if src failed,
+		// the incomplete snapshot should be removed.
+		if summary := progress.Summary(); summary != nil {
+			rc := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, false))
+			cmdForget := rc.Command(res.Forget{}.SetArgs(summary.SnapshotID)).String()
+			r.logger.Debug(cmdForget)
+			lock.Lock()
+			if forgetErr := podHandler.WithNamespace(execPod.GetNamespace()).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdForget, " "), dump.NoStdin(), io.Discard, io.Discard); forgetErr != nil {
+				r.logger.Warnf("remove the incomplete snapshot %s failed: %s", summary.SnapshotID, forgetErr.Error())
+			}
+			lock.Unlock()
+		}
+		return nil, err
+	}
+
+	summary := progress.Summary()
+	if summary == nil {
+		return nil, errSummaryNotFound
+	}
+	if err := r.applyRetention(backupObj, deployObj, clusterName, tags); err != nil {
+		r.logger.Warnf("apply retention for %s failed: %s", key, err.Error())
+	}
+	return summary, nil
+}
+
+// snapshotHost returns the hostname of the restic snapshot, it's the argument of
+// flag --host, default to types.DefaultClusterName.
+func snapshotHost(backupObj *storagev1alpha1.Backup) string {
+	if len(backupObj.Spec.Cluster) == 0 {
+		return types.DefaultClusterName
+	}
+	return backupObj.Spec.Cluster
+}
+
+// snapshotTags returns the tags of the restic snapshot, pvc is the persistentvolumeclaim
+// name or the dump key.
+func snapshotTags(backupObj *storagev1alpha1.Backup, pvc string) []string {
+	return []string{string(backupObj.Spec.BackupFrom.Resource), backupObj.Namespace, backupObj.Spec.BackupFrom.Name, pvc}
+}
+
+// ensureRepository executes `restic init` to init restic repository if it doesn't exist.
+// The repository is locked exclusively, the concurrent backups to the same storage
+// may init the repository at the same time.
+func (r *backupRun) ensureRepository(execPod *corev1.Pod) error {
+	lock := r.repoLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	rc := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, false))
+	cmdCheckRepo := rc.Command(res.List{}.SetArgs("keys")).String()
+	cmdInitRepo := rc.Command(res.Init{}).String()
+
+	handler := podHandler.WithNamespace(execPod.GetNamespace())
 	// if `restic list keys` failed, it's means that the rstic repository not exist,
 	// we should execute `restic init` command to init restic repository.
-	logger.Debug(cmdCheckRepo)
-	if err := podHandler.ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdCheckRepo, " "), os.Stdin, io.Discard, io.Discard); err != nil {
-		logger.Debug(cmdInitRepo)
+	r.logger.Debug(cmdCheckRepo)
+	if err := handler.ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdCheckRepo, " "), os.Stdin, io.Discard, io.Discard); err != nil {
+		r.logger.Debug(cmdInitRepo)
 		// if `restic init` failed, the next backup task wil not be continue.
-		if err := podHandler.ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdInitRepo, " "), os.Stdin, io.Discard, io.Discard); err != nil {
-			return errors.New("restic init failed")
+		stderr := new(bytes.Buffer)
+		if err := handler.ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdInitRepo, " "), os.Stdin, io.Discard, stderr); err != nil {
+			return errors.Wrapf(errRepositoryNotReady, "restic init failed: %s", strings.TrimSpace(stderr.String()))
 		}
 	}
-	logger.Debug(cmdBackup)
-	// execute `restic backup` command to backup pvc data to storage.
-	if err := podHandler.WithNamespace(operatorNamespace).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdBackup, " "), os.Stdin, io.Discard, io.Discard); err != nil {
-		return fmt.Errorf("restic backup pvc/%s failed, maybe the directory/file of %s do not exist in k8s node", pvc, pvpath)
-	}
-
 	return nil
 }
+
+// parseBackupSummary find out the summary message from the output of `restic backup --json`,
+// every line of the output is a json message, the summary message is the last one.
+func parseBackupSummary(output []byte) (*restic.NodeBackupSummary, error) {
+	lines := bytes.Split(bytes.TrimSpace(output), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		summary := &restic.NodeBackupSummary{}
+		if err := json.Unmarshal(lines[i], summary); err != nil {
+			continue
+		}
+		if summary.MessageType == "summary" {
+			return summary, nil
+		}
+	}
+	return nil, errSummaryNotFound
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBackupSummary(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		wantSnapshot string
+		wantErr      error
+	}{
+		{
+			name:         "termination message",
+			output:       resticSummaryLine + "\n",
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+		},
+		{
+			name:         "summary without newline",
+			output:       resticSummaryLine,
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+		},
+		{
+			name:         "exit code 3",
+			output:       strings.Join([]string{resticStatusLine, resticErrorLine, resticSummaryLine, resticWarningLine}, "\n"),
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+		},
+		{
+			name:    "status only",
+			output:  resticStatusLine + "\n",
+			wantErr: errSummaryNotFound,
+		},
+		{
+			name:    "truncated summary",
+			output:  resticStatusLine + "\n" + resticSummaryLine[:50],
+			wantErr: errSummaryNotFound,
+		},
+		{
+			name:    "restic fatal error",
+			output:  resticFatalLine,
+			wantErr: errSummaryNotFound,
+		},
+		{
+			name:    "empty",
+			wantErr: errSummaryNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := parseBackupSummary([]byte(tt.output))
+			if err != tt.wantErr {
+				t.Fatalf("parseBackupSummary() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if summary.SnapshotID != tt.wantSnapshot {
+				t.Errorf("snapshot = %q, want %q", summary.SnapshotID, tt.wantSnapshot)
+			}
+			if summary.FilesNew != 2 || summary.TotalBytesProcessed != 2048 || summary.DataAdded != 1234 {
+				t.Errorf("unexpected summary: %+v", summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// HostRootVolumeName is the name of volume that mount the k8s node root
+	// directory in the executor deployment.
+	HostRootVolumeName = "host-root"
+)
+
+// ExecutorDeployment returns the deployment that run restic command against the
+// restic repository of the storage defined in Backup object.
+// The deployment is the same as horusctl used to backup persistentvolume data,
+// restore/clone reuse it to restore persistentvolume data from the storage.
+// The deployment name is name and it will run on the k8s node nodeName,
+// let nodeName empty if the deployment should be scheduled by kube-scheduler.
+func ExecutorDeployment(backupObj *storagev1alpha1.Backup, storage types.Storage, name, nodeName string) (*appsv1.Deployment, error) {
+	if backupObj.Spec.BackupTo == nil {
+		return nil, errors.New("Backup.spec.backupTo is empty")
+	}
+
+	var data []byte
+	var err error
+	switch storage {
+	case types.StorageNFS:
+		if backupObj.Spec.BackupTo.NFS == nil {
+			return nil, errors.New("Backup.spec.backupTo.nfs is empty")
+		}
+		data, err = backup2nfsDeployment(backupObj, name, nodeName)
+	case types.StorageMinIO:
+		if backupObj.Spec.BackupTo.MinIO == nil {
+			return nil, errors.New("Backup.spec.backupTo.minio is empty")
+		}
+		data, err = backup2minioDeployment(backupObj, name, nodeName)
+	case types.StorageS3:
+		if backupObj.Spec.BackupTo.S3 == nil {
+			return nil, errors.New("Backup.spec.backupTo.s3 is empty")
+		}
+		data, err = backup2s3Deployment(backupObj, name, nodeName)
+	case types.StorageRestServer:
+		if backupObj.Spec.BackupTo.RestServer == nil {
+			return nil, errors.New("Backup.spec.backupTo.restServer is empty")
+		}
+		data, err = backup2restserverDeployment(backupObj, name, nodeName)
+	case types.StorageCephFS:
+		if backupObj.Spec.BackupTo.CephFS == nil {
+			return nil, errors.New("Backup.spec.backupTo.cephfs is empty")
+		}
+		data, err = backup2cephfsDeployment(backupObj, name, nodeName)
+	case types.StorageRClone:
+		if backupObj.Spec.BackupTo.Rclone == nil {
+			return nil, errors.New("Backup.spec.backupTo.rclone is empty")
+		}
+		data, err = backup2rcloneDeployment(backupObj, name, nodeName)
+	case types.StoragePVC:
+		if backupObj.Spec.BackupTo.PVC == nil {
+			return nil, errors.New("Backup.spec.backupTo.pvc is empty")
+		}
+		data, err = backup2pvcDeployment(backupObj, name, nodeName)
+	case types.StorageSFTP:
+		if backupObj.Spec.BackupTo.SFTP == nil {
+			return nil, errors.New("Backup.spec.backupTo.sftp is empty")
+		}
+		data, err = backup2sftpDeployment(backupObj, name, nodeName)
+	default:
+		return nil, fmt.Errorf("not support storage type: %s", storage)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deployObj := &appsv1.Deployment{}
+	if err := yaml.Unmarshal(data, deployObj); err != nil {
+		return nil, errors.Wrap(err, "unmarshal deployment failed")
+	}
+	return deployObj, nil
+}
+
+// CreateExecutor creates the executor deployment and get its any running status pod.
+// The namespace determine which namespace the deployment object deploy to.
+func CreateExecutor(namespace string, deployObj *appsv1.Deployment) (*corev1.Pod, error) {
+	handler := depHandler.WithNamespace(namespace)
+	deployObj.SetNamespace(namespace)
+
+	// 1.apply deployment
+	deployObj, err := handler.Apply(deployObj)
+	if err != nil {
+		return nil, fmt.Errorf("deployment handler Apply failed: %s", err.Error())
+	}
+	// 2.block here and wait the deployment to be available and ready.
+	if err := handler.WaitReady(deployObj.GetName()); err != nil {
+		return nil, fmt.Errorf("deployment handler WaitReady %s failed: %s", deployObj.GetName(), err.Error())
+	}
+	// 3.get all pods object owned by the deployment.
+	podObjs, err := handler.GetPods(deployObj)
+	if err != nil {
+		return nil, fmt.Errorf("deployment handler get %s all pods failed: %s", deployObj.GetName(), err.Error())
+	}
+	// 4.return the running pod which is not Terminating.
+	for _, podObj := range podObjs {
+		if !podObj.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if podObj.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		return podObj, nil
+	}
+	return nil, fmt.Errorf("not found running pod for deployment/%s", deployObj.GetName())
+}
+
+// RemoveHostRoot removes the volume mounts the k8s node root directory from the executor
+// pod spec, so the executor can be scheduled to any k8s node by kube-scheduler.
+func RemoveHostRoot(podSpec *corev1.PodSpec) {
+	var volumes []corev1.Volume
+	for _, volume := range podSpec.Volumes {
+		if volume.Name != HostRootVolumeName {
+			volumes = append(volumes, volume)
+		}
+	}
+	podSpec.Volumes = volumes
+	for i := range podSpec.Containers {
+		var volumeMounts []corev1.VolumeMount
+		for _, volumeMount := range podSpec.Containers[i].VolumeMounts {
+			if volumeMount.Name != HostRootVolumeName {
+				volumeMounts = append(volumeMounts, volumeMount)
+			}
+		}
+		podSpec.Containers[i].VolumeMounts = volumeMounts
+	}
+}
+
+// CopyCredential copy the credential secrets referenced by the executor pod spec in the
+// operator namespace to the namespace as one secret with the new name, and makes the
+// pod spec reference the new secret, so the executor can be created in the namespace.
+// The keys of the credential secrets should not conflict.
+func CopyCredential(podSpec *corev1.PodSpec, namespace, name string) error {
+	data := make(map[string][]byte)
+	for _, credentialName := range referencedSecrets(podSpec) {
+		secObj, err := secHandler.WithNamespace(util.GetOperatorNamespace()).Get(credentialName)
+		if err != nil {
+			return errors.Wrapf(err, "secret handler get secret/%s failed", credentialName)
+		}
+		for key, value := range secObj.Data {
+			data[key] = value
+		}
+	}
+	copied := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	util.SetRecommendedLabels(copied)
+	if _, err := secHandler.WithNamespace(namespace).Apply(copied); err != nil {
+		return errors.Wrapf(err, "secret handler apply secret/%s failed", name)
+	}
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		for j := range container.Env {
+			if ref := container.Env[j].ValueFrom; ref != nil && ref.SecretKeyRef != nil {
+				ref.SecretKeyRef.Name = name
+			}
+		}
+	}
+	for i := range podSpec.Volumes {
+		if cephfs := podSpec.Volumes[i].CephFS; cephfs != nil && cephfs.SecretRef != nil {
+			cephfs.SecretRef.Name = name
+		}
+		if secret := podSpec.Volumes[i].Secret; secret != nil {
+			secret.SecretName = name
+		}
+	}
+	return nil
+}
+
+// referencedSecrets returns the names of secrets referenced by the environment variables
+// of the containers, the cephfs volumes and the secret volumes.
+func referencedSecrets(podSpec *corev1.PodSpec) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, volume := range podSpec.Volumes {
+		var name string
+		switch {
+		case volume.CephFS != nil && volume.CephFS.SecretRef != nil:
+			name = volume.CephFS.SecretRef.Name
+		case volume.Secret != nil:
+			name = volume.Secret.SecretName
+		default:
+			continue
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, container := range podSpec.Containers {
+		for _, env := range container.Env {
+			if env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+				continue
+			}
+			if name := env.ValueFrom.SecretKeyRef.Name; !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -5,98 +5,80 @@ import (
 	"time"
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/restic"
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
-	corev1 "k8s.io/api/core/v1"
 )
 
-// backupFunc
-type backupFunc func(backupObj *storagev1alpha1.Backup, pvc string, meta pvdataMeta) error
-
-// backupFactory
-func backupFactory(storage types.Storage) backupFunc {
-	return func(backupObj *storagev1alpha1.Backup, pvc string, meta pvdataMeta) error {
-		beginTime := time.Now().UTC()
-		defer func() {
-			costedTime = time.Now().UTC().Sub(beginTime)
-		}()
+// backup backups the persistentvolumeclaim to the storage of the run and returns the restic backup summary.
+func (r *backupRun) backup(backupObj *storagev1alpha1.Backup, pvc string, meta pvdataMeta) (*restic.NodeBackupSummary, error) {
+	switch r.storage {
+	case types.StorageNFS, types.StorageMinIO, types.StorageS3, types.StorageCephFS,
+		types.StorageRClone, types.StorageSFTP, types.StorageRestServer, types.StoragePVC:
+	default:
+		return nil, fmt.Errorf("not support storage type: %s", r.storage)
+	}
 
-		// ==============================
-		// for backup to different storage.
-		// ==============================
-		switch storage {
-		case types.StorageNFS:
-			logger = logger.WithField("storage", "nfs")
-		case types.StorageMinIO:
-			logger = logger.WithField("storage", "minio")
-		case types.StorageS3:
-			logger = logger.WithField("storage", "s3")
-		case types.StorageCephFS:
-			logger = logger.WithField("storage", "cephfs")
-		case types.StorageRClone:
-			logger = logger.WithField("storage", "rclone")
-		case types.StorageSFTP:
-			logger = logger.WithField("storage", "sftp")
-		case types.StorageRestServer:
-			logger = logger.WithField("storage", "restserver")
-		default:
-			return fmt.Errorf("not support storage type: %s", storage)
+	// Block here until waiting for pod/deployment/statefulset/daemonset to be ready and available.
+	// These k8s resource are what we should backup to storage.
+	var err error
+	name := backupObj.Spec.BackupFrom.Name
+	resource := backupObj.Spec.BackupFrom.Resource
+	namespace := backupObj.GetNamespace()
+	switch resource {
+	case storagev1alpha1.PodResource:
+		if err = podHandler.WithNamespace(namespace).WaitReady(name); err != nil {
+			return nil, errors.Wrapf(err, "pod handler wait pod/%s to be ready failed", name)
 		}
-
-		// Block here until waiting for pod/deployment/statefulset/daemonset to be ready and available.
-		// These k8s resource are what we should backup to storage.
-		var err error
-		name := backupObj.Spec.BackupFrom.Name
-		resource := backupObj.Spec.BackupFrom.Resource
-		namespace := backupObj.GetNamespace()
-		switch resource {
-		case storagev1alpha1.PodResource:
-			if err = podHandler.WithNamespace(namespace).WaitReady(name); err != nil {
-				return errors.Wrapf(err, "pod handler wait pod/%s to be ready failed", name)
-			}
-		case storagev1alpha1.DeploymentResource:
-			if err = depHandler.WithNamespace(namespace).WaitReady(name); err != nil {
-				return errors.Wrapf(err, "deployment handler wait deployment/%s to be ready failed", name)
-			}
-		case storagev1alpha1.StatefulSetResource:
-			if err = stsHandler.WithNamespace(namespace).WaitReady(name); err != nil {
-				return errors.Wrapf(err, "statefulset handler wait statefulset/%s to be ready failed", name)
-			}
-		case storagev1alpha1.DaemonSetResource:
-			if err = dsHandler.WithNamespace(namespace).WaitReady(name); err != nil {
-				return errors.Wrapf(err, "daemonset handler wait daemonset/%s to be ready failed", name)
-			}
-		default:
-			return fmt.Errorf("not support backup resource: %s", resource)
+	case storagev1alpha1.DeploymentResource:
+		if err = depHandler.WithNamespace(namespace).WaitReady(name); err != nil {
+			return nil, errors.Wrapf(err, "deployment handler wait deployment/%s to be ready failed", name)
 		}
-
-		// ==============================
-		// for backup to different storage.
-		// ==============================
-		var execPod *corev1.Pod
-		switch storage {
-		case types.StorageNFS:
-			if execPod, err = createBackup2nfsDeployment(backupObj, meta); err != nil {
-				return err
-			}
-			logger.WithFields(logrus.Fields{"cost": costedTime.String()}).Debugf("create deployment/%s", theDeployName(backup2nfsName, backupObj, meta))
-		case types.StorageMinIO:
-			if execPod, err = createBackup2minioDepoyment(backupObj, meta); err != nil {
-				return err
-			}
-			logger.WithFields(logrus.Fields{"cost": costedTime.String()}).Debugf("create deployment/%s", theDeployName(backup2nfsName, backupObj, meta))
-		case types.StorageSFTP:
-			if execPod, err = createBackup2sftpDeployment(backupObj, meta); err != nil {
-				return err
-			}
-			logger.WithFields(logrus.Fields{"cost": costedTime.String()}).Debugf("create deployment/%s", theDeployName(backup2sftpName, backupObj, meta))
+	case storagev1alpha1.StatefulSetResource:
+		if err = stsHandler.WithNamespace(namespace).WaitReady(name); err != nil {
+			return nil, errors.Wrapf(err, "statefulset handler wait statefulset/%s to be ready failed", name)
+		}
+	case storagev1alpha1.DaemonSetResource:
+		if err = dsHandler.WithNamespace(namespace).WaitReady(name); err != nil {
+			return nil, errors.Wrapf(err, "daemonset handler wait daemonset/%s to be ready failed", name)
 		}
+	case storagev1alpha1.PersistentVolumeClaim, storagev1alpha1.PersistentVolume:
+		// the persistentvolumeclaim is mounted when construct the persistentvolume metadata.
+	default:
+		return nil, fmt.Errorf("not support backup resource: %s", resource)
+	}
 
-		// execute restic command to backup persistentvolume data to remote storage within the pod.
-		if err = executeBackupCommand(backupObj, execPod, pvc, meta); err != nil {
-			return err
+	// backup from the VolumeSnapshot creates its own executor in the namespace of the persistentvolumeclaim.
+	if backupObj.Spec.VolumeSnapshot != nil && backupObj.Spec.Dump == nil && meta.manifests == nil {
+		return r.executeSnapshotBackup(backupObj, pvc, meta)
+	}
+
+	// ==============================
+	// for backup to different storage.
+	// ==============================
+	// the executor deployment is the template of the executor job and pod.
+	executorName := theDeployName(executorNames[r.storage], backupObj, meta)
+	deployObj, err := ExecutorDeployment(backupObj, r.storage, executorName, meta.nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifests and the database dump are written to the stdin of `restic backup`,
+	// which is executed within the executor pod.
+	if meta.manifests != nil || backupObj.Spec.Dump != nil {
+		begin := time.Now()
+		execPod, err := r.createExecutorPod(executorPod(backupObj, deployObj))
+		if err != nil {
+			return nil, err
+		}
+		defer r.deleteExecutor(executorKey{namespace: execPod.GetNamespace(), name: execPod.GetName()})
+		r.logger.WithField("cost", time.Now().Sub(begin).String()).Debugf("Executor pod/%s is running", execPod.GetName())
+		if meta.manifests != nil {
+			return r.executeManifestBackup(backupObj, deployObj, execPod, pvc, meta)
 		}
-		return nil
+		return r.executeDumpCommand(backupObj, deployObj, execPod, pvc, meta)
 	}
+	// execute restic command to backup persistentvolume data to remote storage
+	// within the executor job.
+	return r.executeBackupCommand(backupObj, deployObj, pvc, meta)
 }
@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"path/filepath"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pvcVolumes returns the map of persistentvolumeclaim name to the volume name in pod spec.
+func pvcVolumes(podObj *corev1.Pod) map[string]string {
+	volumes := make(map[string]string)
+	for _, volume := range podObj.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			volumes[volume.PersistentVolumeClaim.ClaimName] = volume.Name
+		}
+	}
+	return volumes
+}
+
+// matchPVC returns true if the persistentvolumeclaim name or the volume name
+// matches any of the shell patterns.
+func matchPVC(patterns []string, pvc, volume string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, pvc); matched {
+			return true
+		}
+		if len(volume) == 0 {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, volume); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPVCs returns the persistentvolumeclaims mounted by the pod that
+// should be backed up according to Backup.spec.pvcFilter.
+func filterPVCs(backupObj *storagev1alpha1.Backup, podObj *corev1.Pod, pvcList []string) []string {
+	filter := backupObj.Spec.PVCFilter
+	if filter == nil {
+		return pvcList
+	}
+	volumes := pvcVolumes(podObj)
+	var filtered []string
+	for _, pvc := range pvcList {
+		if len(filter.Include) != 0 && !matchPVC(filter.Include, pvc, volumes[pvc]) {
+			logger.Debugf("pvc/%s not matched Backup.spec.pvcFilter.include, skip it", pvc)
+			continue
+		}
+		if matchPVC(filter.Exclude, pvc, volumes[pvc]) {
+			logger.Debugf("pvc/%s matched Backup.spec.pvcFilter.exclude, skip it", pvc)
+			continue
+		}
+		filtered = append(filtered, pvc)
+	}
+	return filtered
+}
+
+// excludeArgs returns the restic backup exclude flags for the persistentvolumeclaim
+// according to Backup.spec.excludes, pvpath is the root directory of the persistentvolume
+// in executor pod.
+//
+// The flags are rendered one pattern per flag instead of setting restic.Backup fields,
+// which joins the patterns by comma, but restic treats the comma as part of the pattern.
+func excludeArgs(backupObj *storagev1alpha1.Backup, pvc, volume, pvpath string) []string {
+	var args []string
+	for _, exclude := range backupObj.Spec.Excludes {
+		if len(exclude.PVCs) != 0 && !matchPVC(exclude.PVCs, pvc, volume) {
+			continue
+		}
+		for _, pattern := range exclude.Exclude {
+			args = append(args, "--exclude="+excludePattern(pattern, pvpath))
+		}
+		for _, pattern := range exclude.Iexclude {
+			args = append(args, "--iexclude="+excludePattern(pattern, pvpath))
+		}
+		for _, file := range exclude.ExcludeFile {
+			args = append(args, "--exclude-file="+filepath.Join(pvpath, file))
+		}
+		// restic only takes the last --exclude-larger-than.
+		if len(exclude.ExcludeLargerThan) != 0 {
+			args = append(args, "--exclude-larger-than="+exclude.ExcludeLargerThan)
+		}
+	}
+	return args
+}
+
+// excludePattern makes the absolute pattern relative to the persistentvolume root directory,
+// the negated pattern starts with "!" is supported.
+func excludePattern(pattern, pvpath string) string {
+	negated := strings.HasPrefix(pattern, "!")
+	pattern = strings.TrimPrefix(pattern, "!")
+	if strings.HasPrefix(pattern, "/") {
+		pattern = filepath.Join(pvpath, pattern)
+	}
+	if negated {
+		return "!" + pattern
+	}
+	return pattern
+}
@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultHookTimeout = 30 * time.Second
+	// hookKillGracePeriod is how long to wait for the exec to return after the
+	// hook command killed by timeout.
+	hookKillGracePeriod = 10 * time.Second
+)
+
+// hookPhase is the phase the hooks executed, "pre" or "post".
+type hookPhase string
+
+const (
+	hookPhasePre  hookPhase = "pre"
+	hookPhasePost hookPhase = "post"
+)
+
+// podHooks returns the hooks of the phase for the pod, the hook defined by pod
+// annotations overrides the hooks defined in Backup.spec.hooks.
+func podHooks(backupObj *storagev1alpha1.Backup, podObj *corev1.Pod, phase hookPhase) ([]storagev1alpha1.BackupHook, error) {
+	annoContainer, annoCommand, annoTimeout, annoOnError := types.AnnotationPreHookContainer,
+		types.AnnotationPreHookCommand, types.AnnotationPreHookTimeout, types.AnnotationPreHookOnError
+	if phase == hookPhasePost {
+		annoContainer, annoCommand, annoTimeout, annoOnError = types.AnnotationPostHookContainer,
+			types.AnnotationPostHookCommand, types.AnnotationPostHookTimeout, types.AnnotationPostHookOnError
+	}
+
+	annotations := podObj.GetAnnotations()
+	if command := annotations[annoCommand]; len(command) != 0 {
+		hook := storagev1alpha1.BackupHook{
+			Container: annotations[annoContainer],
+			OnError:   storagev1alpha1.HookErrorMode(annotations[annoOnError]),
+		}
+		if err := json.Unmarshal([]byte(command), &hook.Command); err != nil {
+			hook.Command = []string{"/bin/sh", "-c", command}
+		}
+		if timeout := annotations[annoTimeout]; len(timeout) != 0 {
+			duration, err := time.ParseDuration(timeout)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parse annotation %s of pod/%s failed", annoTimeout, podObj.GetName())
+			}
+			hook.Timeout = &metav1.Duration{Duration: duration}
+		}
+		return []storagev1alpha1.BackupHook{hook}, nil
+	}
+
+	hooks := backupObj.Spec.Hooks
+	if hooks == nil {
+		return nil, nil
+	}
+	if phase == hookPhasePost {
+		return hooks.PostBackup, nil
+	}
+	return hooks.PreBackup, nil
+}
+
+// runHooks executes the hooks of the phase in the pod one by one. It returns error
+// and stops executing the rest hooks if the hook failed and the OnError policy is Fail.
+func (r *backupRun) runHooks(backupObj *storagev1alpha1.Backup, podObj *corev1.Pod, phase hookPhase) error {
+	hooks, err := podHooks(backupObj, podObj, phase)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		begin := time.Now()
+		if err := executeHook(podObj, hook); err != nil {
+			err = errors.Wrapf(err, "%s backup hook %v in pod/%s failed", phase, hook.Command, podObj.GetName())
+			if hook.OnError == storagev1alpha1.HookErrorModeContinue {
+				r.logger.Warn(err)
+				continue
+			}
+			return err
+		}
+		r.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully execute %s backup hook %v in pod/%s", phase, hook.Command, podObj.GetName())
+	}
+	return nil
+}
+
+// runPreHooks executes the pre backup hooks in every pod mounts the persistentvolumeclaims
+// to backup, and returns the pods the hooks executed, including the pod which hooks failed.
+func (r *backupRun) runPreHooks(backupObj *storagev1alpha1.Backup, pvcpvMap map[string]pvdataMeta) ([]*corev1.Pod, error) {
+	var podObjs []*corev1.Pod
+	seen := make(map[string]bool)
+	for _, meta := range pvcpvMap {
+		// the persistentvolume not bound has no pod to execute hooks, and the
+		// pod mounts the persistentvolumeclaim temporarily has no application.
+		key := meta.podNamespace + "/" + meta.podName
+		if len(meta.podName) == 0 || strings.HasPrefix(meta.podName, pvcMounterName+"-") || seen[key] {
+			continue
+		}
+		seen[key] = true
+		podObj, err := podHandler.WithNamespace(meta.podNamespace).Get(meta.podName)
+		if err != nil {
+			return podObjs, errors.Wrapf(err, "pod handler get pod/%s failed", meta.podName)
+		}
+		podObjs = append(podObjs, podObj)
+		if err := r.runHooks(backupObj, podObj, hookPhasePre); err != nil {
+			return podObjs, err
+		}
+	}
+	return podObjs, nil
+}
+
+// executeHook executes the hook command in the pod container and waits for it
+// until timeout. The command is wrapped by `timeout -s KILL`, so that it's killed
+// in the pod when timeout, such as a hung pre hook holding the database lock.
+// If the container has no `timeout` command, such as the distroless images, the
+// command is executed directly, it's not killed in the pod when timeout.
+func executeHook(podObj *corev1.Pod, hook storagev1alpha1.BackupHook) error {
+	if len(hook.Command) == 0 {
+		return errors.New("hook command is empty")
+	}
+	timeout := defaultHookTimeout
+	if hook.Timeout != nil && hook.Timeout.Duration > 0 {
+		timeout = hook.Timeout.Duration
+	}
+	command := append([]string{"timeout", "-s", "KILL", strconv.FormatInt(int64(math.Ceil(timeout.Seconds())), 10)}, hook.Command...)
+	stderr, err := execHook(podObj, hook.Container, command, timeout)
+	if err != nil && timeoutNotFound(err, stderr) {
+		logger.Warnf("command timeout not found in pod/%s, the hook is not killed in the pod when timeout", podObj.GetName())
+		stderr, err = execHook(podObj, hook.Container, hook.Command, timeout)
+	}
+	if err != nil && len(stderr) != 0 {
+		return fmt.Errorf("%s: %s", err.Error(), stderr)
+	}
+	return err
+}
+
+// execHook executes the command in the pod container and waits for it until timeout,
+// it returns the stderr of the command.
+func execHook(podObj *corev1.Pod, container string, command []string, timeout time.Duration) (string, error) {
+	begin := time.Now()
+	stderr := new(bytes.Buffer)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- podHandler.WithNamespace(podObj.GetNamespace()).ExecuteWithStream(podObj.GetName(), container, command, strings.NewReader(""), io.Discard, stderr)
+	}()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			return "", nil
+		}
+		if time.Since(begin) >= timeout {
+			return "", fmt.Errorf("timeout after %s, the command is killed", timeout.String())
+		}
+		return strings.TrimSpace(stderr.String()), err
+	// The command has been killed, but the exec stream is not closed.
+	case <-time.After(timeout + hookKillGracePeriod):
+		return "", fmt.Errorf("timeout after %s, the command is killed but the exec doesn't return", timeout.String())
+	}
+}
+
+// timeoutNotFound returns true if the command failed because the `timeout` command
+// doesn't exist in the container, the container runtime reports it by the exec
+// error or the stderr.
+func timeoutNotFound(err error, stderr string) bool {
+	const notFound = `"timeout": executable file not found`
+	return strings.Contains(err.Error(), notFound) || strings.Contains(stderr, notFound)
+}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTimeoutNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		stderr string
+		expect bool
+	}{
+		{
+			name:   "exec error of runc",
+			err:    errors.New(`OCI runtime exec failed: exec failed: unable to start container process: exec: "timeout": executable file not found in $PATH: unknown`),
+			expect: true,
+		},
+		{
+			name:   "stderr",
+			err:    errors.New("command terminated with exit code 126"),
+			stderr: `exec: "timeout": executable file not found in $PATH`,
+			expect: true,
+		},
+		{
+			name:   "the hook command failed",
+			err:    errors.New("command terminated with exit code 1"),
+			stderr: "ERROR 1045 (28000): Access denied",
+		},
+		{
+			name: "the hook command not found",
+			err:  errors.New(`OCI runtime exec failed: exec failed: unable to start container process: exec: "mysql": executable file not found in $PATH: unknown`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeoutNotFound(tt.err, tt.stderr); got != tt.expect {
+				t.Fatalf("timeoutNotFound() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// executorTTL is the seconds the finished executor job is kept, the job is
+	// deleted by kubernetes even if horusctl is killed before cleaning it up.
+	executorTTL = int32(300)
+	// exitRepositoryNotReady is the exit code of the executor job if the restic
+	// repository is not accessible or can't be initialized, it's different from
+	// the exit codes of restic.
+	exitRepositoryNotReady = 10
+	// maxGenerateNamePrefix is the max length of the executor name prefix, the job
+	// name is used as the label value of its pods, which must be no more than 63
+	// characters, and kubernetes appends 5 random characters to the prefix.
+	maxGenerateNamePrefix = 57
+)
+
+// executorNames is the name prefix of the executor for every storage.
+var executorNames = map[types.Storage]string{
+	types.StorageNFS:        backup2nfsName,
+	types.StorageMinIO:      backup2minioName,
+	types.StorageS3:         backup2s3Name,
+	types.StorageRestServer: backup2restserverName,
+	types.StorageCephFS:     backup2cephfsName,
+	types.StorageRClone:     backup2rcloneName,
+	types.StoragePVC:        backup2pvcName,
+	types.StorageSFTP:       backup2sftpName,
+}
+
+// executorDeadline returns the max seconds the executor runs, it's the same as the
+// horusctl job, the executor is useless after horusctl terminated.
+func executorDeadline(backupObj *storagev1alpha1.Backup) int64 {
+	timeout := backupObj.Spec.Timeout.Duration
+	if timeout <= 0 {
+		timeout = types.DefaultBackupTimeout
+	}
+	if seconds := int64(timeout.Seconds()); seconds > 0 {
+		return seconds
+	}
+	return 1
+}
+
+// executorPodTemplate returns the pod template of the executor deployment, which is
+// never restarted and terminated after the deadline.
+func executorPodTemplate(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment) corev1.PodTemplateSpec {
+	deadline := executorDeadline(backupObj)
+	template := *deployObj.Spec.Template.DeepCopy()
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	template.Spec.ActiveDeadlineSeconds = &deadline
+	for i := range template.Spec.Containers {
+		// the logs is the termination message if the container failed.
+		template.Spec.Containers[i].TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+	}
+	return template
+}
+
+// executorJob converts the executor deployment to the job runs command as its entrypoint.
+// The output of the command written to /dev/termination-log is the result of the job.
+func executorJob(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, command []string) *batchv1.Job {
+	backoffLimit := int32(0)
+	ttl := executorTTL
+	deadline := executorDeadline(backupObj)
+	template := executorPodTemplate(backupObj, deployObj)
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Command = command
+		template.Spec.Containers[i].Args = nil
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: generateName(deployObj.GetName()),
+			Namespace:    deployObj.GetNamespace(),
+			Labels:       deployObj.GetLabels(),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			ActiveDeadlineSeconds:   &deadline,
+			Template:                template,
+		},
+	}
+}
+
+// executorPod converts the executor deployment to the bare pod to execute commands within,
+// such as `restic backup --stdin`. The pod exits by itself after the deadline.
+func executorPod(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment) *corev1.Pod {
+	template := executorPodTemplate(backupObj, deployObj)
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Command = []string{"sleep", strconv.FormatInt(*template.Spec.ActiveDeadlineSeconds, 10)}
+		template.Spec.Containers[i].Args = nil
+	}
+	template.ObjectMeta.GenerateName = generateName(deployObj.GetName())
+	template.ObjectMeta.Namespace = deployObj.GetNamespace()
+	return &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+}
+
+// generateName returns the name prefix of the executor, kubernetes generates the
+// unique name for every executor, so the executors never conflict with the ones
+// left by the last run.
+func generateName(name string) string {
+	if len(name) > maxGenerateNamePrefix {
+		name = name[:maxGenerateNamePrefix]
+	}
+	return strings.TrimRight(name, "-.") + "-"
+}
+
+// runJob creates the executor job and waits for it to finish, it returns the termination
+// message of the job pod, which is the logs of the pod if the job failed.
+// The logs of the job pod are written to output if it's not nil.
+// The job is deleted after finished.
+func (r *backupRun) runJob(jobObj *batchv1.Job, output io.Writer) (string, error) {
+	namespace := jobObj.GetNamespace()
+	handler := jobHandler.WithNamespace(namespace)
+	jobObj.SetOwnerReferences(r.executorOwner(namespace, *jobObj.Spec.ActiveDeadlineSeconds))
+	jobObj, err := handler.Create(jobObj)
+	if err != nil {
+		return "", errors.Wrap(err, "job handler create job failed")
+	}
+	name := jobObj.GetName()
+	r.addExecutor(executorKey{namespace: namespace, name: name, job: true})
+	defer r.deleteExecutor(executorKey{namespace: namespace, name: name, job: true})
+	r.logger.Debugf("Created executor job/%s", name)
+
+	logsCtx, cancelLogs := context.WithCancel(context.TODO())
+	defer cancelLogs()
+	var logsDone chan struct{}
+	if output != nil {
+		logsDone = make(chan struct{})
+		go func() {
+			defer close(logsDone)
+			if err := streamJobLogs(logsCtx, namespace, name, output); err != nil {
+				r.logger.Debugf("stream the logs of job/%s failed: %s", name, err.Error())
+			}
+		}()
+	}
+
+	// the job terminated by kubernetes after the deadline, give it more time to update status.
+	timeout := time.Duration(*jobObj.Spec.ActiveDeadlineSeconds)*time.Second + time.Minute
+	var succeeded bool
+	if err := wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		if jobObj, err = handler.Get(name); err != nil {
+			return false, errors.Wrapf(err, "job handler get job/%s failed", name)
+		}
+		for _, cond := range jobObj.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case batchv1.JobComplete:
+				succeeded = true
+				return true, nil
+			case batchv1.JobFailed:
+				return true, nil
+			}
+		}
+		return false, nil
+	}); err != nil {
+		return "", errors.Wrapf(err, "wait job/%s finished failed", name)
+	}
+	// the logs stream ends after the container terminated, wait for the remaining logs.
+	if logsDone != nil {
+		select {
+		case <-logsDone:
+		case <-time.After(10 * time.Second):
+			cancelLogs()
+			<-logsDone
+		}
+	}
+
+	message, exitCode := jobResult(namespace, name)
+	if !succeeded {
+		if exitCode == exitRepositoryNotReady {
+			return message, errors.Wrap(errRepositoryNotReady, message)
+		}
+		return message, fmt.Errorf("job/%s failed: %s", name, message)
+	}
+	return message, nil
+}
+
+// streamJobLogs follows the logs of the job pod and writes them to output until
+// the container terminated or ctx canceled.
+func streamJobLogs(ctx context.Context, namespace, name string, output io.Writer) error {
+	handler := podHandler.WithNamespace(namespace)
+	var podName string
+	// the logs are available after the container started.
+	if err := wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+		podObjs, err := handler.ListByLabel("job-name=" + name)
+		if err != nil {
+			return false, nil
+		}
+		for _, podObj := range podObjs {
+			for _, status := range podObj.Status.ContainerStatuses {
+				if status.State.Running != nil || status.State.Terminated != nil {
+					podName = podObj.GetName()
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}, ctx.Done()); err != nil {
+		return err
+	}
+	stream, err := handler.Clientset().CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "get the logs of pod/%s failed", podName)
+	}
+	defer stream.Close()
+	_, err = io.Copy(output, stream)
+	return err
+}
+
+// jobResult returns the termination message and the exit code of the job pod.
+func jobResult(namespace, name string) (string, int32) {
+	podObjs, err := podHandler.WithNamespace(namespace).ListByLabel("job-name=" + name)
+	if err != nil {
+		logger.Warnf("pod handler list pods of job/%s failed: %s", name, err.Error())
+		return "", 0
+	}
+	for _, podObj := range podObjs {
+		for _, status := range podObj.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil {
+				return strings.TrimSpace(terminated.Message), terminated.ExitCode
+			}
+		}
+		// the pod is killed after the deadline before the container terminated.
+		if len(podObj.Status.Reason) != 0 {
+			return podObj.Status.Reason + ": " + podObj.Status.Message, 0
+		}
+	}
+	return "", 0
+}
+
+// createExecutorPod creates the executor pod and waits for it to be running.
+// The pod should be deleted by the caller.
+func (r *backupRun) createExecutorPod(podObj *corev1.Pod) (*corev1.Pod, error) {
+	namespace := podObj.GetNamespace()
+	handler := podHandler.WithNamespace(namespace)
+	podObj.SetOwnerReferences(r.executorOwner(namespace, *podObj.Spec.ActiveDeadlineSeconds))
+	podObj, err := handler.Create(podObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "pod handler create pod failed")
+	}
+	name := podObj.GetName()
+	r.addExecutor(executorKey{namespace: namespace, name: name})
+	r.logger.Debugf("Created executor pod/%s", name)
+
+	if err := wait.PollImmediate(2*time.Second, time.Duration(*podObj.Spec.ActiveDeadlineSeconds)*time.Second, func() (bool, error) {
+		if podObj, err = handler.Get(name); err != nil {
+			return false, errors.Wrapf(err, "pod handler get pod/%s failed", name)
+		}
+		switch podObj.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodSucceeded, corev1.PodFailed:
+			return false, fmt.Errorf("pod/%s terminated: %s", name, podObj.Status.Message)
+		}
+		return false, nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "wait pod/%s running failed", name)
+	}
+	return podObj, nil
+}
+
+// shellCommand returns the command to execute the script by sh, the script is joined
+// by the lines.
+func shellCommand(lines ...string) []string {
+	return []string{"sh", "-c", strings.Join(lines, "\n")}
+}
+
+// shellQuote quotes the arguments to be used in the shell script.
+func shellQuote(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
@@ -1,11 +1,7 @@
 package backup
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"os"
-	"strings"
 	"time"
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
@@ -13,26 +9,41 @@ import (
 	"github.com/forbearing/horus-operator/pkg/types"
 	"github.com/forbearing/horus-operator/pkg/util"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
-// createFindpvdirDeployment
-func createFindpvdirDeployment(backupObj *storagev1alpha1.Backup, meta pvdataMeta) (string, error) {
-	beginTime := time.Now().UTC()
-	defer func() {
-		costedTime = time.Now().UTC().Sub(beginTime)
-	}()
+// runFindpvdirJob runs the findpvdir job on the k8s node where the persistentvolume
+// is mounted to find the persistentvolume data directory path.
+func (r *backupRun) runFindpvdirJob(backupObj *storagev1alpha1.Backup, meta pvdataMeta) (string, error) {
+	// if persistentvolume volume source is hostPath or local, the returned value
+	// is pvpath not pvdir, and pvpath = pvdir + pvname.
+	// And it's no need to find the persistentvolume data directory path now, just return
+	// the "hostPath" or "local" in k8s node path.
+	switch meta.volumeSource {
+	case types.VolumeHostPath:
+		pvObj, err := pvHandler.Get(meta.pvname)
+		if err != nil {
+			return "", errors.Wrap(err, "persistentvolume handler get persistentvolume failed")
+		}
+		return pvObj.Spec.HostPath.Path, nil
+	case types.VolumeLocal:
+		pvObj, err := pvHandler.Get(meta.pvname)
+		if err != nil {
+			return "", errors.Wrap(err, "persistentvolume handler get persistentvolume failed")
+		}
+		return pvObj.Spec.Local.Path, nil
+	}
 
 	operatorNamespace := util.GetOperatorNamespace()
-	podHandler.ResetNamespace(operatorNamespace)
-
-	DeployNameFindpvdir = theDeployName(findpvdirName, backupObj, meta)
+	deployName := theDeployName(findpvdirName, backupObj, meta)
 	findpvdirBytes := []byte(fmt.Sprintf(
 		// the deployment template
 		template.TemplateFindpvdir,
 		// deployment.metadata.name
 		// deployment.metadata.namespace
 		// deployment name, deployment namespace
-		DeployNameFindpvdir, operatorNamespace,
+		deployName, operatorNamespace,
 		// deployment.spec.template.metadata.annotations
 		// pod template annotations
 		types.AnnotationUpdatedTime, time.Now().Format(time.RFC3339),
@@ -43,45 +54,29 @@ func createFindpvdirDeployment(backupObj *storagev1alpha1.Backup, meta pvdataMet
 		// deployment.spec.template.spec.containers.env
 		// the environment variables passed to pods.
 		backupObj.Spec.TimeZone))
-	execPod, err := filterRunningPod(operatorNamespace, findpvdirBytes)
-	if err != nil {
-		return "", err
-	}
-
-	// if persistentvolume volume source is hostPath or local, the returned value
-	// is pvpath not pvdir, and pvpath = pvdir + pvname.
-	// And it's no need to find the persistentvolume data directory path now, just return
-	// the "hostPath" or "local" in k8s node path.
-	cmdFindpvdir := []string{"findpvdir", "--pod-uid", meta.podUID, "--storage-type", meta.volumeSource}
-	switch meta.volumeSource {
-	case types.VolumeHostPath:
-		pvObj, err := pvHandler.Get(meta.pvname)
-		if err != nil {
-			return "", errors.Wrap(err, "persistentvolume handler get persistentvolume failed")
-		}
-		return pvObj.Spec.HostPath.Path, nil
-	case types.VolumeLocal:
-		pvObj, err := pvHandler.Get(meta.pvname)
-		if err != nil {
-			return "", errors.Wrap(err, "persistentvolume handler get persistentvolume failed")
-		}
-		return pvObj.Spec.Local.Path, nil
+	deployObj := &appsv1.Deployment{}
+	if err := yaml.Unmarshal(findpvdirBytes, deployObj); err != nil {
+		return "", errors.Wrap(err, "unmarshal deployment failed")
 	}
-	logger.Debugf("executing command %v to find persistentvolume data in pod %s", cmdFindpvdir, execPod.GetName())
 
-	// It will execute command "cmdFindpvdir" within pod to find the persistentvolume data directory path
-	// and output it to cmdOutput.
-	cmdOutput := new(bytes.Buffer)
-	for i := 1; i <= 12; i++ {
-		if err := podHandler.ExecuteWithStream(execPod.GetName(), "", cmdFindpvdir, os.Stdin, cmdOutput, io.Discard); err != nil {
-			return "", errors.Wrapf(err, "%s find the persistentvolume data directory failed", DeployNameFindpvdir)
-		}
-		if len(strings.TrimSpace(cmdOutput.String())) != 0 {
-			break
-		}
-		logger.Warnf("the persistentvolume data path not found, retry %d", i)
-		time.Sleep(time.Second * 5)
+	// The job executes command "findpvdir" to find the persistentvolume data directory path,
+	// the path may not be found until the kubelet mounts the persistentvolume, retry
+	// at most 12 times. The path is written to the termination message of the job.
+	cmdFindpvdir := shellQuote([]string{"findpvdir", "--pod-uid", meta.podUID, "--storage-type", meta.volumeSource})
+	r.logger.Debugf("executing command %s to find persistentvolume data on node %s", cmdFindpvdir, meta.nodeName)
+	command := shellCommand(
+		"for i in 1 2 3 4 5 6 7 8 9 10 11 12; do",
+		fmt.Sprintf(`  pvdir=$(%s) || exit 1`, cmdFindpvdir),
+		`  [ -n "$pvdir" ] && break`,
+		`  echo "the persistentvolume data path not found, retry $i" >&2`,
+		"  sleep 5",
+		"done",
+		`printf '%s' "$pvdir" >/dev/termination-log`,
+	)
+	pvdir, err := r.runJob(executorJob(backupObj, deployObj, command), nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "%s find the persistentvolume data directory failed", deployName)
 	}
-	logger.Debugf("the persistentvolume data path is: %s", strings.TrimSpace(cmdOutput.String()))
-	return strings.TrimSpace(cmdOutput.String()), nil
+	r.logger.Debugf("the persistentvolume data path is: %s", pvdir)
+	return pvdir, nil
 }
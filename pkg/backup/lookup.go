@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// lookupDeadline is the max seconds the lookup executor pod runs, it's enough to
+// execute the restic commands to view the repository, such as `restic snapshots`.
+const lookupDeadline = int64(600)
+
+// LookupExecutor creates a short-lived executor pod in the operator namespace to execute
+// restic commands against the restic repository of the Backup object on the storage,
+// the repository and credentials are the same as the Backup backs up to. It's used to
+// view the repository when no backup is running, such as `horusctl restic snapshots`.
+// If storage is empty, the Backup object must back up to only one storage.
+// The returned function deletes the pod, it should be called by the caller.
+func LookupExecutor(namespace, name string, storage types.Storage) (*corev1.Pod, func(), error) {
+	backupObj, err := getBackup(namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	storages := ParseStorage(backupObj)
+	if len(storage) == 0 {
+		if len(storages) != 1 {
+			return nil, nil, fmt.Errorf("Backup %s/%s backs up to storages %v, the storage is required", namespace, name, storages)
+		}
+		storage = storages[0]
+	}
+	found := false
+	for _, s := range storages {
+		if s == storage {
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("Backup %s/%s doesn't back up to storage %s, must be one of %v", namespace, name, storage, storages)
+	}
+
+	// the executor only views the restic repository, it doesn't need the k8s node
+	// root directory and can be scheduled to any k8s node.
+	deployObj, err := ExecutorDeployment(backupObj, storage, theLookupName(executorNames[storage], name), "")
+	if err != nil {
+		return nil, nil, err
+	}
+	RemoveHostRoot(&deployObj.Spec.Template.Spec)
+	podObj := executorPod(backupObj, deployObj)
+	deadline := lookupDeadline
+	podObj.Spec.ActiveDeadlineSeconds = &deadline
+	for i := range podObj.Spec.Containers {
+		podObj.Spec.Containers[i].Command = []string{"sleep", strconv.FormatInt(deadline, 10)}
+	}
+
+	r := newBackupRun(logger.WithFields(logrus.Fields{"name": name, "namespace": namespace, "storage": storage}))
+	execPod, err := r.createExecutorPod(podObj)
+	if err != nil {
+		r.cleanup()
+		return nil, nil, err
+	}
+	return execPod, r.cleanup, nil
+}
+
+// theLookupName returns the name prefix of the lookup executor of the Backup object.
+func theLookupName(executorName, backupName string) string {
+	return fmt.Sprintf("%s-lookup-%s", executorName, backupName)
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/manifest"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	res "github.com/forbearing/restic"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// addManifests collects the manifests of the backup target and adds them to pvcpvMap
+// with the key manifest.Key, so the manifests are backed up to every storage like
+// the persistentvolumeclaims. The executor is scheduled to the k8s node of any pod
+// in pvcpvMap.
+func addManifests(backupObj *storagev1alpha1.Backup, pvcpvMap map[string]pvdataMeta) error {
+	backupFrom := backupObj.Spec.BackupFrom
+	data, err := manifest.Collect(backupObj.GetNamespace(), backupFrom.Resource, backupFrom.Name, backupObj.Spec.Manifests)
+	if err != nil {
+		return err
+	}
+	meta := pvdataMeta{manifests: data}
+	for _, m := range pvcpvMap {
+		if len(m.nodeName) != 0 {
+			meta.nodeName = m.nodeName
+			break
+		}
+	}
+	pvcpvMap[manifest.Key] = meta
+	return nil
+}
+
+// executeManifestBackup writes the manifests to `restic backup --stdin` executed in
+// the executor pod, the manifests are saved as the file manifest.Filename in the
+// restic snapshot. The retention policy is applied after backup succeeded.
+func (r *backupRun) executeManifestBackup(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, execPod *corev1.Pod, key string, meta pvdataMeta) (*restic.NodeBackupSummary, error) {
+	clusterName := snapshotHost(backupObj)
+	tags := snapshotTags(backupObj, key)
+	rj := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, true))
+	cmdBackup := rj.Command(res.Backup{Tag: tags, Host: clusterName, Stdin: true, StdinFilename: manifest.Filename}).String()
+	if err := r.ensureRepository(execPod); err != nil {
+		return nil, err
+	}
+	r.logger.Debug(cmdBackup)
+	progress := newBackupProgress(r.logger, key)
+	lock := r.repoLock()
+	lock.RLock()
+	err := podHandler.WithNamespace(execPod.GetNamespace()).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdBackup, " "), bytes.NewReader(meta.manifests), progress, progress)
+	lock.RUnlock()
+	progress.Close()
+	if err != nil {
+		return nil, fmt.Errorf("restic backup manifests failed: %s: %s", err.Error(), progress.Stderr())
+	}
+
+	summary := progress.Summary()
+	if summary == nil {
+		return nil, errSummaryNotFound
+	}
+	if err := r.applyRetention(backupObj, deployObj, clusterName, tags); err != nil {
+		r.logger.Warnf("apply retention for %s failed: %s", key, err.Error())
+	}
+	return summary, nil
+}
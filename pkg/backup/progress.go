@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// progressInterval is the min interval to log the progress of `restic backup`.
+	progressInterval = 30 * time.Second
+	// progressFPS is the frequency restic reports the progress of `restic backup --json`,
+	// restic reports it 60 times per second by default.
+	progressFPS = "0.2"
+	// maxStderrLines is the max lines of the restic errors kept by backupProgress.
+	maxStderrLines = 20
+)
+
+// backupProgress parses the output of `restic backup --json` line by line. It logs
+// the status messages at most once per progressInterval, and keeps the summary
+// message and the last maxStderrLines restic errors. The lines not in json are the
+// errors restic written to stderr, such as "Fatal: unable to open config file".
+// It's safe to write the stdout and stderr of restic to it concurrently.
+type backupProgress struct {
+	logger *logrus.Entry
+	name   string
+
+	mu      sync.Mutex
+	buf     []byte
+	last    time.Time
+	summary *restic.NodeBackupSummary
+	errors  []string
+}
+
+// newBackupProgress returns the backupProgress of the backup named name, such as "pvc/data".
+func newBackupProgress(logger *logrus.Entry, name string) *backupProgress {
+	return &backupProgress{logger: logger, name: name, last: time.Now()}
+}
+
+// Write implements io.Writer, the incomplete line is kept until the next write.
+func (p *backupProgress) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.parseLine(p.buf[:i])
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Close parses the last line not ended with newline.
+func (p *backupProgress) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.parseLine(p.buf)
+	p.buf = nil
+	return nil
+}
+
+// Summary returns the summary message, nil if restic didn't report it.
+func (p *backupProgress) Summary() *restic.NodeBackupSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.summary
+}
+
+// Stderr returns the restic errors, one error per line.
+func (p *backupProgress) Stderr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return strings.Join(p.errors, "\n")
+}
+
+func (p *backupProgress) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	message := struct {
+		MessageType string `json:"message_type"`
+	}{}
+	if line[0] != '{' || json.Unmarshal(line, &message) != nil {
+		p.addError(string(line))
+		return
+	}
+	switch message.MessageType {
+	case "status":
+		status := restic.NodeBackupStatus{}
+		if err := json.Unmarshal(line, &status); err != nil || time.Since(p.last) < progressInterval {
+			return
+		}
+		p.last = time.Now()
+		p.logger.Infof("Backup %s progress: %.1f%%, %d/%d files, %s/%s, %d errors, %ds remaining", p.name,
+			status.PercentDone*100, status.FilesDone, status.TotalFiles,
+			formatBytes(status.BytesDone), formatBytes(status.TotalBytes), status.ErrorCount, status.SecondsRemaining)
+	case "summary":
+		summary := &restic.NodeBackupSummary{}
+		if err := json.Unmarshal(line, summary); err == nil {
+			p.summary = summary
+		}
+	case "error":
+		backupErr := restic.NodeBackupError{}
+		if err := json.Unmarshal(line, &backupErr); err != nil {
+			return
+		}
+		p.addError(fmt.Sprintf("error during %s %s: %s", backupErr.During, backupErr.Item, formatError(backupErr.Error)))
+	}
+}
+
+func (p *backupProgress) addError(line string) {
+	p.errors = append(p.errors, line)
+	if len(p.errors) > maxStderrLines {
+		p.errors = p.errors[len(p.errors)-maxStderrLines:]
+	}
+}
+
+// formatError returns the message of the json encoded go error reported by restic,
+// the error is encoded as {"message": "..."} by the newer restic, or the fields
+// of the error type, such as *os.PathError.
+func formatError(data json.RawMessage) string {
+	message := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(data, &message); err == nil && len(message.Message) != 0 {
+		return message.Message
+	}
+	return string(data)
+}
+
+// formatBytes returns the human readable bytes, such as "1536Mi".
+func formatBytes(n uint64) string {
+	return resource.NewQuantity(int64(n), resource.BinarySI).String()
+}
+
+// withProgressFPS returns the restic command executed with the environment variable
+// RESTIC_PROGRESS_FPS, so restic reports the progress at the frequency progressFPS.
+func withProgressFPS(command []string) []string {
+	return append([]string{"env", "RESTIC_PROGRESS_FPS=" + progressFPS}, command...)
+}
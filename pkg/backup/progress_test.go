@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// the output of `restic backup --json` of restic 0.14 and 0.16.
+const (
+	resticStatusLine  = `{"message_type":"status","seconds_elapsed":2,"seconds_remaining":2,"percent_done":0.5,"total_files":4,"files_done":2,"total_bytes":2048,"bytes_done":1024,"current_files":["/data/a"]}`
+	resticSummaryLine = `{"message_type":"summary","files_new":2,"files_changed":1,"files_unmodified":1,"dirs_new":1,"dirs_changed":0,"dirs_unmodified":0,"data_blobs":3,"tree_blobs":1,"data_added":1234,"total_files_processed":4,"total_bytes_processed":2048,"total_duration":0.52,"snapshot_id":"2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d"}`
+	// restic 0.14 encodes the go error by its fields.
+	resticErrorLine = `{"message_type":"error","error":{"Op":"open","Path":"/data/secret","Err":13},"during":"archival","item":"/data/secret"}`
+	// restic 0.16+ encodes the go error as message.
+	resticErrorMessageLine = `{"message_type":"error","error":{"message":"open /data/secret: permission denied"},"during":"archival","item":"/data/secret"}`
+	resticFatalLine        = `Fatal: unable to open config file: Stat: stat /repo/config: no such file or directory`
+	resticWarningLine      = `Warning: at least one source file could not be read`
+)
+
+func TestBackupProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		// chunks are written to backupProgress one by one.
+		chunks       []string
+		wantSnapshot string
+		wantStderr   []string
+		wantLog      string
+	}{
+		{
+			name:         "success",
+			chunks:       []string{resticStatusLine + "\n" + resticSummaryLine + "\n"},
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+			wantLog:      "Backup pvc/data progress: 50.0%, 2/4 files, 1Ki/2Ki, 0 errors, 2s remaining",
+		},
+		{
+			name: "lines split across writes",
+			chunks: []string{
+				resticStatusLine[:10], resticStatusLine[10:] + "\n" + resticSummaryLine[:20],
+				resticSummaryLine[20:], "\n",
+			},
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+		},
+		{
+			name:         "partial last line parsed by Close",
+			chunks:       []string{resticStatusLine + "\n", resticSummaryLine},
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+		},
+		{
+			name:   "truncated summary",
+			chunks: []string{resticStatusLine + "\n", resticSummaryLine[:50]},
+			// the truncated line is not json, it's kept as restic error.
+			wantStderr: []string{resticSummaryLine[:50]},
+		},
+		{
+			// restic exits with 3, the snapshot is saved but some files can't be read.
+			name: "exit code 3",
+			chunks: []string{
+				resticStatusLine + "\n",
+				resticErrorLine + "\n",
+				resticErrorMessageLine + "\n",
+				resticSummaryLine + "\n",
+				resticWarningLine + "\n",
+			},
+			wantSnapshot: "2f5d9a1c7e8b4d3a9f0e1c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d",
+			wantStderr: []string{
+				`error during archival /data/secret: {"Op":"open","Path":"/data/secret","Err":13}`,
+				"error during archival /data/secret: open /data/secret: permission denied",
+				resticWarningLine,
+			},
+		},
+		{
+			name:       "repository not exist",
+			chunks:     []string{resticFatalLine + "\n", "Is there a repository at the following location?\r\n", "/repo"},
+			wantStderr: []string{resticFatalLine, "Is there a repository at the following location?", "/repo"},
+		},
+		{
+			name:   "unknown message type ignored",
+			chunks: []string{`{"message_type":"verbose_status","action":"new","item":"/data/a"}` + "\n\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := new(bytes.Buffer)
+			l := logrus.New()
+			l.SetOutput(output)
+			p := newBackupProgress(logrus.NewEntry(l), "pvc/data")
+			// log the first status message.
+			p.last = time.Now().Add(-progressInterval)
+			for _, chunk := range tt.chunks {
+				if n, err := p.Write([]byte(chunk)); err != nil || n != len(chunk) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			p.Close()
+
+			var snapshot string
+			if summary := p.Summary(); summary != nil {
+				snapshot = summary.SnapshotID
+			}
+			if snapshot != tt.wantSnapshot {
+				t.Errorf("snapshot = %q, want %q", snapshot, tt.wantSnapshot)
+			}
+			if stderr, want := p.Stderr(), strings.Join(tt.wantStderr, "\n"); stderr != want {
+				t.Errorf("Stderr() = %q, want %q", stderr, want)
+			}
+			if len(tt.wantLog) != 0 && !strings.Contains(output.String(), tt.wantLog) {
+				t.Errorf("log %q doesn't contain %q", output.String(), tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestBackupProgressStderrLimit(t *testing.T) {
+	p := newBackupProgress(logrus.NewEntry(logrus.New()), "pvc/data")
+	for i := 0; i < maxStderrLines+5; i++ {
+		p.Write([]byte("error line\n"))
+	}
+	p.Write([]byte("the last error"))
+	p.Close()
+	lines := strings.Split(p.Stderr(), "\n")
+	if len(lines) != maxStderrLines {
+		t.Fatalf("got %d lines, want %d", len(lines), maxStderrLines)
+	}
+	if lines[len(lines)-1] != "the last error" {
+		t.Fatalf("the last line = %q", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	res "github.com/forbearing/restic"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// forgetForBackup construct the `restic forget` flags from Backup.spec.retention
+// and Backup.spec.retentionPolicy, returns nil if no retention defined, which
+// means keep all snapshots.
+func forgetForBackup(backupObj *storagev1alpha1.Backup) *res.Forget {
+	forget := res.Forget{KeepLast: int(backupObj.Spec.Retention)}
+	if policy := backupObj.Spec.RetentionPolicy; policy != nil {
+		forget.KeepHourly = int(policy.KeepHourly)
+		forget.KeepDaily = int(policy.KeepDaily)
+		forget.KeepWeekly = int(policy.KeepWeekly)
+		forget.KeepMonthly = int(policy.KeepMonthly)
+		forget.KeepYearly = int(policy.KeepYearly)
+		forget.KeepWithin = policy.KeepWithin
+	}
+	if forget.KeepLast == 0 && forget.KeepHourly == 0 && forget.KeepDaily == 0 && forget.KeepWeekly == 0 &&
+		forget.KeepMonthly == 0 && forget.KeepYearly == 0 && len(forget.KeepWithin) == 0 {
+		return nil
+	}
+	return &forget
+}
+
+// retentionForget returns the `restic forget --prune` flags to remove the snapshots
+// with the host and tags not matched the retention policy, returns nil if no retention
+// defined. The snapshots are grouped by host and tags, so the retention is applied to
+// every persistentvolumeclaim separately.
+func retentionForget(backupObj *storagev1alpha1.Backup, host string, tags []string) *res.Forget {
+	forget := forgetForBackup(backupObj)
+	if forget == nil {
+		return nil
+	}
+	forget.Host = []string{host}
+	forget.Tag = tags
+	forget.GroupBy = "host,tags"
+	forget.Prune = true
+	return forget
+}
+
+// applyRetention runs `restic forget --prune` in the executor job to remove the
+// snapshots of the persistentvolumeclaim not matched the retention policy.
+// Only the snapshots with the same host and tags as the backup are considered,
+// so the retention is applied to every persistentvolumeclaim separately.
+// The job is converted from the executor deployment deployObj.
+func (r *backupRun) applyRetention(backupObj *storagev1alpha1.Backup, deployObj *appsv1.Deployment, host string, tags []string) error {
+	forget := retentionForget(backupObj, host, tags)
+	if forget == nil {
+		return nil
+	}
+	// snapshots can't be removed from the append-only repository.
+	executor := &deployObj.Spec.Template
+	if appendOnly, _ := strconv.ParseBool(executor.GetAnnotations()[types.AnnotationAppendOnly]); appendOnly {
+		r.logger.Debugf("restic repository is append-only, skip retention")
+		return nil
+	}
+
+	rc := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(executor, false))
+	cmdForget := rc.Command(*forget).String()
+	r.logger.Debug(cmdForget)
+	// `restic forget --prune` requires the exclusive repository lock.
+	lock := r.repoLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if _, err := r.runJob(executorJob(backupObj, deployObj, strings.Split(cmdForget, " ")), nil); err != nil {
+		return errors.Wrap(err, "restic forget failed")
+	}
+	return nil
+}
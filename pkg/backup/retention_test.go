@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	res "github.com/forbearing/restic"
+)
+
+func TestForgetForBackup(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   storagev1alpha1.BackupSpec
+		expect *res.Forget
+	}{
+		{
+			name: "no retention keeps all snapshots",
+		},
+		{
+			name:   "retention",
+			spec:   storagev1alpha1.BackupSpec{Retention: 5},
+			expect: &res.Forget{KeepLast: 5},
+		},
+		{
+			name: "empty retention policy",
+			spec: storagev1alpha1.BackupSpec{RetentionPolicy: &storagev1alpha1.RetentionPolicy{}},
+		},
+		{
+			name: "retention policy",
+			spec: storagev1alpha1.BackupSpec{
+				Retention: 3,
+				RetentionPolicy: &storagev1alpha1.RetentionPolicy{
+					KeepHourly:  24,
+					KeepDaily:   7,
+					KeepWeekly:  4,
+					KeepMonthly: 12,
+					KeepYearly:  2,
+					KeepWithin:  "1y2m3d",
+				},
+			},
+			expect: &res.Forget{
+				KeepLast:    3,
+				KeepHourly:  24,
+				KeepDaily:   7,
+				KeepWeekly:  4,
+				KeepMonthly: 12,
+				KeepYearly:  2,
+				KeepWithin:  "1y2m3d",
+			},
+		},
+		{
+			name:   "keep within only",
+			spec:   storagev1alpha1.BackupSpec{RetentionPolicy: &storagev1alpha1.RetentionPolicy{KeepWithin: "7d"}},
+			expect: &res.Forget{KeepWithin: "7d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forget := forgetForBackup(&storagev1alpha1.Backup{Spec: tt.spec})
+			if !reflect.DeepEqual(forget, tt.expect) {
+				t.Fatalf("forgetForBackup() = %+v, want %+v", forget, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRetentionForget(t *testing.T) {
+	tags := []string{"deployment", "test", "nginx", "data"}
+	if forget := retentionForget(&storagev1alpha1.Backup{}, "kubernetes", tags); forget != nil {
+		t.Fatalf("retentionForget() = %+v, want nil", forget)
+	}
+
+	backupObj := &storagev1alpha1.Backup{Spec: storagev1alpha1.BackupSpec{
+		Retention:       3,
+		RetentionPolicy: &storagev1alpha1.RetentionPolicy{KeepDaily: 7, KeepWithin: "1m"},
+	}}
+	forget := retentionForget(backupObj, "kubernetes", tags)
+	if forget == nil {
+		t.Fatal("retentionForget() = nil")
+	}
+	rc := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(nil, false))
+	expect := "restic --no-cache forget --keep-last=3 --keep-daily=7 --keep-within=1m " +
+		"--host=kubernetes --tag=deployment,test,nginx,data --group-by=host,tags --prune"
+	if cmd := rc.Command(*forget).String(); cmd != expect {
+		t.Fatalf("restic forget command = %q, want %q", cmd, expect)
+	}
+}
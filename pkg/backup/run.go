@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"sort"
+	"sync"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// backupRun is the context of one run of Do, it replaces the package level state,
+// so the persistentvolumeclaims can be backed up concurrently.
+// The copies returned by withStorage share the same runState.
+type backupRun struct {
+	logger *logrus.Entry
+	// storage is the storage the persistentvolumeclaim backed up to, it's only
+	// set for the copies returned by withStorage.
+	storage types.Storage
+	state   *runState
+}
+
+// runState is the state shared by all the goroutines of the run.
+type runState struct {
+	mu sync.Mutex
+	// executors are the jobs and pods created by the run, they're deleted when the run finished.
+	executors map[executorKey]bool
+	// repoLocks serializes the restic commands that require the exclusive repository
+	// lock, such as `restic init` and `restic forget --prune`, with `restic backup`.
+	repoLocks map[types.Storage]*sync.RWMutex
+	// pvcMounter is the pod mounts the persistentvolumeclaim to backup temporarily,
+	// it's deleted after the target backup finished.
+	pvcMounter *executorKey
+
+	// anchorMu guards anchors, it's not mu, creating an anchor may block the other
+	// goroutines long.
+	anchorMu sync.Mutex
+	// anchors are the configmaps own the executors of the run in every namespace,
+	// see executorAnchor.
+	anchors map[string]*corev1.ConfigMap
+}
+
+type executorKey struct {
+	namespace string
+	name      string
+	// job is true if the executor is a job, otherwise it's a pod.
+	job bool
+}
+
+func newBackupRun(logger *logrus.Entry) *backupRun {
+	return &backupRun{
+		logger: logger,
+		state: &runState{
+			executors: make(map[executorKey]bool),
+			repoLocks: make(map[types.Storage]*sync.RWMutex),
+			anchors:   make(map[string]*corev1.ConfigMap),
+		},
+	}
+}
+
+// withStorage returns a copy of the run to backup to the storage.
+func (r *backupRun) withStorage(storage types.Storage) *backupRun {
+	return &backupRun{
+		logger:  r.logger.WithField("storage", storage),
+		storage: storage,
+		state:   r.state,
+	}
+}
+
+// withLogger returns a copy of the run with the logger.
+func (r *backupRun) withLogger(logger *logrus.Entry) *backupRun {
+	return &backupRun{logger: logger, storage: r.storage, state: r.state}
+}
+
+// addExecutor records the executor created by the run.
+func (r *backupRun) addExecutor(key executorKey) {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	r.state.executors[key] = true
+}
+
+// deleteExecutor deletes the executor created by the run.
+func (r *backupRun) deleteExecutor(key executorKey) {
+	r.state.mu.Lock()
+	delete(r.state.executors, key)
+	r.state.mu.Unlock()
+	if key.job {
+		// the job handler deletes the job pods in background.
+		if err := jobHandler.WithNamespace(key.namespace).Delete(key.name); err != nil && !apierrors.IsNotFound(err) {
+			r.logger.Warnf("delete job/%s failed: %s", key.name, err.Error())
+		}
+		return
+	}
+	if err := podHandler.WithNamespace(key.namespace).Delete(key.name); err != nil && !apierrors.IsNotFound(err) {
+		r.logger.Warnf("delete pod/%s failed: %s", key.name, err.Error())
+	}
+}
+
+// cleanup deletes all the executors and executor anchors created by the run.
+func (r *backupRun) cleanup() {
+	r.state.mu.Lock()
+	keys := make([]executorKey, 0, len(r.state.executors))
+	for key := range r.state.executors {
+		keys = append(keys, key)
+	}
+	r.state.mu.Unlock()
+	for _, key := range keys {
+		r.deleteExecutor(key)
+	}
+	r.deleteExecutorAnchors()
+}
+
+// repoLock returns the lock of the restic repository on the storage.
+func (r *backupRun) repoLock() *sync.RWMutex {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	lock, ok := r.state.repoLocks[r.storage]
+	if !ok {
+		lock = new(sync.RWMutex)
+		r.state.repoLocks[r.storage] = lock
+	}
+	return lock
+}
+
+// backupTask backup one persistentvolumeclaim, database dump or manifests to one storage.
+type backupTask struct {
+	storage types.Storage
+	pvc     string
+	meta    pvdataMeta
+}
+
+// laneKey returns the key of the lane the task belongs to. The tasks in the same lane
+// are executed one by one, the lanes are executed concurrently.
+//   - the persistentvolumeclaim restic repository can only be mounted by one executor,
+//     all its tasks are in one lane.
+//   - the VolumeSnapshot and the executor of the persistentvolumeclaim backed up from
+//     VolumeSnapshot are named by the persistentvolumeclaim, its tasks to all storages are
+//     in one lane. If backup to the persistentvolumeclaim restic repository too, all
+//     the tasks are in one lane.
+//   - the other tasks run in their own executors, every task is a lane.
+func laneKey(backupObj *storagev1alpha1.Backup, task backupTask, hasPVCStorage bool) string {
+	fromSnapshot := backupObj.Spec.VolumeSnapshot != nil && backupObj.Spec.Dump == nil && task.meta.manifests == nil
+	switch {
+	case task.storage == types.StoragePVC, fromSnapshot && hasPVCStorage:
+		return string(types.StoragePVC)
+	case fromSnapshot:
+		return "snapshot/" + task.pvc
+	default:
+		return string(task.storage) + "/" + task.pvc
+	}
+}
+
+// backupLanes groups the tasks of every persistentvolumeclaim to every storage into lanes.
+func backupLanes(backupObj *storagev1alpha1.Backup, pvcpvMap map[string]pvdataMeta) [][]backupTask {
+	pvcs := make([]string, 0, len(pvcpvMap))
+	for pvc := range pvcpvMap {
+		pvcs = append(pvcs, pvc)
+	}
+	sort.Strings(pvcs)
+	storages := ParseStorage(backupObj)
+	hasPVCStorage := false
+	for _, storage := range storages {
+		if storage == types.StoragePVC {
+			hasPVCStorage = true
+		}
+	}
+
+	var keys []string
+	lanes := make(map[string][]backupTask)
+	for _, storage := range storages {
+		for _, pvc := range pvcs {
+			task := backupTask{storage: storage, pvc: pvc, meta: pvcpvMap[pvc]}
+			key := laneKey(backupObj, task, hasPVCStorage)
+			if _, ok := lanes[key]; !ok {
+				keys = append(keys, key)
+			}
+			lanes[key] = append(lanes[key], task)
+		}
+	}
+	result := make([][]backupTask, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, lanes[key])
+	}
+	return result
+}
+
+// maxConcurrency returns the max number of lanes executed concurrently, default to 1.
+func maxConcurrency(backupObj *storagev1alpha1.Backup) int {
+	if backupObj.Spec.MaxConcurrency < 1 {
+		return 1
+	}
+	return int(backupObj.Spec.MaxConcurrency)
+}
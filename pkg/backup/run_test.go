@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+)
+
+func TestLaneKey(t *testing.T) {
+	snapshotSpec := storagev1alpha1.BackupSpec{VolumeSnapshot: &storagev1alpha1.VolumeSnapshotOptions{}}
+	tests := []struct {
+		name          string
+		spec          storagev1alpha1.BackupSpec
+		task          backupTask
+		hasPVCStorage bool
+		expect        string
+	}{
+		{
+			name:   "every pvc to every storage is a lane",
+			task:   backupTask{storage: types.StorageNFS, pvc: "data"},
+			expect: "nfs/data",
+		},
+		{
+			name:          "pvc storage is one lane",
+			task:          backupTask{storage: types.StoragePVC, pvc: "data"},
+			hasPVCStorage: true,
+			expect:        "pvc",
+		},
+		{
+			name:          "the other storages are not affected by pvc storage",
+			task:          backupTask{storage: types.StorageS3, pvc: "data"},
+			hasPVCStorage: true,
+			expect:        "s3/data",
+		},
+		{
+			name:   "volume snapshot of the pvc is one lane",
+			spec:   snapshotSpec,
+			task:   backupTask{storage: types.StorageS3, pvc: "data"},
+			expect: "snapshot/data",
+		},
+		{
+			name:          "volume snapshot with pvc storage is one lane",
+			spec:          snapshotSpec,
+			task:          backupTask{storage: types.StorageS3, pvc: "data"},
+			hasPVCStorage: true,
+			expect:        "pvc",
+		},
+		{
+			name:   "manifests are not backed up from volume snapshot",
+			spec:   snapshotSpec,
+			task:   backupTask{storage: types.StorageS3, pvc: "manifests", meta: pvdataMeta{manifests: []byte("---")}},
+			expect: "s3/manifests",
+		},
+		{
+			name: "database dump is not backed up from volume snapshot",
+			spec: storagev1alpha1.BackupSpec{
+				VolumeSnapshot: &storagev1alpha1.VolumeSnapshotOptions{},
+				Dump:           &storagev1alpha1.DatabaseDump{Type: storagev1alpha1.DatabaseMySQL},
+			},
+			task:   backupTask{storage: types.StorageMinIO, pvc: "mysql"},
+			expect: "minio/mysql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := laneKey(&storagev1alpha1.Backup{Spec: tt.spec}, tt.task, tt.hasPVCStorage)
+			if key != tt.expect {
+				t.Fatalf("laneKey() = %q, want %q", key, tt.expect)
+			}
+		})
+	}
+}
+
+func TestBackupLanes(t *testing.T) {
+	pvcpvMap := map[string]pvdataMeta{"logs": {}, "data": {}}
+	backupTo := &storagev1alpha1.BackupTo{
+		NFS: &storagev1alpha1.NFS{},
+		PVC: &storagev1alpha1.PVC{},
+		S3:  &storagev1alpha1.S3{},
+	}
+	tests := []struct {
+		name   string
+		spec   storagev1alpha1.BackupSpec
+		expect [][]string
+	}{
+		{
+			name: "file level backup",
+			spec: storagev1alpha1.BackupSpec{BackupTo: backupTo},
+			expect: [][]string{
+				{"nfs/data"},
+				{"nfs/logs"},
+				{"pvc/data", "pvc/logs"},
+				{"s3/data"},
+				{"s3/logs"},
+			},
+		},
+		{
+			name:   "volume snapshot with pvc storage",
+			spec:   storagev1alpha1.BackupSpec{BackupTo: backupTo, VolumeSnapshot: &storagev1alpha1.VolumeSnapshotOptions{}},
+			expect: [][]string{{"nfs/data", "nfs/logs", "pvc/data", "pvc/logs", "s3/data", "s3/logs"}},
+		},
+		{
+			name: "volume snapshot",
+			spec: storagev1alpha1.BackupSpec{
+				BackupTo:       &storagev1alpha1.BackupTo{NFS: &storagev1alpha1.NFS{}, S3: &storagev1alpha1.S3{}},
+				VolumeSnapshot: &storagev1alpha1.VolumeSnapshotOptions{},
+			},
+			expect: [][]string{
+				{"nfs/data", "s3/data"},
+				{"nfs/logs", "s3/logs"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lanes [][]string
+			for _, lane := range backupLanes(&storagev1alpha1.Backup{Spec: tt.spec}, pvcpvMap) {
+				var tasks []string
+				for _, task := range lane {
+					tasks = append(tasks, string(task.storage)+"/"+task.pvc)
+				}
+				lanes = append(lanes, tasks)
+			}
+			if !reflect.DeepEqual(lanes, tt.expect) {
+				t.Fatalf("backupLanes() = %v, want %v", lanes, tt.expect)
+			}
+		})
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	for concurrency, expect := range map[int32]int{0: 1, 1: 1, 4: 4} {
+		if n := maxConcurrency(&storagev1alpha1.Backup{Spec: storagev1alpha1.BackupSpec{MaxConcurrency: concurrency}}); n != expect {
+			t.Fatalf("maxConcurrency(%d) = %d, want %d", concurrency, n, expect)
+		}
+	}
+}
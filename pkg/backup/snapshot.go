@@ -0,0 +1,236 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/dump"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// snapshotName is the name prefix of the VolumeSnapshot, the temporary
+	// persistentvolumeclaim and the executor.
+	snapshotName       = "horus-snapshot"
+	snapshotVolumeName = "snapshot-data"
+	// snapshotMountPath is where the temporary persistentvolumeclaim mounted in
+	// the executor, snapshotDevicePath is used if it's a block volume.
+	snapshotMountPath  = "/snapshot-data"
+	snapshotDevicePath = "/dev/snapshot-data"
+
+	defaultSnapshotReadyTimeout = 10 * time.Minute
+)
+
+var volumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+// executeSnapshotBackup takes a VolumeSnapshot of the persistentvolumeclaim, provisions
+// a temporary persistentvolumeclaim from it, and backup the temporary persistentvolumeclaim
+// within the executor created in the namespace of the persistentvolumeclaim.
+// All the temporary objects are deleted after backup.
+func (r *backupRun) executeSnapshotBackup(backupObj *storagev1alpha1.Backup, pvc string, meta pvdataMeta) (*restic.NodeBackupSummary, error) {
+	if len(meta.podName) == 0 {
+		return nil, fmt.Errorf("pv/%s is not bound to any pvc, can't take volume snapshot", pvc)
+	}
+	namespace := meta.podNamespace
+	// persistentvolumeclaim can only be mounted by pods in the same namespace.
+	if r.storage == types.StoragePVC && namespace != util.GetOperatorNamespace() {
+		return nil, fmt.Errorf("backup volume snapshot to pvc only supported in namespace %s", util.GetOperatorNamespace())
+	}
+	options := backupObj.Spec.VolumeSnapshot
+	timeout := defaultSnapshotReadyTimeout
+	if options.ReadyTimeout != nil && options.ReadyTimeout.Duration > 0 {
+		timeout = options.ReadyTimeout.Duration
+	}
+	name := fmt.Sprintf("%s-%s-%s", snapshotName, backupObj.GetName(), pvc)
+	snapshotHandler := dynHandler.WithNamespace(namespace).WithGVK(volumeSnapshotGVK)
+
+	// ==============================
+	// 1. take the VolumeSnapshot of the persistentvolumeclaim
+	// ==============================
+	pvcObj, err := pvcHandler.WithNamespace(namespace).Get(pvc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", pvc)
+	}
+	// the objects left by the last failed backup.
+	cleanupSnapshot(namespace, name)
+	defer cleanupSnapshot(namespace, name)
+	if _, err := snapshotHandler.Create(newVolumeSnapshot(namespace, name, pvc, options.VolumeSnapshotClassName)); err != nil {
+		return nil, errors.Wrapf(err, "create volumesnapshot/%s failed", name)
+	}
+	restoreSize, err := waitSnapshotReady(namespace, name, timeout)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Infof("Successfully take volumesnapshot/%s of pvc/%s", name, pvc)
+
+	// ==============================
+	// 2. provision the temporary persistentvolumeclaim from the VolumeSnapshot
+	// ==============================
+	if _, err := pvcHandler.WithNamespace(namespace).Create(newSnapshotPVC(pvcObj, name, options.StorageClassName, restoreSize)); err != nil {
+		return nil, errors.Wrapf(err, "persistentvolumeclaim handler create pvc/%s failed", name)
+	}
+	isBlock := pvcObj.Spec.VolumeMode != nil && *pvcObj.Spec.VolumeMode == corev1.PersistentVolumeBlock
+
+	// ==============================
+	// 3. create the executor mounts the temporary persistentvolumeclaim read-only
+	// ==============================
+	deployObj, err := ExecutorDeployment(backupObj, r.storage, name, "")
+	if err != nil {
+		return nil, err
+	}
+	deployObj.SetNamespace(namespace)
+	// the executor is labeled by the VolumeSnapshot name.
+	labels := map[string]string{
+		types.LabelName:      snapshotName,
+		types.LabelInstance:  name,
+		types.LabelPartOf:    "horus",
+		types.LabelManagedBy: "horus-operator",
+	}
+	deployObj.SetLabels(labels)
+	deployObj.Spec.Template.SetLabels(labels)
+	podSpec := &deployObj.Spec.Template.Spec
+	RemoveHostRoot(podSpec)
+	// secret can only be referenced by pods in the same namespace.
+	if err := CopyCredential(podSpec, namespace, name); err != nil {
+		return nil, err
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: snapshotVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name, ReadOnly: true},
+		},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if isBlock {
+			container.VolumeDevices = append(container.VolumeDevices, corev1.VolumeDevice{Name: snapshotVolumeName, DevicePath: snapshotDevicePath})
+		} else {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: snapshotVolumeName, MountPath: snapshotMountPath, ReadOnly: true})
+		}
+	}
+
+	// ==============================
+	// 4. backup the temporary persistentvolumeclaim
+	// ==============================
+	// the block volume is streamed to `restic backup --stdin` executed in the executor pod.
+	if isBlock {
+		execPod, err := r.createExecutorPod(executorPod(backupObj, deployObj))
+		if err != nil {
+			return nil, err
+		}
+		defer r.deleteExecutor(executorKey{namespace: namespace, name: execPod.GetName()})
+		return r.resticBackupStream(backupObj, deployObj, execPod, pvc, execPod, "", dump.Exec{Command: []string{"cat", snapshotDevicePath}}, pvc+".img")
+	}
+	return r.resticBackup(backupObj, deployObj, pvc, meta.volumeName, snapshotMountPath)
+}
+
+// newVolumeSnapshot returns the VolumeSnapshot of the persistentvolumeclaim.
+func newVolumeSnapshot(namespace, name, pvc, className string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{"persistentVolumeClaimName": pvc},
+	}
+	if len(className) != 0 {
+		spec["volumeSnapshotClassName"] = className
+	}
+	snapshotObj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	snapshotObj.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshotObj.SetName(name)
+	snapshotObj.SetNamespace(namespace)
+	util.SetRecommendedLabels(snapshotObj)
+	return snapshotObj
+}
+
+// newSnapshotPVC returns the temporary persistentvolumeclaim provisioned from the VolumeSnapshot,
+// it has the same access modes and volume mode as the persistentvolumeclaim to backup.
+func newSnapshotPVC(pvcObj *corev1.PersistentVolumeClaim, name, storageClassName string, restoreSize *resource.Quantity) *corev1.PersistentVolumeClaim {
+	apiGroup := volumeSnapshotGVK.Group
+	spec := corev1.PersistentVolumeClaimSpec{
+		AccessModes:      pvcObj.Spec.AccessModes,
+		VolumeMode:       pvcObj.Spec.VolumeMode,
+		StorageClassName: pvcObj.Spec.StorageClassName,
+		Resources:        *pvcObj.Spec.Resources.DeepCopy(),
+		DataSource: &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     volumeSnapshotGVK.Kind,
+			Name:     name,
+		},
+	}
+	if len(storageClassName) != 0 {
+		spec.StorageClassName = &storageClassName
+	}
+	// the persistentvolumeclaim should be large enough to hold the snapshot data.
+	if restoreSize != nil {
+		if spec.Resources.Requests == nil {
+			spec.Resources.Requests = corev1.ResourceList{}
+		}
+		if request, ok := spec.Resources.Requests[corev1.ResourceStorage]; !ok || request.Cmp(*restoreSize) < 0 {
+			spec.Resources.Requests[corev1.ResourceStorage] = *restoreSize
+		}
+	}
+	snapshotPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pvcObj.GetNamespace(),
+		},
+		Spec: spec,
+	}
+	util.SetRecommendedLabels(snapshotPVC)
+	return snapshotPVC
+}
+
+// waitSnapshotReady waits until the VolumeSnapshot ready to use and returns its restore size.
+func waitSnapshotReady(namespace, name string, timeout time.Duration) (*resource.Quantity, error) {
+	var restoreSize *resource.Quantity
+	handler := dynHandler.WithNamespace(namespace).WithGVK(volumeSnapshotGVK)
+	if err := wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		snapshotObj, err := handler.GetByName(name)
+		if err != nil {
+			return false, errors.Wrapf(err, "get volumesnapshot/%s failed", name)
+		}
+		if message, found, _ := unstructured.NestedString(snapshotObj.Object, "status", "error", "message"); found && len(message) != 0 {
+			logger.Warnf("volumesnapshot/%s error: %s", name, message)
+		}
+		ready, _, _ := unstructured.NestedBool(snapshotObj.Object, "status", "readyToUse")
+		if !ready {
+			return false, nil
+		}
+		if size, found, _ := unstructured.NestedString(snapshotObj.Object, "status", "restoreSize"); found {
+			if quantity, err := resource.ParseQuantity(size); err == nil {
+				restoreSize = &quantity
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "wait volumesnapshot/%s ready to use failed", name)
+	}
+	return restoreSize, nil
+}
+
+// cleanupSnapshot deletes the copied credential secret, the temporary persistentvolumeclaim
+// and the VolumeSnapshot, the executors are deleted by the run.
+func cleanupSnapshot(namespace, name string) {
+	if err := secHandler.WithNamespace(namespace).Delete(name); err != nil && !apierrors.IsNotFound(err) {
+		logger.Warnf("delete secret/%s failed: %s", name, err.Error())
+	}
+	if err := pvcHandler.WithNamespace(namespace).Delete(name); err != nil && !apierrors.IsNotFound(err) {
+		logger.Warnf("delete pvc/%s failed: %s", name, err.Error())
+	}
+	if err := dynHandler.WithNamespace(namespace).WithGVK(volumeSnapshotGVK).DeleteByName(name); err != nil && !apierrors.IsNotFound(err) {
+		logger.Warnf("delete volumesnapshot/%s failed: %s", name, err.Error())
+	}
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"encoding/json"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	reasonBackupStarted      = "BackupStarted"
+	reasonBackupFinished     = "BackupFinished"
+	reasonBackupSucceeded    = "BackupSucceeded"
+	reasonBackupFailed       = "BackupFailed"
+	reasonPrepareFailed      = "PrepareFailed"
+	reasonRepositoryReady    = "RepositoryReady"
+	reasonRepositoryNotReady = "RepositoryNotReady"
+	reasonHookFailed         = "HookFailed"
+)
+
+// getBackup get the Backup object by dynamic handler.
+func getBackup(namespace, name string) (*storagev1alpha1.Backup, error) {
+	gvk := schema.GroupVersionKind{
+		Group:   types.GroupStorage,
+		Version: types.GroupVersionStorage.Version,
+		Kind:    types.KindBackup,
+	}
+	unstructObj, err := dynHandler.WithNamespace(namespace).WithGVK(gvk).Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, `dynamic handler get "%s.%s" resource object failed`, types.ResourceBackup, types.GroupStorage)
+	}
+	backupObj := &storagev1alpha1.Backup{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructObj.UnstructuredContent(), backupObj); err != nil {
+		return nil, errors.Wrapf(err, "convert unstructured object to %s.%s resource object failed", types.ResourceBackup, types.GroupStorage)
+	}
+	return backupObj, nil
+}
+
+// setCondition sets the condition of the Backup status.
+func setCondition(backupObj *storagev1alpha1.Backup, status *storagev1alpha1.BackupStatus,
+	condType storagev1alpha1.BackupConditionType, condStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               string(condType),
+		Status:             condStatus,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: backupObj.GetGeneration(),
+	})
+}
+
+// patchStatus patch the status subresource of the Backup object.
+// dynamic handler doesn't support update status, so we patch it by the dynamic client.
+func patchStatus(backupObj *storagev1alpha1.Backup, status *storagev1alpha1.BackupStatus) error {
+	data, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return errors.Wrap(err, "marshal Backup status failed")
+	}
+	gvr := schema.GroupVersionResource{
+		Group:    types.GroupStorage,
+		Version:  types.GroupVersionStorage.Version,
+		Resource: types.ResourceBackup,
+	}
+	if _, err := dynHandler.DynamicClient().Resource(gvr).Namespace(backupObj.GetNamespace()).
+		Patch(ctx, backupObj.GetName(), apitypes.MergePatchType, data, metav1.PatchOptions{}, "status"); err != nil {
+		return errors.Wrapf(err, "patch %s.%s status failed", types.ResourceBackup, types.GroupStorage)
+	}
+	return nil
+}
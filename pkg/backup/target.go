@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/forbearing/k8s/namespace"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var nsHandler = namespace.NewOrDie(ctx, "")
+
+// resolveTargets returns all the resources to backup defined in Backup.spec.backupFrom.
+// The resource specified by name and resource comes first, then the resources in
+// targets, and the resources matched selector at last. Duplicated targets are removed.
+func resolveTargets(backupObj *storagev1alpha1.Backup) ([]storagev1alpha1.BackupTarget, error) {
+	var (
+		targets    []storagev1alpha1.BackupTarget
+		seen       = make(map[string]bool)
+		backupFrom = backupObj.Spec.BackupFrom
+	)
+	add := func(target storagev1alpha1.BackupTarget) {
+		if len(target.Namespace) == 0 {
+			target.Namespace = backupObj.GetNamespace()
+		}
+		key := fmt.Sprintf("%s/%s/%s", target.Namespace, target.Resource, target.Name)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		targets = append(targets, target)
+	}
+
+	if backupFrom == nil {
+		return nil, errors.New("Backup.spec.backupFrom is required")
+	}
+	if len(backupFrom.Name) != 0 || len(backupFrom.Resource) != 0 {
+		add(storagev1alpha1.BackupTarget{Name: backupFrom.Name, Resource: backupFrom.Resource})
+	}
+	for _, target := range backupFrom.Targets {
+		add(target)
+	}
+	if backupFrom.Selector != nil {
+		selected, err := selectTargets(backupObj)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range selected {
+			add(target)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("no resource matched Backup.spec.backupFrom, skip backup")
+	}
+	for _, target := range targets {
+		if target.Namespace != backupObj.GetNamespace() && !crossNamespaceAllowed(backupObj) {
+			return nil, fmt.Errorf("%s/%s is in namespace %s, only the Backup in namespace %s can backup the resources in other namespaces",
+				target.Resource, target.Name, target.Namespace, util.GetOperatorNamespace())
+		}
+	}
+	return targets, nil
+}
+
+// crossNamespaceAllowed returns true if the Backup object can backup the resources
+// in other namespaces. The restic repository and the credentials are controlled by
+// the author of the Backup object, who could read the data of the namespaces they have
+// no access to, so only the Backup objects in the operator namespace can do it.
+func crossNamespaceAllowed(backupObj *storagev1alpha1.Backup) bool {
+	return backupObj.GetNamespace() == util.GetOperatorNamespace()
+}
+
+// selectTargets finds the deployments, statefulsets, daemonsets and pods matched
+// Backup.spec.backupFrom.selector in the namespaces matched Backup.spec.backupFrom.namespaceSelector.
+// The pods controlled by other resources are ignored, they are backed up by its controller.
+func selectTargets(backupObj *storagev1alpha1.Backup) ([]storagev1alpha1.BackupTarget, error) {
+	backupFrom := backupObj.Spec.BackupFrom
+	selector, err := metav1.LabelSelectorAsSelector(backupFrom.Selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse Backup.spec.backupFrom.selector failed")
+	}
+	// an empty selector matches everything, it's too dangerous to backup all resources.
+	if selector.Empty() {
+		return nil, errors.New("Backup.spec.backupFrom.selector must not be empty")
+	}
+
+	namespaces := []string{backupObj.GetNamespace()}
+	if backupFrom.NamespaceSelector != nil {
+		if !crossNamespaceAllowed(backupObj) {
+			return nil, fmt.Errorf("Backup.spec.backupFrom.namespaceSelector can only be used by the Backup in namespace %s", util.GetOperatorNamespace())
+		}
+		nsSelector, err := metav1.LabelSelectorAsSelector(backupFrom.NamespaceSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse Backup.spec.backupFrom.namespaceSelector failed")
+		}
+		nsObjs, err := nsHandler.ListByLabel(nsSelector.String())
+		if err != nil {
+			return nil, errors.Wrap(err, "namespace handler list namespaces failed")
+		}
+		namespaces = namespaces[:0]
+		for _, nsObj := range nsObjs {
+			namespaces = append(namespaces, nsObj.GetName())
+		}
+	}
+
+	var targets []storagev1alpha1.BackupTarget
+	labelSelector := selector.String()
+	for _, ns := range namespaces {
+		deployObjs, err := depHandler.WithNamespace(ns).ListByLabel(labelSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "deployment handler list deployments in namespace/%s failed", ns)
+		}
+		for _, deployObj := range deployObjs {
+			targets = append(targets, storagev1alpha1.BackupTarget{Namespace: ns, Name: deployObj.GetName(), Resource: storagev1alpha1.DeploymentResource})
+		}
+		stsObjs, err := stsHandler.WithNamespace(ns).ListByLabel(labelSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "statefulset handler list statefulsets in namespace/%s failed", ns)
+		}
+		for _, stsObj := range stsObjs {
+			targets = append(targets, storagev1alpha1.BackupTarget{Namespace: ns, Name: stsObj.GetName(), Resource: storagev1alpha1.StatefulSetResource})
+		}
+		dsObjs, err := dsHandler.WithNamespace(ns).ListByLabel(labelSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "daemonset handler list daemonsets in namespace/%s failed", ns)
+		}
+		for _, dsObj := range dsObjs {
+			targets = append(targets, storagev1alpha1.BackupTarget{Namespace: ns, Name: dsObj.GetName(), Resource: storagev1alpha1.DaemonSetResource})
+		}
+		podObjs, err := podHandler.WithNamespace(ns).ListByLabel(labelSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pod handler list pods in namespace/%s failed", ns)
+		}
+		for _, podObj := range podObjs {
+			if metav1.GetControllerOf(podObj) != nil {
+				continue
+			}
+			targets = append(targets, storagev1alpha1.BackupTarget{Namespace: ns, Name: podObj.GetName(), Resource: storagev1alpha1.PodResource})
+		}
+	}
+	return targets, nil
+}
+
+// targetBackup returns a copy of Backup object that only backup the target.
+func targetBackup(backupObj *storagev1alpha1.Backup, target storagev1alpha1.BackupTarget) *storagev1alpha1.Backup {
+	targetObj := backupObj.DeepCopy()
+	targetObj.SetNamespace(target.Namespace)
+	targetObj.Spec.BackupFrom = &storagev1alpha1.BackupFrom{Name: target.Name, Resource: target.Resource}
+	return targetObj
+}
@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"testing"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveTargetsNamespace(t *testing.T) {
+	targets := []storagev1alpha1.BackupTarget{
+		{Name: "nginx", Resource: storagev1alpha1.DeploymentResource},
+		{Namespace: "test", Name: "mysql", Resource: storagev1alpha1.StatefulSetResource},
+	}
+	tests := []struct {
+		name      string
+		namespace string
+		targets   []storagev1alpha1.BackupTarget
+		expectErr bool
+	}{
+		{
+			name:      "the same namespace",
+			namespace: "test",
+			targets:   targets,
+		},
+		{
+			name:      "other namespace",
+			namespace: "default",
+			targets:   targets,
+			expectErr: true,
+		},
+		{
+			name:      "other namespace in the operator namespace",
+			namespace: types.DefaultOperatorNamespace,
+			targets:   targets,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupObj := &storagev1alpha1.Backup{
+				ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace, Name: "backup"},
+				Spec:       storagev1alpha1.BackupSpec{BackupFrom: &storagev1alpha1.BackupFrom{Targets: tt.targets}},
+			}
+			_, err := resolveTargets(backupObj)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("resolveTargets() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestSelectTargetsNamespaceSelector(t *testing.T) {
+	backupObj := &storagev1alpha1.Backup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "backup"},
+		Spec: storagev1alpha1.BackupSpec{BackupFrom: &storagev1alpha1.BackupFrom{
+			Selector:          &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"backup": "true"}},
+		}},
+	}
+	if _, err := selectTargets(backupObj); err == nil {
+		t.Fatal("selectTargets() selects other namespaces for the Backup not in the operator namespace")
+	}
+}
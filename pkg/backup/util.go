@@ -9,9 +9,12 @@ import (
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
 	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
 	"github.com/forbearing/k8s/deployment"
+	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // theDeployName return a standard deployment name.
@@ -22,51 +25,11 @@ func theDeployName(name string, backupObj *storagev1alpha1.Backup, meta pvdataMe
 	return fmt.Sprintf("%s-%s-%s", name, meta.nodeName, backupObj.GetName())
 }
 
-// filterRunningPod creates the deployment and get its any running status pod.
-// The namespace determine which namespace the deployment object deploy to.
-func filterRunningPod(namespace string, deployData interface{}) (*corev1.Pod, error) {
-	depHandler.ResetNamespace(namespace)
-	rsHandler.ResetNamespace(namespace)
-	podHandler.ResetNamespace(namespace)
-
-	// 1.apply deployment
-	deployObj, err := depHandler.Apply(deployData)
-	if err != nil {
-		return nil, fmt.Errorf("deployment handler Apply failed: %s", err.Error())
-	}
-	// 2.block here and wait the deployment to be available and ready.
-	if err := depHandler.WaitReady(deployObj.GetName()); err != nil {
-		return nil, fmt.Errorf("deployment handler WaitReady %s failed: %s", deployObj.GetName(), err.Error())
-	}
-	// 3.get all pods object owned by the deployment.
-	podObjs, err := depHandler.GetPods(deployObj)
-	if err != nil {
-		return nil, fmt.Errorf("replicaset handler get %s all replicasets failed: %s", deployObj.GetName(), err.Error())
-	}
-	// 4.if the DeletionTimestamp of pod is zero/nil and return it.
-	//   running pods doesn't have DeletionTimestamp field.
-	//   DeletionTimestamp field only set in Terminating status pods.
-	for _, podObj := range podObjs {
-		// if DeletionTimestamp not zero/nil, it means that the pod is Terminating.
-		if !podObj.DeletionTimestamp.IsZero() {
-			continue
-		}
-		// if pod.Status.Phase not PodRunning, such as Pending, it means that the pod is "ContainerCreating",
-		// it's not necessary to check the pod status.phase after call "WaitReady" method.
-		if podObj.Status.Phase != corev1.PodRunning {
-			continue
-		}
-		return podObj, nil
-	}
-	return nil, fmt.Errorf("not found running pod for deployment/%s", deployObj.GetName())
-}
-
 // filterRunningPod2 creates the deployment and get its any running status pod.
 // The namespace determine which namespace the deployment object deploy to.
 func filterRunningPod2(namespace string, deployData interface{}) (*corev1.Pod, error) {
-	depHandler.ResetNamespace(namespace)
-	rsHandler.ResetNamespace(namespace)
-	podHandler.ResetNamespace(namespace)
+	depHandler := depHandler.WithNamespace(namespace)
+	rsHandler := rsHandler.WithNamespace(namespace)
 
 	// 1.apply deployment
 	deployObj, err := depHandler.Apply(deployData)
@@ -112,8 +75,8 @@ func filterRunningPod2(namespace string, deployData interface{}) (*corev1.Pod, e
 	return nil, fmt.Errorf("not found running pod for deployment/%s", deployObj.GetName())
 }
 
-// parseStorage parse the backup.spec.backupTo field to know where we should backup to
-func parseStorage(backupObj *storagev1alpha1.Backup) []types.Storage {
+// ParseStorage parse the backup.spec.backupTo field to know where we should backup to
+func ParseStorage(backupObj *storagev1alpha1.Backup) []types.Storage {
 	t := reflect.TypeOf(backupObj.Spec.BackupTo).Elem()
 	v := reflect.ValueOf(backupObj.Spec.BackupTo).Elem()
 
@@ -164,3 +127,37 @@ func getOrApplyDeployment(handler *deployment.Handler, data []byte) (*appsv1.Dep
 	}
 	return handler.Apply(data)
 }
+
+// syncCredential returns the name of the secret in operator namespace that contains
+// the storage credential and the secret itself.
+// Secret can only be referenced by pods in the same namespace, so the credential
+// secret in other namespace is copied to the operator namespace, prefix is the
+// name prefix of the copied secret.
+func syncCredential(backupObj *storagev1alpha1.Backup, prefix, credentialName, credentialNamespace string) (string, *corev1.Secret, error) {
+	operatorNamespace := util.GetOperatorNamespace()
+	if len(credentialNamespace) == 0 {
+		credentialNamespace = operatorNamespace
+	}
+	secObj, err := secHandler.WithNamespace(credentialNamespace).Get(credentialName)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "secret handler get secret/%s in namespace/%s failed", credentialName, credentialNamespace)
+	}
+	if credentialNamespace == operatorNamespace {
+		return credentialName, secObj, nil
+	}
+
+	name := fmt.Sprintf("%s-%s-credential", prefix, backupObj.GetName())
+	copied := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: operatorNamespace,
+		},
+		Type: secObj.Type,
+		Data: secObj.Data,
+	}
+	util.SetRecommendedLabels(copied)
+	if _, err := secHandler.WithNamespace(operatorNamespace).Apply(copied); err != nil {
+		return "", nil, errors.Wrapf(err, "secret handler apply secret/%s failed", name)
+	}
+	return name, secObj, nil
+}
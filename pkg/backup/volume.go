@@ -0,0 +1,200 @@
+package backup
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// volumePod returns the pod mounts the persistentvolumeclaim defined in Backup.spec.backupFrom,
+// or the persistentvolumeclaim bound to the persistentvolume defined in Backup.spec.backupFrom,
+// and the name of the persistentvolumeclaim.
+//
+// If no running pod mounts the persistentvolumeclaim, such as the persistentvolumeclaim
+// of CronJob, a pod is created to mount it temporarily, and it will
+// be deleted after backup finished.
+// If the persistentvolume is not bound, the returned pod is nil.
+func (r *backupRun) volumePod(backupObj *storagev1alpha1.Backup) (*corev1.Pod, string, error) {
+	namespace := backupObj.GetNamespace()
+	pvc := backupObj.Spec.BackupFrom.Name
+	if backupObj.Spec.BackupFrom.Resource == storagev1alpha1.PersistentVolume {
+		pvObj, err := pvHandler.Get(backupObj.Spec.BackupFrom.Name)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "persistentvolume handler get pv/%s failed", backupObj.Spec.BackupFrom.Name)
+		}
+		if pvObj.Status.Phase != corev1.VolumeBound || pvObj.Spec.ClaimRef == nil {
+			return nil, "", nil
+		}
+		if pvObj.Spec.ClaimRef.Namespace != namespace && !crossNamespaceAllowed(backupObj) {
+			return nil, "", fmt.Errorf("pv/%s is bound to pvc in namespace %s, only the Backup in namespace %s can backup the resources in other namespaces",
+				pvObj.GetName(), pvObj.Spec.ClaimRef.Namespace, util.GetOperatorNamespace())
+		}
+		namespace = pvObj.Spec.ClaimRef.Namespace
+		pvc = pvObj.Spec.ClaimRef.Name
+	}
+
+	pvcObj, err := pvcHandler.WithNamespace(namespace).Get(pvc)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", pvc)
+	}
+	if pvcObj.Status.Phase != corev1.ClaimBound {
+		return nil, "", fmt.Errorf("pvc/%s is not bound, skip backup", pvc)
+	}
+	podObjs, err := podHandler.WithNamespace(namespace).List()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "pod handler list pods failed")
+	}
+	for _, podObj := range podObjs {
+		if podObj.Status.Phase != corev1.PodRunning || !podObj.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, volume := range podObj.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc {
+				return podObj, pvc, nil
+			}
+		}
+	}
+
+	r.logger.Infof("pvc/%s is not mounted by any running pod, mount it temporarily", pvc)
+	deployObj := pvcMounter(backupObj, fmt.Sprintf("%s-%s", pvcMounterName, backupObj.GetName()), pvc, true)
+	deployObj.SetNamespace(namespace)
+	podObj, err := r.createExecutorPod(executorPod(backupObj, deployObj))
+	if err != nil {
+		return nil, "", err
+	}
+	r.state.pvcMounter = &executorKey{namespace: namespace, name: podObj.GetName()}
+	return podObj, pvc, nil
+}
+
+// VolumeDir returns the k8s node and the persistentvolume data directory in the k8s
+// node of the persistentvolumeclaim, restore writes the snapshot data to the directory
+// by the executor pinned to the k8s node.
+//
+// The persistentvolumeclaim is mounted read-write by a temporary pod, so the kubelet
+// mounts the persistentvolume on the k8s node and the findpvdir job can find its data
+// directory. The returned function deletes the pod and the other executors, it should
+// be called by the caller after the data restored.
+func VolumeDir(backupObj *storagev1alpha1.Backup, namespace, pvc string) (string, string, func(), error) {
+	pvname, err := pvcHandler.WithNamespace(namespace).GetPV(pvc)
+	if err != nil {
+		return "", "", nil, errors.Wrapf(err, "persistentvolumeclaim handler get the pv of pvc/%s failed", pvc)
+	}
+	volumeSource, err := pvHandler.GetVolumeSource(pvname)
+	if err != nil {
+		return "", "", nil, errors.Wrapf(err, "persistentvolume handler get the volume source of pv/%s failed", pvname)
+	}
+
+	r := newBackupRun(logger.WithFields(logrus.Fields{"namespace": namespace, "pvc": pvc}))
+	deployObj := pvcMounter(backupObj, fmt.Sprintf("%s-%s", pvcMounterName, backupObj.GetName()), pvc, false)
+	deployObj.SetNamespace(namespace)
+	podObj, err := r.createExecutorPod(executorPod(backupObj, deployObj))
+	if err != nil {
+		r.cleanup()
+		return "", "", nil, err
+	}
+	meta := pvdataMeta{
+		volumeSource: volumeSource,
+		nodeName:     podObj.Spec.NodeName,
+		podName:      podObj.GetName(),
+		podUID:       string(podObj.GetUID()),
+		pvname:       pvname,
+		podNamespace: namespace,
+	}
+	if meta.pvdir, err = r.runFindpvdirJob(backupObj, meta); err != nil {
+		r.cleanup()
+		return "", "", nil, err
+	}
+	if len(meta.pvdir) == 0 {
+		r.cleanup()
+		return "", "", nil, fmt.Errorf("pvc/%s data directory not found in node %s", pvc, meta.nodeName)
+	}
+	r.logger.Debugf("the path of persistentvolume data in k8s node %s: %s", meta.nodeName, meta.nodePath())
+	return meta.nodeName, meta.nodePath(), r.cleanup, nil
+}
+
+// localVolumeMeta returns the metadata of the persistentvolume not bound to any
+// persistentvolumeclaim, the data directory and the k8s node are found from the
+// persistentvolume spec, only the "local" persistentvolume is supported.
+func localVolumeMeta(pv string) (pvdataMeta, error) {
+	pvObj, err := pvHandler.Get(pv)
+	if err != nil {
+		return pvdataMeta{}, errors.Wrapf(err, "persistentvolume handler get pv/%s failed", pv)
+	}
+	if pvObj.Spec.Local == nil || pvObj.Spec.NodeAffinity == nil || pvObj.Spec.NodeAffinity.Required == nil {
+		return pvdataMeta{}, fmt.Errorf("pv/%s is not bound, only the local persistentvolume can be backed up without pvc", pv)
+	}
+	for _, term := range pvObj.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == corev1.LabelHostname && expr.Operator == corev1.NodeSelectorOpIn && len(expr.Values) != 0 {
+				return pvdataMeta{
+					volumeSource: types.VolumeLocal,
+					nodeName:     expr.Values[0],
+					pvdir:        pvObj.Spec.Local.Path,
+					pvname:       pv,
+				}, nil
+			}
+		}
+	}
+	return pvdataMeta{}, fmt.Errorf("the k8s node of pv/%s not found in its node affinity", pv)
+}
+
+// pvcMounter construct a deployment that mounts the persistentvolumeclaim, it makes
+// the kubelet mount the persistentvolume on the k8s node, so the findpvdir job can
+// find the persistentvolume data directory. The persistentvolumeclaim is mounted
+// read-only to backup, restore mounts it read-write to write the data directory.
+func pvcMounter(backupObj *storagev1alpha1.Backup, name, pvc string, readOnly bool) *appsv1.Deployment {
+	labels := map[string]string{
+		types.LabelName:      pvcMounterName,
+		types.LabelInstance:  string(backupObj.GetUID()),
+		types.LabelPartOf:    "horus",
+		types.LabelManagedBy: "horus-operator",
+	}
+	replicas := int32(1)
+	gracePeriod := int64(0)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+				},
+				Spec: corev1.PodSpec{
+					Tolerations:                   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					TerminationGracePeriodSeconds: &gracePeriod,
+					Containers: []corev1.Container{{
+						Name:  pvcMounterName,
+						Image: pvcMounterImage,
+						Env:   []corev1.EnvVar{{Name: "TZ", Value: backupObj.Spec.TimeZone}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "data",
+							MountPath: "/data",
+							ReadOnly:  readOnly,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "data",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: pvc,
+								ReadOnly:  readOnly,
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
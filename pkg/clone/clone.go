@@ -0,0 +1,293 @@
+package clone
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/backup"
+	"github.com/forbearing/horus-operator/pkg/restore"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	"github.com/forbearing/k8s/deployment"
+	"github.com/forbearing/k8s/dynamic"
+	"github.com/forbearing/k8s/namespace"
+	"github.com/forbearing/k8s/persistentvolumeclaim"
+	"github.com/forbearing/k8s/pod"
+	"github.com/forbearing/k8s/statefulset"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// cloneExecutorName is the name prefix of the deployments that copy persistentvolumeclaim data.
+	cloneExecutorName  = "clone-pvc"
+	cloneExecutorImage = "hybfkuf/backup-tools-restic:latest"
+
+	// the source persistentvolumeclaim is mounted at cloneSourcePath, and
+	// the new persistentvolumeclaim is mounted at cloneTargetPath.
+	cloneSourcePath   = "/clone-source"
+	cloneSourceVolume = "clone-source"
+	cloneTargetPath   = "/clone-target"
+	cloneTargetVolume = "clone-target"
+)
+
+var (
+	ctx        = context.TODO()
+	podHandler = pod.NewOrDie(ctx, "", "")
+	depHandler = deployment.NewOrDie(ctx, "", "")
+	stsHandler = statefulset.NewOrDie(ctx, "", "")
+	pvcHandler = persistentvolumeclaim.NewOrDie(ctx, "", "")
+	nsHandler  = namespace.NewOrDie(ctx, "")
+	dynHandler = dynamic.NewOrDie(ctx, "", "")
+)
+
+// logger is the base logger, every clone derives its own *logrus.Entry from it.
+var (
+	logger = logrus.WithFields(logrus.Fields{})
+)
+
+// Do start to clone the persistentvolumeclaims of the deployment/statefulset defined
+// in Clone object to the new persistentvolumeclaims.
+// namespace is the Clone object namespace
+// name is the Clone object name
+func Do(ctx context.Context, namespace, name string) (err error) {
+	begin := time.Now()
+	cloneObj, err := getClone(namespace, name)
+	if err != nil {
+		logger.WithField("namespace", namespace).Error(err)
+		return err
+	}
+	if cloneObj.Spec.CloneFrom == nil {
+		err = errors.New("Clone.spec.cloneFrom is required")
+		logger.Error(err)
+		return err
+	}
+	// setup logger
+	logger := logger.WithFields(logrus.Fields{
+		"name":      name,
+		"namespace": namespace,
+		"resource":  cloneObj.Spec.CloneFrom.Resource,
+	})
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully get Clone object")
+
+	// The Clone status is updated when clone begin and finish, whether success or failure.
+	status := cloneObj.Status.DeepCopy()
+	startTime := metav1.Now()
+	status.Phase = storagev1alpha1.CloneRunning
+	status.StartTime = &startTime
+	status.CompletionTime = nil
+	status.PVCs = nil
+	status.Message = ""
+	if err := patchStatus(cloneObj, status); err != nil {
+		logger.Warn(err)
+	}
+	defer func() {
+		completionTime := metav1.Now()
+		status.CompletionTime = &completionTime
+		if err != nil {
+			logger.Error(err)
+			status.Phase = storagev1alpha1.CloneFailed
+			status.Message = err.Error()
+		} else {
+			status.Phase = storagev1alpha1.CloneSucceeded
+			status.Message = fmt.Sprintf("Successfully clone %d persistentvolumeclaim(s)", len(status.PVCs))
+		}
+		if err := patchStatus(cloneObj, status); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	return run(ctx, logger, cloneObj, status)
+}
+
+func run(ctx context.Context, logger *logrus.Entry, cloneObj *storagev1alpha1.Clone, status *storagev1alpha1.CloneStatus) error {
+	// ==============================
+	// 1. find out the persistentvolumeclaims to clone and the new persistentvolumeclaims.
+	// ==============================
+	begin := time.Now()
+	cloneFrom := cloneObj.Spec.CloneFrom
+	cloneTo := cloneTarget(cloneObj)
+	srcNamespace := cloneObj.GetNamespace()
+	if cloneTo.Namespace == srcNamespace && cloneTo.Name == cloneFrom.Name {
+		return errors.New("either Clone.spec.cloneTo.namespace or Clone.spec.cloneTo.name must be different from the source")
+	}
+	method := cloneObj.Spec.Method
+	if len(method) == 0 {
+		method = storagev1alpha1.CloneMethodCopy
+	}
+	if method != storagev1alpha1.CloneMethodCopy && method != storagev1alpha1.CloneMethodRestic {
+		return fmt.Errorf("not support clone method: %s", method)
+	}
+	w, err := workload.Get(srcNamespace, cloneFrom.Resource, cloneFrom.Name)
+	if err != nil {
+		return err
+	}
+	if len(w.PVCs) == 0 {
+		return fmt.Errorf("There is no pvc mounted by the %s/%s, skip clone", cloneFrom.Resource, cloneFrom.Name)
+	}
+	if cloneTo.CopyWorkload {
+		if err := checkWorkloadSelector(w, srcNamespace, cloneTo); err != nil {
+			return err
+		}
+	}
+	pvcs := make(map[string]string)
+	for _, pvc := range w.PVCs {
+		pvcs[pvc] = targetPVCName(w, cloneTo, pvc)
+	}
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("The persistentvolumeclaims to clone are: %v", pvcs)
+
+	// ==============================
+	// 2. create the namespace and the new persistentvolumeclaims.
+	// ==============================
+	begin = time.Now()
+	if err := ensureNamespace(cloneTo.Namespace); err != nil {
+		return err
+	}
+	for _, pvc := range w.PVCs {
+		if err := createTargetPVC(logger, srcNamespace, pvc, cloneTo.Namespace, pvcs[pvc], cloneTo.StorageClassName); err != nil {
+			return err
+		}
+	}
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully create persistentvolumeclaims in namespace %s", cloneTo.Namespace)
+
+	// ==============================
+	// 3. copy the persistentvolumeclaim data.
+	// ==============================
+	var backupObj *storagev1alpha1.Backup
+	if method == storagev1alpha1.CloneMethodRestic {
+		begin = time.Now()
+		if backupObj, err = freshBackup(ctx, cloneObj); err != nil {
+			return err
+		}
+		logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully backup %s/%s", cloneFrom.Resource, cloneFrom.Name)
+	}
+	for i, pvc := range w.PVCs {
+		begin := time.Now()
+		switch method {
+		case storagev1alpha1.CloneMethodCopy:
+			err = copyPVC(logger, cloneObj, i, srcNamespace, pvc, cloneTo.Namespace, pvcs[pvc])
+		case storagev1alpha1.CloneMethodRestic:
+			err = restorePVC(ctx, cloneObj, backupObj, i, pvc, cloneTo.Namespace, pvcs[pvc])
+		}
+		if err != nil {
+			return errors.Wrapf(err, "clone pvc/%s failed", pvc)
+		}
+		status.PVCs = append(status.PVCs, storagev1alpha1.ClonedPVC{Source: pvc, Target: pvcs[pvc]})
+		logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully clone pvc/%s to %s/%s", pvc, cloneTo.Namespace, pvcs[pvc])
+	}
+
+	// ==============================
+	// 4. copy the deployment/statefulset manifest.
+	// ==============================
+	if cloneTo.CopyWorkload {
+		if err := copyWorkload(w, cloneTo, pvcs); err != nil {
+			return err
+		}
+		logger.Infof("Successfully copy %s/%s to %s/%s", cloneFrom.Resource, cloneFrom.Name, cloneTo.Namespace, cloneTo.Name)
+	}
+
+	logger.Infof("Successfully clone %s/%s", cloneFrom.Resource, cloneFrom.Name)
+	return nil
+}
+
+// cloneTarget returns the Clone.spec.cloneTo with default values.
+func cloneTarget(cloneObj *storagev1alpha1.Clone) *storagev1alpha1.CloneTo {
+	cloneTo := &storagev1alpha1.CloneTo{}
+	if cloneObj.Spec.CloneTo != nil {
+		cloneTo = cloneObj.Spec.CloneTo.DeepCopy()
+	}
+	if len(cloneTo.Namespace) == 0 {
+		cloneTo.Namespace = cloneObj.GetNamespace()
+	}
+	if len(cloneTo.Name) == 0 {
+		cloneTo.Name = cloneObj.Spec.CloneFrom.Name
+	}
+	return cloneTo
+}
+
+// ensureNamespace creates the namespace if not exist.
+func ensureNamespace(name string) error {
+	_, err := nsHandler.Get(name)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "namespace handler get namespace/%s failed", name)
+	}
+	nsObj := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if _, err := nsHandler.Create(nsObj); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "namespace handler create namespace/%s failed", name)
+	}
+	return nil
+}
+
+// freshBackup takes a new restic snapshot of the persistentvolumeclaims by the
+// Backup object defined in Clone.spec.backup, and returns the Backup object.
+func freshBackup(ctx context.Context, cloneObj *storagev1alpha1.Clone) (*storagev1alpha1.Backup, error) {
+	if len(cloneObj.Spec.Backup) == 0 {
+		return nil, errors.New(`Clone.spec.backup is required when clone method is "restic"`)
+	}
+	backupObj, err := getBackup(cloneObj.GetNamespace(), cloneObj.Spec.Backup)
+	if err != nil {
+		return nil, err
+	}
+	if backupObj.Spec.BackupFrom == nil || !equality.Semantic.DeepEqual(*backupObj.Spec.BackupFrom, *cloneObj.Spec.CloneFrom) {
+		return nil, fmt.Errorf("Backup.spec.backupFrom of backup/%s is different from Clone.spec.cloneFrom", backupObj.GetName())
+	}
+	if err := backup.Do(ctx, backupObj.GetNamespace(), backupObj.GetName()); err != nil {
+		return nil, errors.Wrapf(err, "backup %s failed", backupObj.GetName())
+	}
+	return backupObj, nil
+}
+
+// restorePVC restores the latest restic snapshot of the source persistentvolumeclaim
+// to the new persistentvolumeclaim. It constructs a Restore object and reuses the
+// restore logic.
+func restorePVC(ctx context.Context, cloneObj *storagev1alpha1.Clone, backupObj *storagev1alpha1.Backup,
+	index int, srcPVC, dstNamespace, dstPVC string) error {
+	restoreObj := &storagev1alpha1.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("clone-%s-%d", cloneObj.GetName(), index),
+			Namespace: dstNamespace,
+			UID:       cloneObj.GetUID(),
+		},
+		Spec: storagev1alpha1.RestoreSpec{
+			RestoreFrom: &storagev1alpha1.RestoreFrom{
+				Backup:  backupObj.GetName(),
+				Storage: cloneObj.Spec.Storage,
+			},
+			RestoreTo: &storagev1alpha1.RestoreTo{
+				Name:     dstPVC,
+				Resource: storagev1alpha1.PersistentVolumeClaim,
+			},
+			// the tags are the same as the Backup object set, see pkg/backup/execute.go.
+			Tags: []string{
+				string(cloneObj.Spec.CloneFrom.Resource),
+				cloneObj.GetNamespace(),
+				cloneObj.Spec.CloneFrom.Name,
+				srcPVC,
+			},
+			TimeZone: cloneObj.Spec.TimeZone,
+		},
+	}
+	return restore.Run(ctx, restoreObj, backupObj, &storagev1alpha1.RestoreStatus{})
+}
+
+// executorLabels returns the labels of the deployment that copy persistentvolumeclaim data.
+// component is "source" or "target", the source and target deployments may be in
+// the same namespace, they should not select each other's pods.
+func executorLabels(cloneObj *storagev1alpha1.Clone, component string) map[string]string {
+	return map[string]string{
+		types.LabelName:      cloneExecutorName,
+		types.LabelInstance:  string(cloneObj.GetUID()),
+		types.LabelComponent: component,
+		types.LabelPartOf:    "horus",
+		types.LabelManagedBy: "horus-operator",
+	}
+}
@@ -0,0 +1,138 @@
+package clone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/backup"
+	"github.com/forbearing/horus-operator/pkg/dump"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// copyPVC copy the data of the source persistentvolumeclaim to the new persistentvolumeclaim.
+// It creates a deployment mounts the source persistentvolumeclaim read-only and a
+// deployment mounts the new persistentvolumeclaim, then pipes the output of
+// `tar -c` in the source pod to the `tar -x` in the target pod.
+func copyPVC(logger *logrus.Entry, cloneObj *storagev1alpha1.Clone, index int, srcNamespace, srcPVC, dstNamespace, dstPVC string) error {
+	// The ReadWriteOnce persistentvolumeclaim can only be mounted by the pods in
+	// the same node, so the source pod runs on the node where the persistentvolumeclaim mounted.
+	nodeName, err := mountedNode(srcNamespace, srcPVC)
+	if err != nil {
+		return err
+	}
+
+	srcName := fmt.Sprintf("%s-%s-source-%d", cloneExecutorName, cloneObj.GetName(), index)
+	srcDeploy := newExecutor(cloneObj, "source", srcName, srcPVC, cloneSourceVolume, cloneSourcePath, true, nodeName)
+	srcPod, err := backup.CreateExecutor(srcNamespace, srcDeploy)
+	defer depHandler.WithNamespace(srcNamespace).Delete(srcName)
+	if err != nil {
+		return err
+	}
+	dstName := fmt.Sprintf("%s-%s-target-%d", cloneExecutorName, cloneObj.GetName(), index)
+	dstDeploy := newExecutor(cloneObj, "target", dstName, dstPVC, cloneTargetVolume, cloneTargetPath, false, "")
+	dstPod, err := backup.CreateExecutor(dstNamespace, dstDeploy)
+	defer depHandler.WithNamespace(dstNamespace).Delete(dstName)
+	if err != nil {
+		return err
+	}
+
+	// The uid and gid of the files should be kept, the user names in the executor
+	// image may be different from the application image.
+	cmdTarCreate := []string{"tar", "--numeric-owner", "-C", cloneSourcePath, "-cf", "-", "."}
+	cmdTarExtract := []string{"tar", "--numeric-owner", "-C", cloneTargetPath, "-xpf", "-"}
+	logger.Debug(strings.Join(cmdTarCreate, " "), " | ", strings.Join(cmdTarExtract, " "))
+
+	pr, pw := io.Pipe()
+	srcStderr, dstStderr := new(bytes.Buffer), new(bytes.Buffer)
+	srcErrCh := make(chan error, 1)
+	go func() {
+		err := podHandler.WithNamespace(srcNamespace).ExecuteWithStream(srcPod.GetName(), "", cmdTarCreate, dump.NoStdin(), pw, srcStderr)
+		pw.CloseWithError(err)
+		srcErrCh <- err
+	}()
+	if err := podHandler.WithNamespace(dstNamespace).ExecuteWithStream(dstPod.GetName(), "", cmdTarExtract, pr, io.Discard, dstStderr); err != nil {
+		// unblock the source pod writing to the pipe.
+		pr.CloseWithError(err)
+		<-srcErrCh
+		return fmt.Errorf("extract data to pvc/%s failed: %s", dstPVC, strings.TrimSpace(dstStderr.String()))
+	}
+	if err := <-srcErrCh; err != nil {
+		return fmt.Errorf("archive data of pvc/%s failed: %s", srcPVC, strings.TrimSpace(srcStderr.String()))
+	}
+	return nil
+}
+
+// mountedNode returns the node name of the running pod which mounts the persistentvolumeclaim,
+// returns empty string if the persistentvolumeclaim is not mounted.
+func mountedNode(namespace, pvc string) (string, error) {
+	podObjs, err := podHandler.WithNamespace(namespace).List()
+	if err != nil {
+		return "", errors.Wrap(err, "pod handler list pods failed")
+	}
+	for _, podObj := range podObjs {
+		if podObj.Status.Phase != corev1.PodRunning || len(podObj.Spec.NodeName) == 0 {
+			continue
+		}
+		for _, claimName := range workload.PodTemplatePVC(corev1.PodTemplateSpec{Spec: podObj.Spec}) {
+			if claimName == pvc {
+				return podObj.Spec.NodeName, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// newExecutor construct a deployment that mounts the persistentvolumeclaim at mountPath.
+func newExecutor(cloneObj *storagev1alpha1.Clone, component, name, pvc, volume, mountPath string, readOnly bool, nodeName string) *appsv1.Deployment {
+	labels := executorLabels(cloneObj, component)
+	replicas := int32(1)
+	gracePeriod := int64(0)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+				},
+				Spec: corev1.PodSpec{
+					NodeName:                      nodeName,
+					Tolerations:                   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					TerminationGracePeriodSeconds: &gracePeriod,
+					Containers: []corev1.Container{{
+						Name:  cloneExecutorName,
+						Image: cloneExecutorImage,
+						Env:   []corev1.EnvVar{{Name: "TZ", Value: cloneObj.Spec.TimeZone}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      volume,
+							MountPath: mountPath,
+							ReadOnly:  readOnly,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: volume,
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: pvc,
+								ReadOnly:  readOnly,
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
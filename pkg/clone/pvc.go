@@ -0,0 +1,62 @@
+package clone
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// targetPVCName returns the name of the new persistentvolumeclaim cloned from pvc.
+// see the doc of storagev1alpha1.CloneTo.
+func targetPVCName(w *workload.Workload, cloneTo *storagev1alpha1.CloneTo, pvc string) string {
+	if tpl, ok := w.ClaimTemplates[pvc]; ok {
+		return workload.ClaimTemplatePVC(tpl.Name, cloneTo.Name, tpl.Ordinal)
+	}
+	if cloneTo.Namespace != w.Namespace {
+		return pvc
+	}
+	return fmt.Sprintf("%s-%s", cloneTo.Name, pvc)
+}
+
+// createTargetPVC creates the new persistentvolumeclaim with the same size and
+// access modes as the source persistentvolumeclaim. The existing persistentvolumeclaim
+// will be reused.
+func createTargetPVC(logger *logrus.Entry, srcNamespace, srcPVC, dstNamespace, dstPVC, storageClassName string) error {
+	srcObj, err := pvcHandler.WithNamespace(srcNamespace).Get(srcPVC)
+	if err != nil {
+		return errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", srcPVC)
+	}
+	if _, err := pvcHandler.WithNamespace(dstNamespace).Get(dstPVC); err == nil {
+		logger.Warnf("pvc/%s already exists in namespace %s, reuse it", dstPVC, dstNamespace)
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", dstPVC)
+	}
+
+	dstObj := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dstPVC,
+			Namespace: dstNamespace,
+			Labels:    srcObj.GetLabels(),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      srcObj.Spec.AccessModes,
+			Resources:        srcObj.Spec.Resources,
+			StorageClassName: srcObj.Spec.StorageClassName,
+			VolumeMode:       srcObj.Spec.VolumeMode,
+		},
+	}
+	if len(storageClassName) != 0 {
+		dstObj.Spec.StorageClassName = &storageClassName
+	}
+	if _, err := pvcHandler.WithNamespace(dstNamespace).Create(dstObj); err != nil {
+		return errors.Wrapf(err, "persistentvolumeclaim handler create pvc/%s failed", dstPVC)
+	}
+	return nil
+}
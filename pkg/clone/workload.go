@@ -0,0 +1,135 @@
+package clone
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// copyWorkload copy the deployment/statefulset to the Clone.spec.cloneTo namespace
+// with the Clone.spec.cloneTo name, and the persistentvolumeclaim references
+// rewritten to the new persistentvolumeclaims. The labels, selector and pod template
+// labels are relabeled by cloneLabels, so the copied workload doesn't select the
+// pods of the source, see checkWorkloadSelector.
+// pvcs maps the source persistentvolumeclaim to the new persistentvolumeclaim.
+func copyWorkload(w *workload.Workload, cloneTo *storagev1alpha1.CloneTo, pvcs map[string]string) error {
+	switch w.Resource {
+	case storagev1alpha1.DeploymentResource:
+		deployObj := &appsv1.Deployment{
+			ObjectMeta: copyObjectMeta(w.Deployment.ObjectMeta, cloneTo),
+			Spec:       *w.Deployment.Spec.DeepCopy(),
+		}
+		relabel(deployObj.Spec.Selector, &deployObj.Spec.Template, cloneTo.Name)
+		rewriteClaimName(&deployObj.Spec.Template, pvcs)
+		if _, err := depHandler.WithNamespace(cloneTo.Namespace).Apply(deployObj); err != nil {
+			return errors.Wrapf(err, "deployment handler apply deployment/%s failed", cloneTo.Name)
+		}
+	case storagev1alpha1.StatefulSetResource:
+		stsObj := &appsv1.StatefulSet{
+			ObjectMeta: copyObjectMeta(w.StatefulSet.ObjectMeta, cloneTo),
+			Spec:       *w.StatefulSet.Spec.DeepCopy(),
+		}
+		relabel(stsObj.Spec.Selector, &stsObj.Spec.Template, cloneTo.Name)
+		rewriteClaimName(&stsObj.Spec.Template, pvcs)
+		if len(cloneTo.StorageClassName) != 0 {
+			for i := range stsObj.Spec.VolumeClaimTemplates {
+				stsObj.Spec.VolumeClaimTemplates[i].Spec.StorageClassName = &cloneTo.StorageClassName
+			}
+		}
+		if _, err := stsHandler.WithNamespace(cloneTo.Namespace).Apply(stsObj); err != nil {
+			return errors.Wrapf(err, "statefulset handler apply statefulset/%s failed", cloneTo.Name)
+		}
+	}
+	return nil
+}
+
+// checkWorkloadSelector returns error if the selector of the source deployment/statefulset
+// selects the pods of the copied one in the same namespace, otherwise the source
+// controller and the services select the pods of the copied workload too, which
+// run on the cloned persistentvolumeclaims. The pods of the copied workload are
+// only distinguished by the label app.kubernetes.io/instance, see cloneLabels.
+func checkWorkloadSelector(w *workload.Workload, srcNamespace string, cloneTo *storagev1alpha1.CloneTo) error {
+	if cloneTo.Namespace != srcNamespace {
+		return nil
+	}
+	var (
+		labelSelector *metav1.LabelSelector
+		template      *corev1.PodTemplateSpec
+	)
+	switch w.Resource {
+	case storagev1alpha1.DeploymentResource:
+		labelSelector, template = w.Deployment.Spec.Selector, &w.Deployment.Spec.Template
+	case storagev1alpha1.StatefulSetResource:
+		labelSelector, template = w.StatefulSet.Spec.Selector, &w.StatefulSet.Spec.Template
+	default:
+		return nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return errors.Wrapf(err, "parse the selector of %s/%s failed", w.Resource, w.Name)
+	}
+	if selector.Matches(labels.Set(cloneLabels(template.GetLabels(), cloneTo.Name))) {
+		return fmt.Errorf("the selector of %s/%s selects the pods of the copied %s/%s in the same namespace, "+
+			"add the label %s to its selector or clone to another namespace", w.Resource, w.Name, w.Resource, cloneTo.Name, types.LabelInstance)
+	}
+	return nil
+}
+
+// relabel rewrites the selector and the pod template labels of the copied
+// deployment/statefulset named name, see cloneLabels.
+func relabel(selector *metav1.LabelSelector, template *corev1.PodTemplateSpec, name string) {
+	if selector != nil {
+		selector.MatchLabels = cloneLabels(selector.MatchLabels, name)
+	}
+	template.SetLabels(cloneLabels(template.GetLabels(), name))
+}
+
+// cloneLabels returns a copy of the labels with the label app.kubernetes.io/instance
+// set to the name of the copied deployment/statefulset.
+func cloneLabels(source map[string]string, name string) map[string]string {
+	result := make(map[string]string, len(source)+1)
+	for key, value := range source {
+		result[key] = value
+	}
+	result[types.LabelInstance] = name
+	return result
+}
+
+// copyObjectMeta returns the metadata of the copied deployment/statefulset,
+// only the labels and annotations are kept, the labels are relabeled by cloneLabels.
+func copyObjectMeta(meta metav1.ObjectMeta, cloneTo *storagev1alpha1.CloneTo) metav1.ObjectMeta {
+	annotations := make(map[string]string)
+	for key, value := range meta.GetAnnotations() {
+		switch key {
+		case corev1.LastAppliedConfigAnnotation, "deployment.kubernetes.io/revision":
+			continue
+		}
+		annotations[key] = value
+	}
+	return metav1.ObjectMeta{
+		Name:        cloneTo.Name,
+		Namespace:   cloneTo.Namespace,
+		Labels:      cloneLabels(meta.GetLabels(), cloneTo.Name),
+		Annotations: annotations,
+	}
+}
+
+// rewriteClaimName rewrite the persistentvolumeclaim references in pod template to the new persistentvolumeclaims.
+func rewriteClaimName(template *corev1.PodTemplateSpec, pvcs map[string]string) {
+	for i := range template.Spec.Volumes {
+		claim := template.Spec.Volumes[i].PersistentVolumeClaim
+		if claim == nil {
+			continue
+		}
+		if pvc, ok := pvcs[claim.ClaimName]; ok {
+			claim.ClaimName = pvc
+		}
+	}
+}
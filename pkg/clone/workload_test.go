@@ -0,0 +1,76 @@
+package clone
+
+import (
+	"testing"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckWorkloadSelector(t *testing.T) {
+	newWorkload := func(selector map[string]string) *workload.Workload {
+		return &workload.Workload{
+			Name:     "nginx",
+			Resource: storagev1alpha1.DeploymentResource,
+			Deployment: &appsv1.Deployment{Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: selector},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+					"app.kubernetes.io/name":     "nginx",
+					"app.kubernetes.io/instance": "nginx",
+				}}},
+			}},
+		}
+	}
+	tests := []struct {
+		name      string
+		selector  map[string]string
+		namespace string
+		expectErr bool
+	}{
+		{
+			name:      "same namespace, the source selects the copied pods",
+			selector:  map[string]string{"app.kubernetes.io/name": "nginx"},
+			namespace: "test",
+			expectErr: true,
+		},
+		{
+			name:      "same namespace, the source selects by instance",
+			selector:  map[string]string{"app.kubernetes.io/name": "nginx", "app.kubernetes.io/instance": "nginx"},
+			namespace: "test",
+		},
+		{
+			name:      "another namespace",
+			selector:  map[string]string{"app.kubernetes.io/name": "nginx"},
+			namespace: "test-clone",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloneTo := &storagev1alpha1.CloneTo{Namespace: tt.namespace, Name: "nginx-clone"}
+			err := checkWorkloadSelector(newWorkload(tt.selector), "test", cloneTo)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("checkWorkloadSelector() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestRelabel(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx", "app.kubernetes.io/instance": "nginx"}}
+	template := &corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "nginx"}}}
+	source := template.GetLabels()
+	relabel(selector, template, "nginx-clone")
+	if selector.MatchLabels["app.kubernetes.io/instance"] != "nginx-clone" || selector.MatchLabels["app"] != "nginx" {
+		t.Fatalf("unexpected selector: %v", selector.MatchLabels)
+	}
+	if template.Labels["app.kubernetes.io/instance"] != "nginx-clone" || template.Labels["app"] != "nginx" {
+		t.Fatalf("unexpected template labels: %v", template.Labels)
+	}
+	if _, ok := source["app.kubernetes.io/instance"]; ok {
+		t.Fatal("the labels of the source are changed")
+	}
+}
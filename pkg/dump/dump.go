@@ -0,0 +1,298 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/k8s/daemonset"
+	"github.com/forbearing/k8s/deployment"
+	"github.com/forbearing/k8s/pod"
+	"github.com/forbearing/k8s/secret"
+	"github.com/forbearing/k8s/statefulset"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// the keys of the credential secret.
+	usernameKey = "username"
+	passwordKey = "password"
+
+	// mongoConfigEnv is the shell variable contains the config file of
+	// mongodump and mongorestore, see mongoExec.
+	mongoConfigEnv = "HORUS_MONGO_CONFIG"
+)
+
+var (
+	ctx        = context.TODO()
+	podHandler = pod.NewOrDie(ctx, "", "")
+	depHandler = deployment.NewOrDie(ctx, "", "")
+	stsHandler = statefulset.NewOrDie(ctx, "", "")
+	dsHandler  = daemonset.NewOrDie(ctx, "", "")
+	secHandler = secret.NewOrDie(ctx, "", "")
+)
+
+// Filename returns the filename of the dump in the restic snapshot,
+// it's the argument of `restic backup --stdin-filename`.
+func Filename(dbType storagev1alpha1.DatabaseType) string {
+	switch dbType {
+	case storagev1alpha1.DatabaseMongoDB:
+		return string(dbType) + ".archive"
+	case storagev1alpha1.DatabaseRedis:
+		return string(dbType) + ".rdb"
+	default:
+		return string(dbType) + ".sql"
+	}
+}
+
+// Key returns the key identifies the dump, it takes the place of the
+// persistentvolumeclaim name in the restic snapshot tags and Backup status.
+func Key(dbType storagev1alpha1.DatabaseType) string {
+	return string(dbType) + "-dump"
+}
+
+// Pod returns a running pod of the k8s resource to execute the dump tool in.
+func Pod(namespace string, resource storagev1alpha1.Resource, name string) (*corev1.Pod, error) {
+	var (
+		err        error
+		podObjList []*corev1.Pod
+	)
+	switch resource {
+	case storagev1alpha1.PodResource:
+		podObj, err := podHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pod handler get pod/%s failed", name)
+		}
+		podObjList = append(podObjList, podObj)
+	case storagev1alpha1.DeploymentResource:
+		if podObjList, err = depHandler.WithNamespace(namespace).GetPods(name); err != nil {
+			return nil, errors.Wrapf(err, "deployment handler get pods of deployment/%s failed", name)
+		}
+	case storagev1alpha1.StatefulSetResource:
+		if podObjList, err = stsHandler.WithNamespace(namespace).GetPods(name); err != nil {
+			return nil, errors.Wrapf(err, "statefulset handler get pods of statefulset/%s failed", name)
+		}
+	case storagev1alpha1.DaemonSetResource:
+		if podObjList, err = dsHandler.WithNamespace(namespace).GetPods(name); err != nil {
+			return nil, errors.Wrapf(err, "daemonset handler get pods of daemonset/%s failed", name)
+		}
+	default:
+		return nil, fmt.Errorf("dump database in %s is not supported", resource)
+	}
+	for _, podObj := range podObjList {
+		if podObj.Status.Phase == corev1.PodRunning && podObj.GetDeletionTimestamp() == nil {
+			return podObj, nil
+		}
+	}
+	return nil, fmt.Errorf("not found running pod for %s/%s", resource, name)
+}
+
+// Credential returns the username and password in the credential secret,
+// both are empty if no credential secret specified.
+func Credential(dumpSpec *storagev1alpha1.DatabaseDump, namespace string) (string, string, error) {
+	if len(dumpSpec.CredentialName) == 0 {
+		return "", "", nil
+	}
+	if len(dumpSpec.CredentialNamespace) != 0 {
+		namespace = dumpSpec.CredentialNamespace
+	}
+	secObj, err := secHandler.WithNamespace(namespace).Get(dumpSpec.CredentialName)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "secret handler get secret/%s in namespace/%s failed", dumpSpec.CredentialName, namespace)
+	}
+	return string(secObj.Data[usernameKey]), string(secObj.Data[passwordKey]), nil
+}
+
+// Exec is the command executed in the container of a pod. The secret, such as the
+// database password, is never put in the command, the exec arguments are part of
+// the request URI and may be recorded by the kube-apiserver audit logs. Instead,
+// Secret is written to the stdin of Command as the first line, and read by the shell
+// wrapper of Command, see withSecret.
+type Exec struct {
+	Command []string
+	Secret  string
+}
+
+// Command returns the command to dump the database to stdout.
+// The password is passed by environment variable if the dump tool supports it.
+func Command(dumpSpec *storagev1alpha1.DatabaseDump, username, password string) (Exec, error) {
+	var command []string
+	var passwordEnv string
+	databases := dumpSpec.Databases
+	switch dumpSpec.Type {
+	case storagev1alpha1.DatabaseMySQL:
+		passwordEnv = "MYSQL_PWD"
+		command = []string{"mysqldump", "--single-transaction", "--routines", "--events", "--triggers"}
+		if len(username) != 0 {
+			command = append(command, "--user="+username)
+		}
+		if len(databases) == 0 {
+			command = append(command, "--all-databases")
+		} else {
+			command = append(append(command, "--databases"), databases...)
+		}
+	case storagev1alpha1.DatabasePostgreSQL:
+		passwordEnv = "PGPASSWORD"
+		switch len(databases) {
+		case 0:
+			command = []string{"pg_dumpall", "--clean", "--if-exists"}
+		case 1:
+			command = []string{"pg_dump", "--clean", "--if-exists", "--create"}
+		default:
+			return Exec{}, errors.New("postgresql only support dump one database or all databases")
+		}
+		if len(username) != 0 {
+			command = append(command, "--username="+username)
+		}
+		if len(databases) == 1 {
+			command = append(command, databases[0])
+		}
+	case storagev1alpha1.DatabaseMongoDB:
+		if len(databases) > 1 {
+			return Exec{}, errors.New("mongodb only support dump one database or all databases")
+		}
+		command = []string{"mongodump", "--archive"}
+		if len(databases) == 1 {
+			command = append(command, "--db="+databases[0])
+		}
+		return mongoExec(append(command, dumpSpec.Args...), username, password), nil
+	case storagev1alpha1.DatabaseRedis:
+		passwordEnv = "REDISCLI_AUTH"
+		command = []string{"redis-cli", "--rdb", "-"}
+		if len(username) != 0 {
+			command = append(command, "--user", username)
+		}
+	default:
+		return Exec{}, fmt.Errorf("not support database type: %s", dumpSpec.Type)
+	}
+	return withSecret(passwordEnv, password, append(command, dumpSpec.Args...))
+}
+
+// RestoreCommand returns the command to restore the dump read from stdin.
+func RestoreCommand(dumpSpec *storagev1alpha1.DatabaseDump, username, password string) (Exec, error) {
+	var command []string
+	var passwordEnv string
+	switch dumpSpec.Type {
+	case storagev1alpha1.DatabaseMySQL:
+		passwordEnv = "MYSQL_PWD"
+		command = []string{"mysql"}
+		if len(username) != 0 {
+			command = append(command, "--user="+username)
+		}
+	case storagev1alpha1.DatabasePostgreSQL:
+		// the dump contains the statements to create and connect to the databases.
+		passwordEnv = "PGPASSWORD"
+		command = []string{"psql", "--dbname=postgres"}
+		if len(username) != 0 {
+			command = append(command, "--username="+username)
+		}
+	case storagev1alpha1.DatabaseMongoDB:
+		return mongoExec([]string{"mongorestore", "--archive", "--drop"}, username, password), nil
+	case storagev1alpha1.DatabaseRedis:
+		return Exec{}, errors.New("restore redis rdb dump is not supported, restore the rdb file to the redis data directory manually")
+	default:
+		return Exec{}, fmt.Errorf("not support database type: %s", dumpSpec.Type)
+	}
+	return withSecret(passwordEnv, password, command)
+}
+
+// withSecret returns the command wrapped by shell, the shell reads the password
+// from the first line of stdin to the environment variable key, then executes
+// command, the rest of stdin is left to command.
+// The command is not wrapped if the password is empty.
+func withSecret(key, password string, command []string) (Exec, error) {
+	if len(password) == 0 {
+		return Exec{Command: command}, nil
+	}
+	if strings.ContainsAny(password, "\r\n") {
+		return Exec{}, errors.New("the database password must not contain newline")
+	}
+	script := fmt.Sprintf(`IFS= read -r %s; export %s; exec "$0" "$@"`, key, key)
+	return Exec{Command: append([]string{"sh", "-c", script}, command...), Secret: password}, nil
+}
+
+// mongoExec returns the mongodump or mongorestore command with the authentication
+// arguments. mongodump and mongorestore can't read the password from environment
+// variable, the shell reads the config contains the password from the first line
+// of stdin, and passes it to --config by the here-document. The --config requires
+// mongodb database tools 100.3.0+.
+func mongoExec(command []string, username, password string) Exec {
+	if len(username) == 0 {
+		return Exec{Command: command}
+	}
+	command = append(command, "--username="+username, "--authenticationDatabase=admin")
+	if len(password) == 0 {
+		return Exec{Command: command}
+	}
+	// The json string is a valid yaml double-quoted string in one line.
+	quoted, _ := json.Marshal(password)
+	script := fmt.Sprintf("IFS= read -r %s; exec \"$0\" --config=/dev/fd/3 \"$@\" 3<<EOF\n$%s\nEOF", mongoConfigEnv, mongoConfigEnv)
+	return Exec{Command: append([]string{"sh", "-c", script}, command...), Secret: "password: " + string(quoted)}
+}
+
+// Stream executes src in the container of srcPod and streams its stdout to the
+// stdin of dst executed in the container of dstPod, the stdout of dst is written
+// to stdout.
+// The data is never written to disk, it's used to stream the database dump to
+// `restic backup --stdin` and stream `restic dump` to the database client.
+func Stream(srcPod *corev1.Pod, srcContainer string, src Exec,
+	dstPod *corev1.Pod, dstContainer string, dst Exec, stdout io.Writer) error {
+	reader, writer := io.Pipe()
+	srcStderr, dstStderr := new(bytes.Buffer), new(bytes.Buffer)
+	errCh := make(chan error, 1)
+	go func() {
+		err := podHandler.WithNamespace(srcPod.GetNamespace()).ExecuteWithStream(srcPod.GetName(), srcContainer, src.Command, src.stdin(NoStdin()), writer, srcStderr)
+		// close the pipe to tell dst the end of stdin.
+		writer.CloseWithError(err)
+		errCh <- err
+	}()
+	dstErr := podHandler.WithNamespace(dstPod.GetNamespace()).ExecuteWithStream(dstPod.GetName(), dstContainer, dst.Command, dst.stdin(reader), stdout, dstStderr)
+	// unblock src if dst exited early.
+	reader.Close()
+	srcErr := <-errCh
+
+	if srcErr != nil {
+		return fmt.Errorf("execute %s in pod/%s failed: %s: %s", commandName(src.Command), srcPod.GetName(), srcErr.Error(), strings.TrimSpace(srcStderr.String()))
+	}
+	if dstErr != nil {
+		return fmt.Errorf("execute %s in pod/%s failed: %s: %s", commandName(dst.Command), dstPod.GetName(), dstErr.Error(), strings.TrimSpace(dstStderr.String()))
+	}
+	return nil
+}
+
+// stdin returns the stdin of the command, the secret is the first line.
+func (e Exec) stdin(stdin io.Reader) io.Reader {
+	if len(e.Secret) == 0 {
+		return stdin
+	}
+	return io.MultiReader(strings.NewReader(e.Secret+"\n"), stdin)
+}
+
+// NoStdin returns an empty stdin for the command executed in pod. The pod
+// handler always requests the stdin stream, so it can't be nil, and horusctl
+// should never feed its own stdin, such as the terminal, to the command.
+func NoStdin() io.Reader {
+	return strings.NewReader("")
+}
+
+// commandName returns the program name of the command, the "env" prefix and the
+// "sh -c" wrapper are skipped.
+func commandName(command []string) string {
+	for i := 0; i < len(command); i++ {
+		arg := command[i]
+		switch {
+		case arg == "env" || strings.Contains(arg, "="):
+		case arg == "sh" && i+1 < len(command) && command[i+1] == "-c":
+			// sh -c script program args...
+			i += 2
+		default:
+			return arg
+		}
+	}
+	return ""
+}
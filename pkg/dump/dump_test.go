@@ -0,0 +1,92 @@
+package dump
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+)
+
+func TestCommandSecret(t *testing.T) {
+	const password = `p@ss "w0rd"$(id)`
+	for _, dbType := range []storagev1alpha1.DatabaseType{
+		storagev1alpha1.DatabaseMySQL,
+		storagev1alpha1.DatabasePostgreSQL,
+		storagev1alpha1.DatabaseMongoDB,
+		storagev1alpha1.DatabaseRedis,
+	} {
+		dumpSpec := &storagev1alpha1.DatabaseDump{Type: dbType}
+		commands := make(map[string]Exec)
+		var err error
+		if commands["dump"], err = Command(dumpSpec, "root", password); err != nil {
+			t.Fatal(err)
+		}
+		if dbType != storagev1alpha1.DatabaseRedis {
+			if commands["restore"], err = RestoreCommand(dumpSpec, "root", password); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for kind, e := range commands {
+			// the exec arguments may be recorded by the kube-apiserver audit logs.
+			if strings.Contains(strings.Join(e.Command, " "), "w0rd") {
+				t.Errorf("%s %s command contains the password: %v", dbType, kind, e.Command)
+			}
+			if !strings.Contains(e.Secret, "w0rd") {
+				t.Errorf("%s %s secret doesn't contain the password: %q", dbType, kind, e.Secret)
+			}
+		}
+	}
+
+	e, err := Command(&storagev1alpha1.DatabaseDump{Type: storagev1alpha1.DatabaseMySQL}, "root", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Command[0] != "mysqldump" || len(e.Secret) != 0 {
+		t.Errorf("the command without password should not be wrapped: %v", e.Command)
+	}
+	if _, err := Command(&storagev1alpha1.DatabaseDump{Type: storagev1alpha1.DatabaseMySQL}, "root", "a\nb"); err == nil {
+		t.Error("the password contains newline should be rejected")
+	}
+}
+
+// TestWithSecretShell executes the shell wrapper to check the password is read from
+// the first line of stdin and the rest of stdin is left to the command.
+func TestWithSecretShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	const password = ` p@ss\"w0rd"$(id) `
+	e, err := withSecret("MYSQL_PWD", password, []string{"sh", "-c", `printf '%s|' "$MYSQL_PWD"; cat`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(e.Command[0], e.Command[1:]...)
+	cmd.Stdin = e.stdin(strings.NewReader("dump data\n"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := password + "|dump data\n"; string(output) != expect {
+		t.Fatalf("output = %q, want %q", output, expect)
+	}
+
+	// the fake mongorestore prints its arguments and the config.
+	mongorestore := filepath.Join(t.TempDir(), "mongorestore")
+	if err := os.WriteFile(mongorestore, []byte("#!/bin/sh\necho \"$@\"; cat /dev/fd/3\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e = mongoExec([]string{mongorestore}, "root", password)
+	cmd = exec.Command(e.Command[0], e.Command[1:]...)
+	cmd.Stdin = e.stdin(NoStdin())
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "--config=/dev/fd/3 --username=root --authenticationDatabase=admin\n" + `password: " p@ss\\\"w0rd\"$(id) "` + "\n"
+	if string(output) != expect {
+		t.Fatalf("output = %q, want %q", output, expect)
+	}
+}
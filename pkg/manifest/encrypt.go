@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/forbearing/horus-operator/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// EncryptionKeyName is the key of the encryption credential secret.
+	EncryptionKeyName = "MANIFEST_ENCRYPTION_KEY"
+	// AnnotationEncrypted is set on the secrets encrypted in the manifests,
+	// the value is the encryption algorithm.
+	AnnotationEncrypted = "hybfkuf.io/encrypted"
+	encryptionAlgorithm = "aes-256-gcm"
+)
+
+// EncryptionKey returns the AES-256 key derived from the passphrase in the
+// encryption credential secret in the namespace of the horus-operator.
+func EncryptionKey(credentialName string) ([]byte, error) {
+	if len(credentialName) == 0 {
+		return nil, errors.New("encryptionCredentialName is required to encrypt or decrypt secrets")
+	}
+	namespace := util.GetOperatorNamespace()
+	secObj, err := secHandler.WithNamespace(namespace).Get(credentialName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "secret handler get secret/%s in namespace/%s failed", credentialName, namespace)
+	}
+	passphrase := secObj.Data[EncryptionKeyName]
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("secret/%s has no key %s", credentialName, EncryptionKeyName)
+	}
+	key := sha256.Sum256(passphrase)
+	return key[:], nil
+}
+
+// encryptSecret encrypts every value in the secret data and marks the secret encrypted.
+func encryptSecret(secObj *corev1.Secret, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	for k, v := range secObj.Data {
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return err
+		}
+		secObj.Data[k] = gcm.Seal(nonce, nonce, v, nil)
+	}
+	annotations := secObj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[AnnotationEncrypted] = encryptionAlgorithm
+	secObj.SetAnnotations(annotations)
+	return nil
+}
+
+// decryptSecret decrypts the secret encrypted by encryptSecret, the secret not
+// encrypted is not changed.
+func decryptSecret(u *unstructured.Unstructured, key []byte) error {
+	annotations := u.GetAnnotations()
+	if _, ok := annotations[AnnotationEncrypted]; !ok {
+		return nil
+	}
+	if len(key) == 0 {
+		return errors.New("the secret is encrypted but no encryption key provided")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	data, _, _ := unstructured.NestedStringMap(u.Object, "data")
+	for k, v := range data {
+		ciphertext, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return errors.Wrapf(err, "decode key %s failed", k)
+		}
+		if len(ciphertext) < gcm.NonceSize() {
+			return fmt.Errorf("key %s is too short to decrypt", k)
+		}
+		nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+		plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return errors.Wrapf(err, "decrypt key %s failed, maybe the encryption key is wrong", k)
+		}
+		data[k] = base64.StdEncoding.EncodeToString(plaintext)
+	}
+	if len(data) != 0 {
+		if err := unstructured.SetNestedStringMap(u.Object, data, "data"); err != nil {
+			return err
+		}
+	}
+	delete(annotations, AnnotationEncrypted)
+	u.SetAnnotations(annotations)
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "create aes cipher failed")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,99 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestEncryptDecryptSecret(t *testing.T) {
+	key := sha256.Sum256([]byte("passphrase"))
+	plain := map[string][]byte{
+		"username": []byte("root"),
+		"password": []byte("p@ssw0rd"),
+		"empty":    {},
+	}
+	secObj := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mysql",
+			Namespace: "test",
+			Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: `{"data":{"password":"cEBzc3cwcmQ="}}`,
+			},
+		},
+		Data: make(map[string][]byte),
+	}
+	for k, v := range plain {
+		secObj.Data[k] = append([]byte(nil), v...)
+	}
+
+	if err := encryptSecret(secObj, key[:]); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range plain {
+		if len(v) != 0 && bytes.Contains(secObj.Data[k], v) {
+			t.Fatalf("key %s is not encrypted", k)
+		}
+	}
+
+	// the secret in manifests is marshaled as unstructured, the data is base64 encoded.
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(secObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &unstructured.Unstructured{Object: data}
+	clean(u)
+	if _, ok := u.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; ok {
+		t.Fatal("last-applied-configuration annotation is not removed")
+	}
+	if u.GetAnnotations()[AnnotationEncrypted] != encryptionAlgorithm {
+		t.Fatal("encrypted annotation is not set")
+	}
+
+	wrongKey := sha256.Sum256([]byte("wrong"))
+	if err := decryptSecret(u.DeepCopy(), wrongKey[:]); err == nil {
+		t.Fatal("decrypt with the wrong key should fail")
+	}
+	if err := decryptSecret(u.DeepCopy(), nil); err == nil {
+		t.Fatal("decrypt without key should fail")
+	}
+
+	if err := decryptSecret(u, key[:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := u.GetAnnotations()[AnnotationEncrypted]; ok {
+		t.Fatal("encrypted annotation is not removed")
+	}
+	got, _, _ := unstructured.NestedStringMap(u.Object, "data")
+	if len(got) != len(plain) {
+		t.Fatalf("expected %d keys, got %d", len(plain), len(got))
+	}
+	for k, v := range plain {
+		if got[k] != base64.StdEncoding.EncodeToString(v) {
+			t.Fatalf("key %s: expected %q, got %q", k, v, got[k])
+		}
+	}
+}
+
+func TestDecryptSecretNotEncrypted(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "plain"},
+		"data":       map[string]interface{}{"password": "cEBzc3cwcmQ="},
+	}}
+	expected := u.DeepCopy()
+	if err := decryptSecret(u, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(u.Object, expected.Object) {
+		t.Fatal("the secret not encrypted should not be changed")
+	}
+}
@@ -0,0 +1,435 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/k8s/configmap"
+	"github.com/forbearing/k8s/daemonset"
+	"github.com/forbearing/k8s/deployment"
+	"github.com/forbearing/k8s/dynamic"
+	"github.com/forbearing/k8s/persistentvolume"
+	"github.com/forbearing/k8s/persistentvolumeclaim"
+	"github.com/forbearing/k8s/pod"
+	"github.com/forbearing/k8s/secret"
+	"github.com/forbearing/k8s/service"
+	"github.com/forbearing/k8s/serviceaccount"
+	"github.com/forbearing/k8s/statefulset"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Key takes the place of the persistentvolumeclaim name in the restic snapshot tags.
+	Key = "manifests"
+	// Filename is the filename of the manifests in the restic snapshot,
+	// it's the argument of `restic backup --stdin-filename`.
+	Filename = Key + ".yaml"
+)
+
+var (
+	ctx        = context.TODO()
+	podHandler = pod.NewOrDie(ctx, "", "")
+	depHandler = deployment.NewOrDie(ctx, "", "")
+	stsHandler = statefulset.NewOrDie(ctx, "", "")
+	dsHandler  = daemonset.NewOrDie(ctx, "", "")
+	pvcHandler = persistentvolumeclaim.NewOrDie(ctx, "", "")
+	pvHandler  = persistentvolume.NewOrDie(ctx, "")
+	cmHandler  = configmap.NewOrDie(ctx, "", "")
+	secHandler = secret.NewOrDie(ctx, "", "")
+	svcHandler = service.NewOrDie(ctx, "", "")
+	saHandler  = serviceaccount.NewOrDie(ctx, "", "")
+	dynHandler = dynamic.NewOrDie(ctx, "", "")
+)
+
+// kindOrder is the order of the objects in the manifests, the objects referenced
+// by others are created first when restore.
+var kindOrder = []string{"ServiceAccount", "ConfigMap", "Secret", "PersistentVolume", "PersistentVolumeClaim", "Service", "Pod", "Deployment", "StatefulSet", "DaemonSet"}
+
+// collector collects the objects of the backup target, the objects are deduplicated
+// by kind and name.
+type collector struct {
+	namespace string
+	options   *storagev1alpha1.ManifestOptions
+	key       []byte
+	seen      map[string]bool
+	objects   map[string][]runtime.Object
+}
+
+func (c *collector) add(kind string, object runtime.Object) {
+	name := object.(metav1.Object).GetName()
+	if c.seen[kind+"/"+name] {
+		return
+	}
+	c.seen[kind+"/"+name] = true
+	object.GetObjectKind().SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+	if kind == "Deployment" || kind == "StatefulSet" || kind == "DaemonSet" {
+		object.GetObjectKind().SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind(kind))
+	}
+	c.objects[kind] = append(c.objects[kind], object)
+}
+
+// Collect returns the manifests of the k8s resource and the persistentvolumeclaims,
+// persistentvolumes, configmaps, secrets, services and serviceaccount it references,
+// as a multi-document yaml. The fields set by k8s, such as uid, resourceVersion and
+// status, are removed, so the manifests can be created in another cluster.
+func Collect(namespace string, resource storagev1alpha1.Resource, name string, options *storagev1alpha1.ManifestOptions) ([]byte, error) {
+	c := &collector{
+		namespace: namespace,
+		options:   options,
+		seen:      make(map[string]bool),
+		objects:   make(map[string][]runtime.Object),
+	}
+	if options.Secrets == storagev1alpha1.SecretPolicyEncrypt {
+		key, err := EncryptionKey(options.EncryptionCredentialName)
+		if err != nil {
+			return nil, err
+		}
+		c.key = key
+	}
+
+	var (
+		err      error
+		podObjs  []*corev1.Pod
+		template *corev1.PodTemplateSpec
+		pvcNames []string
+	)
+	switch resource {
+	case storagev1alpha1.PodResource:
+		podObj, err := podHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pod handler get pod/%s failed", name)
+		}
+		c.add("Pod", podObj)
+		podObjs = append(podObjs, podObj)
+		template = &corev1.PodTemplateSpec{ObjectMeta: podObj.ObjectMeta, Spec: podObj.Spec}
+	case storagev1alpha1.DeploymentResource:
+		deployObj, err := depHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "deployment handler get deployment/%s failed", name)
+		}
+		c.add("Deployment", deployObj)
+		template = &deployObj.Spec.Template
+		if podObjs, err = depHandler.WithNamespace(namespace).GetPods(name); err != nil {
+			return nil, errors.Wrapf(err, "deployment handler get pods of deployment/%s failed", name)
+		}
+	case storagev1alpha1.StatefulSetResource:
+		stsObj, err := stsHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "statefulset handler get statefulset/%s failed", name)
+		}
+		c.add("StatefulSet", stsObj)
+		template = &stsObj.Spec.Template
+		if podObjs, err = stsHandler.WithNamespace(namespace).GetPods(name); err != nil {
+			return nil, errors.Wrapf(err, "statefulset handler get pods of statefulset/%s failed", name)
+		}
+	case storagev1alpha1.DaemonSetResource:
+		dsObj, err := dsHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "daemonset handler get daemonset/%s failed", name)
+		}
+		c.add("DaemonSet", dsObj)
+		template = &dsObj.Spec.Template
+		if podObjs, err = dsHandler.WithNamespace(namespace).GetPods(name); err != nil {
+			return nil, errors.Wrapf(err, "daemonset handler get pods of daemonset/%s failed", name)
+		}
+	case storagev1alpha1.PersistentVolumeClaim:
+		pvcNames = append(pvcNames, name)
+	case storagev1alpha1.PersistentVolume:
+		pvObj, err := pvHandler.Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "persistentvolume handler get pv/%s failed", name)
+		}
+		c.add("PersistentVolume", pvObj)
+	default:
+		return nil, fmt.Errorf("backup manifests of %s is not supported", resource)
+	}
+
+	// the persistentvolumeclaims created by statefulset volumeClaimTemplates only
+	// exist in the pods spec.
+	for _, podObj := range podObjs {
+		for _, volume := range podObj.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				pvcNames = append(pvcNames, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+	}
+	for _, pvc := range pvcNames {
+		if err = c.addPVC(pvc); err != nil {
+			return nil, err
+		}
+	}
+	if template != nil {
+		if err = c.addReferenced(template); err != nil {
+			return nil, err
+		}
+	}
+	return c.marshal()
+}
+
+// addPVC adds the persistentvolumeclaim and the persistentvolume it bound to.
+func (c *collector) addPVC(name string) error {
+	pvcObj, err := pvcHandler.WithNamespace(c.namespace).Get(name)
+	if err != nil {
+		return errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", name)
+	}
+	c.add("PersistentVolumeClaim", pvcObj)
+	if len(pvcObj.Spec.VolumeName) == 0 {
+		return nil
+	}
+	pvObj, err := pvHandler.Get(pvcObj.Spec.VolumeName)
+	if err != nil {
+		return errors.Wrapf(err, "persistentvolume handler get pv/%s failed", pvcObj.Spec.VolumeName)
+	}
+	c.add("PersistentVolume", pvObj)
+	return nil
+}
+
+// addReferenced adds the configmaps, secrets, serviceaccount referenced by the pod template
+// and the services select the pod template.
+func (c *collector) addReferenced(template *corev1.PodTemplateSpec) error {
+	configMaps, secrets := podReferences(&template.Spec)
+	for _, name := range configMaps {
+		// kube-root-ca.crt is created in every namespace by k8s.
+		if name == "kube-root-ca.crt" {
+			continue
+		}
+		cmObj, err := cmHandler.WithNamespace(c.namespace).Get(name)
+		if err != nil {
+			// the optional configmap may not exist.
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "configmap handler get configmap/%s failed", name)
+		}
+		c.add("ConfigMap", cmObj)
+	}
+	if c.options.Secrets == storagev1alpha1.SecretPolicyInclude || c.options.Secrets == storagev1alpha1.SecretPolicyEncrypt {
+		for _, name := range secrets {
+			secObj, err := secHandler.WithNamespace(c.namespace).Get(name)
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return errors.Wrapf(err, "secret handler get secret/%s failed", name)
+			}
+			// the serviceaccount token is created by k8s.
+			if secObj.Type == corev1.SecretTypeServiceAccountToken {
+				continue
+			}
+			if c.options.Secrets == storagev1alpha1.SecretPolicyEncrypt {
+				if err := encryptSecret(secObj, c.key); err != nil {
+					return errors.Wrapf(err, "encrypt secret/%s failed", name)
+				}
+			}
+			c.add("Secret", secObj)
+		}
+	}
+	if sa := template.Spec.ServiceAccountName; len(sa) != 0 && sa != "default" {
+		saObj, err := saHandler.WithNamespace(c.namespace).Get(sa)
+		if err != nil {
+			return errors.Wrapf(err, "serviceaccount handler get serviceaccount/%s failed", sa)
+		}
+		// the token secrets are created by k8s.
+		saObj.Secrets = nil
+		c.add("ServiceAccount", saObj)
+	}
+
+	svcObjs, err := svcHandler.WithNamespace(c.namespace).List()
+	if err != nil {
+		return errors.Wrap(err, "service handler list services failed")
+	}
+	for _, svcObj := range svcObjs {
+		if len(svcObj.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(svcObj.Spec.Selector).Matches(labels.Set(template.GetLabels())) {
+			c.add("Service", svcObj)
+		}
+	}
+	return nil
+}
+
+// podReferences returns the names of configmaps and secrets referenced by the pod spec.
+func podReferences(podSpec *corev1.PodSpec) (configMaps, secrets []string) {
+	for _, volume := range podSpec.Volumes {
+		if volume.ConfigMap != nil {
+			configMaps = append(configMaps, volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			secrets = append(secrets, volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps = append(configMaps, source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					secrets = append(secrets, source.Secret.Name)
+				}
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps = append(configMaps, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets = append(secrets, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMaps = append(configMaps, envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				secrets = append(secrets, envFrom.SecretRef.Name)
+			}
+		}
+	}
+	for _, ref := range podSpec.ImagePullSecrets {
+		secrets = append(secrets, ref.Name)
+	}
+	return configMaps, secrets
+}
+
+// marshal returns the collected objects as multi-document yaml in kindOrder.
+func (c *collector) marshal() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, kind := range kindOrder {
+		for _, object := range c.objects[kind] {
+			data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+			if err != nil {
+				return nil, errors.Wrapf(err, "convert %s to unstructured failed", kind)
+			}
+			u := &unstructured.Unstructured{Object: data}
+			clean(u)
+			out, err := yaml.Marshal(u.Object)
+			if err != nil {
+				return nil, errors.Wrapf(err, "marshal %s/%s failed", kind, u.GetName())
+			}
+			buf.WriteString("---\n")
+			buf.Write(out)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// clean removes the fields set by k8s. The last-applied-configuration annotation
+// set by "kubectl apply" is removed too, it contains the whole object, includes
+// the data of the secret not encrypted.
+func clean(u *unstructured.Unstructured) {
+	for _, field := range []string{"uid", "resourceVersion", "creationTimestamp", "managedFields", "generation", "ownerReferences", "selfLink"} {
+		unstructured.RemoveNestedField(u.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(u.Object, "metadata", "annotations", corev1.LastAppliedConfigAnnotation)
+	if len(u.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(u.Object, "metadata", "annotations")
+	}
+	unstructured.RemoveNestedField(u.Object, "status")
+	switch u.GetKind() {
+	case "Service":
+		unstructured.RemoveNestedField(u.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(u.Object, "spec", "clusterIPs")
+	case "PersistentVolume":
+		unstructured.RemoveNestedField(u.Object, "spec", "claimRef", "uid")
+		unstructured.RemoveNestedField(u.Object, "spec", "claimRef", "resourceVersion")
+	case "Pod":
+		// the node may not exist in another cluster.
+		unstructured.RemoveNestedField(u.Object, "spec", "nodeName")
+	}
+}
+
+// Apply creates the objects in the manifests in the namespace, the objects already
+// exist are not changed. The encrypted secrets are decrypted with key.
+// The persistentvolumeclaims with storageClassName are provisioned by the storageclass,
+// their persistentvolumes are skipped. It returns the objects created, like "kind/name".
+func Apply(data []byte, namespace string, key []byte) ([]string, error) {
+	var objects []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		if err := decoder.Decode(&u.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "decode manifests failed")
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		objects = append(objects, u)
+	}
+
+	// the persistentvolumes provisioned dynamically are created again by the storageclass.
+	skipPVs := make(map[string]bool)
+	for _, u := range objects {
+		if u.GetKind() != "PersistentVolumeClaim" {
+			continue
+		}
+		if className, _, _ := unstructured.NestedString(u.Object, "spec", "storageClassName"); len(className) == 0 {
+			continue
+		}
+		if volumeName, _, _ := unstructured.NestedString(u.Object, "spec", "volumeName"); len(volumeName) != 0 {
+			skipPVs[volumeName] = true
+		}
+		unstructured.RemoveNestedField(u.Object, "spec", "volumeName")
+		annotations := u.GetAnnotations()
+		for key := range annotations {
+			if strings.HasPrefix(key, "pv.kubernetes.io/") || strings.HasPrefix(key, "volume.kubernetes.io/") ||
+				strings.HasPrefix(key, "volume.beta.kubernetes.io/") {
+				delete(annotations, key)
+			}
+		}
+		u.SetAnnotations(annotations)
+	}
+
+	var created []string
+	for _, u := range objects {
+		kind := u.GetKind()
+		switch kind {
+		case "PersistentVolume":
+			if skipPVs[u.GetName()] {
+				continue
+			}
+			if _, found, _ := unstructured.NestedMap(u.Object, "spec", "claimRef"); found {
+				unstructured.SetNestedField(u.Object, namespace, "spec", "claimRef", "namespace")
+			}
+		case "Secret":
+			if err := decryptSecret(u, key); err != nil {
+				return created, errors.Wrapf(err, "decrypt secret/%s failed", u.GetName())
+			}
+			u.SetNamespace(namespace)
+		default:
+			u.SetNamespace(namespace)
+		}
+
+		if _, err := dynHandler.WithNamespace(namespace).Get(u); err == nil {
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			return created, errors.Wrapf(err, "get %s/%s failed", kind, u.GetName())
+		}
+		if _, err := dynHandler.WithNamespace(namespace).Create(u); err != nil {
+			return created, errors.Wrapf(err, "create %s/%s failed", kind, u.GetName())
+		}
+		created = append(created, strings.ToLower(kind)+"/"+u.GetName())
+	}
+	return created, nil
+}
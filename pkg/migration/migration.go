@@ -0,0 +1,330 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/backup"
+	"github.com/forbearing/horus-operator/pkg/restore"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	"github.com/forbearing/k8s/dynamic"
+	"github.com/forbearing/k8s/persistentvolume"
+	"github.com/forbearing/k8s/persistentvolumeclaim"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	ctx        = context.TODO()
+	pvHandler  = persistentvolume.NewOrDie(ctx, "")
+	pvcHandler = persistentvolumeclaim.NewOrDie(ctx, "", "")
+	dynHandler = dynamic.NewOrDie(ctx, "", "")
+)
+
+// logger is the base logger, every migration derives its own *logrus.Entry from
+// it, see migrator.logger.
+var (
+	logger = logrus.WithFields(logrus.Fields{})
+)
+
+// Do start to migrate the persistentvolumeclaims of the deployment/statefulset defined
+// in Migration object to the new persistentvolumes.
+// namespace is the Migration object namespace
+// name is the Migration object name
+func Do(ctx context.Context, namespace, name string) (err error) {
+	begin := time.Now()
+	migrationObj, err := getMigration(namespace, name)
+	if err != nil {
+		logger.WithField("namespace", namespace).Error(err)
+		return err
+	}
+	if migrationObj.Spec.MigrateFrom == nil || migrationObj.Spec.MigrateTo == nil {
+		err = errors.New("Migration.spec.migrateFrom and Migration.spec.migrateTo are required")
+		logger.Error(err)
+		return err
+	}
+	// setup logger
+	logger := logger.WithFields(logrus.Fields{
+		"name":      name,
+		"namespace": namespace,
+		"resource":  migrationObj.Spec.MigrateFrom.Resource,
+	})
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully get Migration object")
+
+	// The Migration status is updated when every step finish, whether success or failure.
+	status := migrationObj.Status.DeepCopy()
+	startTime := metav1.Now()
+	status.Phase = storagev1alpha1.MigrationRunning
+	status.StartTime = &startTime
+	status.CompletionTime = nil
+	status.PVCs = nil
+	status.Conditions = nil
+	status.Message = ""
+	if err := patchStatus(migrationObj, status); err != nil {
+		logger.Warn(err)
+	}
+	defer func() {
+		completionTime := metav1.Now()
+		status.CompletionTime = &completionTime
+		if err != nil {
+			logger.Error(err)
+			status.Phase = storagev1alpha1.MigrationFailed
+			status.Message = err.Error()
+		} else {
+			var volumes []string
+			for _, pvc := range status.PVCs {
+				volumes = append(volumes, pvc.SourceVolume)
+			}
+			status.Phase = storagev1alpha1.MigrationSucceeded
+			status.Message = fmt.Sprintf("Successfully migrate %d persistentvolumeclaim(s), the old persistentvolumes %v are retained", len(status.PVCs), volumes)
+		}
+		if err := patchStatus(migrationObj, status); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	m := &migrator{
+		logger:         logger,
+		migrationObj:   migrationObj,
+		status:         status,
+		sourcePolicies: make(map[string]corev1.PersistentVolumeReclaimPolicy),
+	}
+	return m.run(ctx)
+}
+
+// migrator holds the state of one migration, it's used to roll back the
+// persistentvolumeclaims when migration failed.
+//
+// sources is the persistentvolumeclaims before migration.
+// sourcePolicies is the original reclaim policy of the old persistentvolumes.
+type migrator struct {
+	logger         *logrus.Entry
+	migrationObj   *storagev1alpha1.Migration
+	status         *storagev1alpha1.MigrationStatus
+	sources        []*corev1.PersistentVolumeClaim
+	sourcePolicies map[string]corev1.PersistentVolumeReclaimPolicy
+}
+
+func (m *migrator) run(ctx context.Context) (err error) {
+	// ==============================
+	// 1. find out the persistentvolumeclaims to migrate.
+	// ==============================
+	begin := time.Now()
+	namespace := m.migrationObj.GetNamespace()
+	migrateFrom := m.migrationObj.Spec.MigrateFrom
+	if len(m.migrationObj.Spec.Backup) == 0 {
+		return errors.New("Migration.spec.backup is required")
+	}
+	backupObj, err := getBackup(namespace, m.migrationObj.Spec.Backup)
+	if err != nil {
+		return err
+	}
+	if backupObj.Spec.BackupFrom == nil || !equality.Semantic.DeepEqual(*backupObj.Spec.BackupFrom, *migrateFrom) {
+		return fmt.Errorf("Backup.spec.backupFrom of backup/%s is different from Migration.spec.migrateFrom", backupObj.GetName())
+	}
+	w, err := workload.Get(namespace, migrateFrom.Resource, migrateFrom.Name)
+	if err != nil {
+		return err
+	}
+	if len(w.PVCs) == 0 {
+		return fmt.Errorf("There is no pvc mounted by the %s/%s, skip migration", migrateFrom.Resource, migrateFrom.Name)
+	}
+	for _, pvc := range w.PVCs {
+		pvcObj, err := pvcHandler.WithNamespace(namespace).Get(pvc)
+		if err != nil {
+			return errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", pvc)
+		}
+		if pvcObj.Status.Phase != corev1.ClaimBound || len(pvcObj.Spec.VolumeName) == 0 {
+			return fmt.Errorf("pvc/%s is not bound", pvc)
+		}
+		m.sources = append(m.sources, pvcObj)
+		m.status.PVCs = append(m.status.PVCs, storagev1alpha1.MigratedPVC{Name: pvc, SourceVolume: pvcObj.Spec.VolumeName})
+	}
+	m.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("The persistentvolumeclaims to migrate are: %v", w.PVCs)
+
+	// ==============================
+	// 2. scale down the deployment/statefulset and take the final backup.
+	// ==============================
+	// The final backup is taken after the deployment/statefulset scaled down, so
+	// that no data written after it is lost. The database dump and the backup hooks
+	// require a running pod, they can't be used to backup the scaled down workload.
+	if backupObj.Spec.Dump != nil {
+		return fmt.Errorf("backup/%s takes the database dump by Backup.spec.dump, it can't backup the scaled down %s/%s",
+			backupObj.GetName(), migrateFrom.Resource, migrateFrom.Name)
+	}
+	begin = time.Now()
+	// always scale up the deployment/statefulset to the original replicas,
+	// even if scale down failed. It runs after rollback.
+	defer func() {
+		scaleErr := w.ScaleUp()
+		m.setCondition(storagev1alpha1.MigrationScaledUp, scaleErr)
+		if scaleErr != nil {
+			m.logger.Error(scaleErr)
+		}
+	}()
+	err = w.ScaleDown()
+	m.setCondition(storagev1alpha1.MigrationScaledDown, err)
+	if err != nil {
+		return err
+	}
+	m.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully scale down %s/%s", migrateFrom.Resource, migrateFrom.Name)
+
+	begin = time.Now()
+	// No pod mounts the persistentvolumeclaims now, every persistentvolumeclaim is
+	// mounted by a temporary pod and backed up, see pkg/backup/volume.go.
+	for _, src := range m.sources {
+		if err = backup.Run(ctx, finalBackup(backupObj, src.GetName()), &storagev1alpha1.BackupStatus{}); err != nil {
+			err = errors.Wrapf(err, "backup pvc/%s by backup/%s failed", src.GetName(), backupObj.GetName())
+			break
+		}
+	}
+	m.setCondition(storagev1alpha1.MigrationBackedUp, err)
+	if err != nil {
+		return err
+	}
+	m.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully backup %s/%s", migrateFrom.Resource, migrateFrom.Name)
+
+	// bind the persistentvolumeclaims back to the old persistentvolumes if any step below failed.
+	defer func() {
+		if err == nil {
+			return
+		}
+		rollbackErr := m.rollback()
+		m.setCondition(storagev1alpha1.MigrationRolledBack, rollbackErr)
+		if rollbackErr != nil {
+			m.logger.Error(rollbackErr)
+			err = errors.Wrapf(err, "rollback failed: %s", rollbackErr.Error())
+			return
+		}
+		m.logger.Info("Successfully rollback")
+	}()
+
+	// ==============================
+	// 3. create the temporary persistentvolumeclaims to provision the new persistentvolumes.
+	// ==============================
+	begin = time.Now()
+	for i, src := range m.sources {
+		if err = createTempPVC(src, tempPVCName(m.migrationObj, i), m.migrationObj.Spec.MigrateTo); err != nil {
+			break
+		}
+	}
+	m.setCondition(storagev1alpha1.MigrationProvisioned, err)
+	if err != nil {
+		return err
+	}
+	m.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully create persistentvolumeclaims")
+
+	// ==============================
+	// 4. restore the final backup to the new persistentvolumes.
+	// ==============================
+	begin = time.Now()
+	for i, src := range m.sources {
+		if err = m.restorePVC(ctx, backupObj, i, src.GetName()); err != nil {
+			err = errors.Wrapf(err, "restore pvc/%s failed", src.GetName())
+			break
+		}
+	}
+	m.setCondition(storagev1alpha1.MigrationRestored, err)
+	if err != nil {
+		return err
+	}
+	m.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully restore persistentvolumeclaims")
+
+	// ==============================
+	// 5. bind the persistentvolumeclaims to the new persistentvolumes.
+	// ==============================
+	begin = time.Now()
+	for i, src := range m.sources {
+		if err = m.swapPVC(i, src); err != nil {
+			err = errors.Wrapf(err, "swap pvc/%s failed", src.GetName())
+			break
+		}
+		m.logger.Infof("pvc/%s is bound to pv/%s", src.GetName(), m.status.PVCs[i].TargetVolume)
+	}
+	m.setCondition(storagev1alpha1.MigrationSwapped, err)
+	if err != nil {
+		return err
+	}
+	m.logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully swap persistentvolumeclaims")
+
+	m.logger.Infof("Successfully migrate %s/%s", migrateFrom.Resource, migrateFrom.Name)
+	return nil
+}
+
+// restorePVC restores the latest restic snapshot of the source persistentvolumeclaim
+// to the index-th temporary persistentvolumeclaim, and records the new persistentvolume.
+func (m *migrator) restorePVC(ctx context.Context, backupObj *storagev1alpha1.Backup, index int, pvc string) error {
+	tempPVC := tempPVCName(m.migrationObj, index)
+	restoreObj := &storagev1alpha1.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("migration-%s-%d", m.migrationObj.GetName(), index),
+			Namespace: m.migrationObj.GetNamespace(),
+			UID:       m.migrationObj.GetUID(),
+		},
+		Spec: storagev1alpha1.RestoreSpec{
+			RestoreFrom: &storagev1alpha1.RestoreFrom{
+				Backup:  backupObj.GetName(),
+				Storage: m.migrationObj.Spec.Storage,
+			},
+			RestoreTo: &storagev1alpha1.RestoreTo{
+				Name:     tempPVC,
+				Resource: storagev1alpha1.PersistentVolumeClaim,
+			},
+			// the tags are the same as the final backup set, see finalBackup and pkg/backup/execute.go.
+			Tags: []string{
+				string(storagev1alpha1.PersistentVolumeClaim),
+				m.migrationObj.GetNamespace(),
+				pvc,
+				pvc,
+			},
+			TimeZone: m.migrationObj.Spec.TimeZone,
+		},
+	}
+	if err := restore.Run(ctx, restoreObj, backupObj, &storagev1alpha1.RestoreStatus{}); err != nil {
+		return err
+	}
+
+	// The persistentvolume is provisioned when the restore pod mounts the persistentvolumeclaim.
+	pvcObj, err := waitBound(m.migrationObj.GetNamespace(), tempPVC)
+	if err != nil {
+		return err
+	}
+	m.status.PVCs[index].TargetVolume = pvcObj.Spec.VolumeName
+	return nil
+}
+
+// finalBackup returns a copy of the Backup object that only backup the persistentvolumeclaim.
+// The backup hooks and manifests are not used, the deployment/statefulset is scaled down.
+func finalBackup(backupObj *storagev1alpha1.Backup, pvc string) *storagev1alpha1.Backup {
+	finalObj := backupObj.DeepCopy()
+	finalObj.Spec.BackupFrom = &storagev1alpha1.BackupFrom{Name: pvc, Resource: storagev1alpha1.PersistentVolumeClaim}
+	finalObj.Spec.PVCFilter = nil
+	finalObj.Spec.Hooks = nil
+	finalObj.Spec.Manifests = nil
+	return finalObj
+}
+
+// setCondition records the result of the migration step and patch the Migration status.
+func (m *migrator) setCondition(condType string, err error) {
+	cond := metav1.Condition{
+		Type:               condType,
+		Status:             metav1.ConditionTrue,
+		Reason:             condType,
+		ObservedGeneration: m.migrationObj.GetGeneration(),
+	}
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "Failed"
+		cond.Message = err.Error()
+	}
+	meta.SetStatusCondition(&m.status.Conditions, cond)
+	if err := patchStatus(m.migrationObj, m.status); err != nil {
+		m.logger.Warn(err)
+	}
+}
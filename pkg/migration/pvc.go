@@ -0,0 +1,236 @@
+package migration
+
+import (
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// annSelectedNode is the annotation set on persistentvolumeclaim by kube-scheduler
+	// to tell the provisioner which node the persistentvolume should be provisioned on.
+	annSelectedNode = "volume.kubernetes.io/selected-node"
+
+	// pvcTimeout is the max time to wait for persistentvolumeclaim bound or deleted.
+	pvcTimeout = 10 * time.Minute
+)
+
+// tempPVCName returns the name of the temporary persistentvolumeclaim used to
+// provision the index-th new persistentvolume.
+func tempPVCName(migrationObj *storagev1alpha1.Migration, index int) string {
+	return fmt.Sprintf("migration-%s-%d", migrationObj.GetName(), index)
+}
+
+// createTempPVC creates the temporary persistentvolumeclaim with the same size and
+// access modes as the source persistentvolumeclaim in the new storageclass.
+func createTempPVC(src *corev1.PersistentVolumeClaim, name string, migrateTo *storagev1alpha1.MigrateTo) error {
+	pvcObj := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: src.GetNamespace(),
+			Labels:    src.GetLabels(),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      src.Spec.AccessModes,
+			Resources:        src.Spec.Resources,
+			StorageClassName: src.Spec.StorageClassName,
+			VolumeMode:       src.Spec.VolumeMode,
+		},
+	}
+	if len(migrateTo.StorageClassName) != 0 {
+		pvcObj.Spec.StorageClassName = &migrateTo.StorageClassName
+	}
+	if len(migrateTo.NodeName) != 0 {
+		pvcObj.SetAnnotations(map[string]string{annSelectedNode: migrateTo.NodeName})
+	}
+	if _, err := pvcHandler.WithNamespace(src.GetNamespace()).Create(pvcObj); err != nil {
+		return errors.Wrapf(err, "persistentvolumeclaim handler create pvc/%s failed", name)
+	}
+	return nil
+}
+
+// swapPVC binds the source persistentvolumeclaim to the new persistentvolume
+// provisioned by the index-th temporary persistentvolumeclaim.
+//
+// Both persistentvolumes are set to "Retain" before their persistentvolumeclaims
+// deleted, the old persistentvolume is kept for rollback, and the reclaim policy
+// of new persistentvolume is restored after the persistentvolumeclaim bound.
+func (m *migrator) swapPVC(index int, src *corev1.PersistentVolumeClaim) error {
+	namespace := src.GetNamespace()
+	sourceVolume := m.status.PVCs[index].SourceVolume
+	targetVolume := m.status.PVCs[index].TargetVolume
+
+	targetPolicy, err := setReclaimPolicy(targetVolume, corev1.PersistentVolumeReclaimRetain)
+	if err != nil {
+		return err
+	}
+	sourcePolicy, err := setReclaimPolicy(sourceVolume, corev1.PersistentVolumeReclaimRetain)
+	if err != nil {
+		return err
+	}
+	m.sourcePolicies[sourceVolume] = sourcePolicy
+
+	if err := deletePVC(namespace, tempPVCName(m.migrationObj, index)); err != nil {
+		return err
+	}
+	if err := deletePVC(namespace, src.GetName()); err != nil {
+		return err
+	}
+	if err := bindPVC(src, targetVolume); err != nil {
+		return err
+	}
+	m.status.PVCs[index].Swapped = true
+	if _, err := setReclaimPolicy(targetVolume, targetPolicy); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rollback binds the persistentvolumeclaims back to the old persistentvolumes,
+// restores the reclaim policy of the old persistentvolumes and deletes the
+// temporary persistentvolumeclaims.
+func (m *migrator) rollback() error {
+	var errs []error
+	for i, src := range m.sources {
+		namespace := src.GetNamespace()
+		sourceVolume := m.status.PVCs[i].SourceVolume
+
+		pvcObj, err := pvcHandler.WithNamespace(namespace).Get(src.GetName())
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", src.GetName()))
+			continue
+		}
+		if err != nil || pvcObj.Spec.VolumeName != sourceVolume {
+			if err := deletePVC(namespace, src.GetName()); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := bindPVC(src, sourceVolume); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			m.logger.Infof("pvc/%s is bound back to pv/%s", src.GetName(), sourceVolume)
+		}
+		m.status.PVCs[i].Swapped = false
+		if policy, ok := m.sourcePolicies[sourceVolume]; ok {
+			if _, err := setReclaimPolicy(sourceVolume, policy); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := deletePVC(namespace, tempPVCName(m.migrationObj, i)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// bindPVC creates the persistentvolumeclaim with the same name and spec as src,
+// and binds it to the persistentvolume.
+func bindPVC(src *corev1.PersistentVolumeClaim, pv string) error {
+	// reserve the persistentvolume for the persistentvolumeclaim, the persistentvolume
+	// is "Released" and can't be bound if its claimRef is the deleted persistentvolumeclaim.
+	var storageClassName string
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pvObj, err := pvHandler.Get(pv)
+		if err != nil {
+			return err
+		}
+		storageClassName = pvObj.Spec.StorageClassName
+		pvObj.Spec.ClaimRef = &corev1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+			Namespace:  src.GetNamespace(),
+			Name:       src.GetName(),
+		}
+		_, err = pvHandler.Update(pvObj)
+		return err
+	}); err != nil {
+		return errors.Wrapf(err, "persistentvolume handler update pv/%s claimRef failed", pv)
+	}
+
+	pvcObj := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      src.GetName(),
+			Namespace: src.GetNamespace(),
+			Labels:    src.GetLabels(),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      src.Spec.AccessModes,
+			Resources:        src.Spec.Resources,
+			StorageClassName: &storageClassName,
+			VolumeMode:       src.Spec.VolumeMode,
+			VolumeName:       pv,
+		},
+	}
+	if _, err := pvcHandler.WithNamespace(src.GetNamespace()).Create(pvcObj); err != nil {
+		return errors.Wrapf(err, "persistentvolumeclaim handler create pvc/%s failed", src.GetName())
+	}
+	_, err := waitBound(src.GetNamespace(), src.GetName())
+	return err
+}
+
+// deletePVC deletes the persistentvolumeclaim and wait for it deleted.
+func deletePVC(namespace, name string) error {
+	handler := pvcHandler.WithNamespace(namespace)
+	if err := handler.Delete(name); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "persistentvolumeclaim handler delete pvc/%s failed", name)
+	}
+	if err := wait.PollImmediate(2*time.Second, pvcTimeout, func() (bool, error) {
+		if _, err := handler.Get(name); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	}); err != nil {
+		return errors.Wrapf(err, "wait pvc/%s deleted failed", name)
+	}
+	return nil
+}
+
+// waitBound waits for the persistentvolumeclaim bound and returns it.
+func waitBound(namespace, name string) (*corev1.PersistentVolumeClaim, error) {
+	var pvcObj *corev1.PersistentVolumeClaim
+	if err := wait.PollImmediate(2*time.Second, pvcTimeout, func() (bool, error) {
+		var err error
+		if pvcObj, err = pvcHandler.WithNamespace(namespace).Get(name); err != nil {
+			return false, err
+		}
+		return pvcObj.Status.Phase == corev1.ClaimBound && len(pvcObj.Spec.VolumeName) != 0, nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "wait pvc/%s bound failed", name)
+	}
+	return pvcObj, nil
+}
+
+// setReclaimPolicy sets the reclaim policy of the persistentvolume and returns the original one.
+func setReclaimPolicy(pv string, policy corev1.PersistentVolumeReclaimPolicy) (corev1.PersistentVolumeReclaimPolicy, error) {
+	var original corev1.PersistentVolumeReclaimPolicy
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pvObj, err := pvHandler.Get(pv)
+		if err != nil {
+			return err
+		}
+		original = pvObj.Spec.PersistentVolumeReclaimPolicy
+		if original == policy {
+			return nil
+		}
+		pvObj.Spec.PersistentVolumeReclaimPolicy = policy
+		_, err = pvHandler.Update(pvObj)
+		return err
+	}); err != nil {
+		return "", errors.Wrapf(err, "persistentvolume handler update pv/%s reclaim policy failed", pv)
+	}
+	return original, nil
+}
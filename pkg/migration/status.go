@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"encoding/json"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// getMigration get the Migration object by dynamic handler.
+func getMigration(namespace, name string) (*storagev1alpha1.Migration, error) {
+	gvk := schema.GroupVersionKind{
+		Group:   types.GroupStorage,
+		Version: types.GroupVersionStorage.Version,
+		Kind:    types.KindMigration,
+	}
+	unstructObj, err := dynHandler.WithNamespace(namespace).WithGVK(gvk).Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, `dynamic handler get "%s.%s" resource object failed`, types.ResourceMigration, types.GroupStorage)
+	}
+	migrationObj := &storagev1alpha1.Migration{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructObj.UnstructuredContent(), migrationObj); err != nil {
+		return nil, errors.Wrapf(err, "convert unstructured object to %s.%s resource object failed", types.ResourceMigration, types.GroupStorage)
+	}
+	return migrationObj, nil
+}
+
+// getBackup get the Backup object by dynamic handler.
+func getBackup(namespace, name string) (*storagev1alpha1.Backup, error) {
+	gvk := schema.GroupVersionKind{
+		Group:   types.GroupStorage,
+		Version: types.GroupVersionStorage.Version,
+		Kind:    types.KindBackup,
+	}
+	unstructObj, err := dynHandler.WithNamespace(namespace).WithGVK(gvk).Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, `dynamic handler get "%s.%s" resource object failed`, types.ResourceBackup, types.GroupStorage)
+	}
+	backupObj := &storagev1alpha1.Backup{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructObj.UnstructuredContent(), backupObj); err != nil {
+		return nil, errors.Wrapf(err, "convert unstructured object to %s.%s resource object failed", types.ResourceBackup, types.GroupStorage)
+	}
+	return backupObj, nil
+}
+
+// patchStatus patch the status subresource of the Migration object.
+// dynamic handler doesn't support update status, so we patch it by the dynamic client.
+func patchStatus(migrationObj *storagev1alpha1.Migration, status *storagev1alpha1.MigrationStatus) error {
+	data, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return errors.Wrap(err, "marshal Migration status failed")
+	}
+	gvr := schema.GroupVersionResource{
+		Group:    types.GroupStorage,
+		Version:  types.GroupVersionStorage.Version,
+		Resource: types.ResourceMigration,
+	}
+	if _, err := dynHandler.DynamicClient().Resource(gvr).Namespace(migrationObj.GetNamespace()).
+		Patch(ctx, migrationObj.GetName(), apitypes.MergePatchType, data, metav1.PatchOptions{}, "status"); err != nil {
+		return errors.Wrapf(err, "patch %s.%s status failed", types.ResourceMigration, types.GroupStorage)
+	}
+	return nil
+}
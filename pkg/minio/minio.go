@@ -48,3 +48,36 @@ func MakeBucket(client *minio.Client, name string, location string) error {
 func MakeFolder(client *minio.Client, name string) error {
 	return nil
 }
+
+// NewS3 returns the client to access the S3 compatible object storage, such as AWS S3.
+// The TLS certificate of the endpoint will not be verified if insecureSkipVerify is true.
+func NewS3(endpoint, accessKeyID, secretAccessKey, sessionToken, region string, useSSL, insecureSkipVerify bool) (*minio.Client, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+	if useSSL && insecureSkipVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	return minio.New(endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(accessKeyID, secretAccessKey, sessionToken),
+		Secure:    useSSL,
+		Region:    region,
+		Transport: transport,
+	})
+}
+
+// EnsureBucket creates the bucket in the region if it doesn't exist.
+// The existence is checked first, so the credential without the permission to
+// create bucket can still be used with the bucket created in advance.
+func EnsureBucket(client *minio.Client, name string, region string) error {
+	ctx := context.TODO()
+	exists, err := client.BucketExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return client.MakeBucket(ctx, name, minio.MakeBucketOptions{Region: region})
+}
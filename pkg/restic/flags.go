@@ -0,0 +1,20 @@
+package restic
+
+import (
+	"strconv"
+
+	"github.com/forbearing/horus-operator/pkg/types"
+	res "github.com/forbearing/restic"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewGlobalFlags returns the restic global flags to run restic command within the executor pod.
+// The flags related to the restic repository are decided by the annotations of the
+// executor pod or its pod template, such as --insecure-tls.
+func NewGlobalFlags(executor metav1.Object, json bool) *res.GlobalFlags {
+	flags := &res.GlobalFlags{NoCache: true, Json: json}
+	if executor != nil {
+		flags.InsecureTls, _ = strconv.ParseBool(executor.GetAnnotations()[types.AnnotationInsecureTLS])
+	}
+	return flags
+}
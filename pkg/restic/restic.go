@@ -1,9 +1,10 @@
 package restic
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 
@@ -21,79 +22,135 @@ var (
 	podHandler = pod.NewOrDie(ctx, "", "")
 )
 
-/// Snapshots execute `restic snapshots` within pod
-func Snapshots(ctx context.Context, storage types.Storage, cluster []string, tags []string, cmdOutput io.Writer, jsonOutputMode bool) error {
-	var (
-		err     error
-		execPod *corev1.Pod
-		podsObj []*corev1.Pod
-	)
+// execPodLabels is the label of the executor pods for every storage.
+var execPodLabels = map[types.Storage]string{
+	types.StorageNFS:        types.Backup2NFSDeployLabel,
+	types.StorageMinIO:      types.Backup2MinioDeployLabel,
+	types.StorageS3:         types.Backup2S3DeployLabel,
+	types.StorageCephFS:     types.Backup2CephFSDeployLabel,
+	types.StorageRClone:     types.Backup2RcloneDeployLabel,
+	types.StorageRestServer: types.Backup2RestServerDeployLabel,
+	types.StoragePVC:        types.Backup2PVCDeployLabel,
+	types.StorageSFTP:       types.Backup2SFTPDeployLabel,
+}
 
-	operatorNamespace := util.GetOperatorNamespace()
-	podHandler.ResetNamespace(operatorNamespace)
+// StatsModes are the counting modes supported by `restic stats`.
+var StatsModes = []string{"restore-size", "raw-data", "files-by-contents", "blobs-per-file"}
 
-	switch storage {
-	case types.StorageNFS:
-		if podsObj, err = podHandler.ListByLabel(types.Backup2NFSDeployLabel); err != nil {
-			err = errors.Wrapf(err, "pod handler list pods in namespace/%s by labels failed", operatorNamespace)
-			logrus.Error(err)
-			return err
-		}
-		if execPod = filterRunningPod(podsObj); execPod == nil {
-			err = errors.Wrapf(err, "not found running pod in namespace/%s with label %s", operatorNamespace, types.Backup2NFSDeployLabel)
-			logrus.Error(err)
-			return err
-		}
-	case types.StorageMinIO:
-		if podsObj, err = podHandler.ListByLabel(types.Backup2MinioDeployLabel); err != nil {
-			err = errors.Wrapf(err, "pod handler list pods in namespace/%s by labels failed", operatorNamespace)
-			logrus.Error(err)
-			return err
-		}
-		if execPod = filterRunningPod(podsObj); execPod == nil {
-			err = errors.Wrapf(err, "not found running pod in namespace/%s with label %s", operatorNamespace, types.Backup2MinioDeployLabel)
-			logrus.Error(err)
-			return err
-		}
-	case types.StorageS3:
-		logrus.Infof("not implemented storage type: %s", storage)
-		return nil
-	case types.StorageCephFS:
-		logrus.Infof("not implemented storage type: %s", storage)
-		return nil
-	case types.StorageSFTP:
-		logrus.Infof("not implemented storage type: %s", storage)
-		return nil
-	case types.StorageRClone:
-		logrus.Infof("not implemented storage type: %s", storage)
-		return nil
-	case types.StorageRestServer:
-		logrus.Infof("not implemented storage type: %s", storage)
-		return nil
-	default:
-		err = fmt.Errorf("not support storage type: %s", storage)
-		logrus.Error(err)
-		return err
+// Snapshots execute `restic snapshots --json` within the executor pod and returns
+// the snapshots filtered by cluster and tags.
+func Snapshots(ctx context.Context, execPod *corev1.Pod, cluster []string, tags []string) ([]NodeSnapshot, error) {
+	output, err := execJSON(ctx, execPod, res.Snapshots{Tag: tags, Host: cluster})
+	if err != nil {
+		return nil, err
 	}
+	var snapshots []NodeSnapshot
+	if err := json.Unmarshal(output, &snapshots); err != nil {
+		return nil, errors.Wrap(err, "decode restic snapshots output failed")
+	}
+	return snapshots, nil
+}
 
-	r := res.NewIgnoreNotFound(context.TODO(), &res.GlobalFlags{NoCache: true})
-	if jsonOutputMode {
-		r = res.NewIgnoreNotFound(context.TODO(), &res.GlobalFlags{NoCache: true, Json: true})
+// Stats execute `restic stats --json` within the executor pod and returns the statistics of the
+// snapshots filtered by cluster and tags, mode is the counting mode, default to "restore-size".
+func Stats(ctx context.Context, execPod *corev1.Pod, cluster []string, tags []string, mode string) (*NodeStat, error) {
+	if len(mode) == 0 {
+		mode = StatsModes[0]
+	}
+	supported := false
+	for _, m := range StatsModes {
+		if m == mode {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("not support restic stats mode: %s, must be one of %v", mode, StatsModes)
+	}
+	output, err := execJSON(ctx, execPod, res.Stats{Tag: tags, Host: cluster, Mode: mode})
+	if err != nil {
+		return nil, err
 	}
-	cmdSnapshot := r.Command(res.Snapshots{Tag: tags, Host: cluster}).String()
+	stat := &NodeStat{}
+	if err := json.Unmarshal(output, stat); err != nil {
+		return nil, errors.Wrap(err, "decode restic stats output failed")
+	}
+	return stat, nil
+}
 
-	logrus.Debugf(`execute command "%s" within "pod/%s"`, cmdSnapshot, execPod.GetName())
-	if err := podHandler.ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdSnapshot, " "), os.Stdin, cmdOutput, io.Discard); err != nil {
-		err = errors.Wrapf(err, `pod handler exec command "%s" within pod/%s failed`, cmdSnapshot, execPod.GetName())
-		logrus.Error(err)
-		return err
+// Ls execute `restic ls --json` within the executor pod and returns the files and directories in
+// the snapshot, the first one is the snapshot itself, its StructType is "snapshot".
+// Only the paths under dirs are listed if dirs is not empty. The cluster and tags
+// are used to filter the snapshots when the snapshot is "latest".
+func Ls(ctx context.Context, execPod *corev1.Pod, cluster []string, tags []string, snapshot string, dirs ...string) ([]NodeLs, error) {
+	if len(snapshot) == 0 {
+		return nil, errors.New("snapshot is required")
+	}
+	output, err := execJSON(ctx, execPod, res.Ls{Tag: tags, Host: cluster}.SetArgs(append([]string{snapshot}, dirs...)...))
+	if err != nil {
+		return nil, err
+	}
+	// restic outputs one json object per line.
+	var nodes []NodeLs
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		node := NodeLs{}
+		if err := decoder.Decode(&node); err != nil {
+			return nil, errors.Wrap(err, "decode restic ls output failed")
+		}
+		nodes = append(nodes, node)
 	}
+	return nodes, nil
+}
 
-	return nil
+// Find execute `restic find --json` within the executor pod and returns the files and directories
+// matched the patterns in every snapshot filtered by cluster and tags.
+// The snapshots not matched any pattern are not returned.
+func Find(ctx context.Context, execPod *corev1.Pod, cluster []string, tags []string, patterns ...string) ([]NodeFind, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("at least one pattern is required")
+	}
+	output, err := execJSON(ctx, execPod, res.Find{Tag: tags, Host: cluster}.SetArgs(patterns...))
+	if err != nil {
+		return nil, err
+	}
+	var finds []NodeFind
+	if err := json.Unmarshal(output, &finds); err != nil {
+		return nil, errors.Wrap(err, "decode restic find output failed")
+	}
+	return finds, nil
 }
 
-func Stats() {
+// execJSON execute the restic command with flag --json within the executor pod and
+// returns its output.
+func execJSON(ctx context.Context, execPod *corev1.Pod, command res.Command) ([]byte, error) {
+	cmdRestic := res.NewIgnoreNotFound(ctx, NewGlobalFlags(execPod, true)).Command(command).String()
+
+	logrus.Debugf(`execute command "%s" within "pod/%s"`, cmdRestic, execPod.GetName())
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if err := podHandler.WithNamespace(execPod.GetNamespace()).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdRestic, " "), os.Stdin, stdout, stderr); err != nil {
+		return nil, errors.Wrapf(err, `pod handler exec command "%s" within pod/%s failed: %s`, cmdRestic, execPod.GetName(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
 
+// FindExecPod returns a running executor pod of the storage in the operator namespace,
+// the restic repository is accessible within the pod. The executor pods only exist
+// while the backup is running.
+func FindExecPod(storage types.Storage) (*corev1.Pod, error) {
+	label, ok := execPodLabels[storage]
+	if !ok {
+		return nil, fmt.Errorf("not support storage type: %s", storage)
+	}
+	operatorNamespace := util.GetOperatorNamespace()
+	podsObj, err := podHandler.WithNamespace(operatorNamespace).ListByLabel(label)
+	if err != nil {
+		return nil, errors.Wrapf(err, "pod handler list pods in namespace/%s by labels failed", operatorNamespace)
+	}
+	execPod := filterRunningPod(podsObj)
+	if execPod == nil {
+		return nil, fmt.Errorf("not found running pod in namespace/%s with label %s", operatorNamespace, label)
+	}
+	return execPod, nil
 }
 
 // filterRunningPod
@@ -1,6 +1,7 @@
 package restic
 
 import (
+	"encoding/json"
 	"os"
 	"time"
 )
@@ -11,6 +12,10 @@ type NodeStat struct {
 	TotalSize      int64 `json:"total_size"`
 	TotalFileCount int64 `json:"total_file_count"`
 	SnapshotsCount int64 `json:"snapshots_count"`
+	// the fields only reported in mode "raw-data".
+	TotalBlobCount        int64   `json:"total_blob_count,omitempty"`
+	TotalUncompressedSize int64   `json:"total_uncompressed_size,omitempty"`
+	CompressionRatio      float64 `json:"compression_ratio,omitempty"`
 }
 
 // NodeSnapshot represents the output of restic subcommand `snapshots`.
@@ -28,11 +33,77 @@ type NodeSnapshot struct {
 	ShortID  string    `json:"short_id"`
 }
 
+// SnapshotTarget is the backup target of the snapshot.
+type SnapshotTarget struct {
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// PVC is the persistentvolumeclaim backed up, or the key of the manifests and
+	// database dump backed up from the stdin.
+	PVC string `json:"pvc"`
+}
+
+// Target returns the backup target of the snapshot decoded from its tags, which are
+// set by horusctl in order: resource, namespace, name and persistentvolumeclaim.
+// The fields are empty if the snapshot is not created by horusctl.
+func (s NodeSnapshot) Target() SnapshotTarget {
+	if len(s.Tags) < 4 {
+		return SnapshotTarget{}
+	}
+	return SnapshotTarget{Resource: s.Tags[0], Namespace: s.Tags[1], Name: s.Tags[2], PVC: s.Tags[3]}
+}
+
+// NodeBackupSummary represents the summary message of restic subcommand `backup`.
+// eg: `restic backup --json /data`
+type NodeBackupSummary struct {
+	MessageType         string  `json:"message_type"` // "summary"
+	FilesNew            uint64  `json:"files_new"`
+	FilesChanged        uint64  `json:"files_changed"`
+	FilesUnmodified     uint64  `json:"files_unmodified"`
+	DirsNew             uint64  `json:"dirs_new"`
+	DirsChanged         uint64  `json:"dirs_changed"`
+	DirsUnmodified      uint64  `json:"dirs_unmodified"`
+	DataBlobs           int64   `json:"data_blobs"`
+	TreeBlobs           int64   `json:"tree_blobs"`
+	DataAdded           int64   `json:"data_added"`
+	TotalFilesProcessed uint64  `json:"total_files_processed"`
+	TotalBytesProcessed uint64  `json:"total_bytes_processed"`
+	TotalDuration       float64 `json:"total_duration"` // in seconds
+	SnapshotID          string  `json:"snapshot_id"`
+}
+
+// NodeBackupStatus represents the status message of restic subcommand `backup`,
+// it's the progress of the backup.
+// eg: `restic backup --json /data`
+type NodeBackupStatus struct {
+	MessageType      string   `json:"message_type"` // "status"
+	SecondsElapsed   uint64   `json:"seconds_elapsed"`
+	SecondsRemaining uint64   `json:"seconds_remaining"`
+	PercentDone      float64  `json:"percent_done"`
+	TotalFiles       uint64   `json:"total_files"`
+	FilesDone        uint64   `json:"files_done"`
+	TotalBytes       uint64   `json:"total_bytes"`
+	BytesDone        uint64   `json:"bytes_done"`
+	ErrorCount       uint64   `json:"error_count"`
+	CurrentFiles     []string `json:"current_files"`
+}
+
+// NodeBackupError represents the error message of restic subcommand `backup`,
+// the file or directory item can't be read during backup.
+// eg: `restic backup --json /data`
+type NodeBackupError struct {
+	MessageType string `json:"message_type"` // "error"
+	// Error is the json encoded go error, its format depends on the error type.
+	Error  json.RawMessage `json:"error"`
+	During string          `json:"during"`
+	Item   string          `json:"item"`
+}
+
 // NodeFind represents the output of restic subcommand `find`.
 // eg: `restic find 871dafac zshrc --json`
 type NodeFind struct {
 	Matches  []Matche `json:"matches"`
-	Hits     uint     `json:"hint"`
+	Hits     uint     `json:"hits"`
 	Snapshot string   `json:"snapshot"`
 }
 
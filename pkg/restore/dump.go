@@ -0,0 +1,69 @@
+package restore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/dump"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	res "github.com/forbearing/restic"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// runDump restores the database dump taken by Backup.spec.dump to the database
+// running in the k8s resource defined in Restore.spec.restoreTo. The output of
+// `restic dump` is streamed to the database client executed in the pod, so the
+// deployment/statefulset is not scaled down.
+func runDump(logger *logrus.Entry, restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, storage types.Storage, status *storagev1alpha1.RestoreStatus) error {
+	begin := time.Now()
+	restoreTo := restoreObj.Spec.RestoreTo
+	dumpSpec := backupObj.Spec.Dump
+	key := dump.Key(dumpSpec.Type)
+	username, password, err := dump.Credential(dumpSpec, restoreObj.GetNamespace())
+	if err != nil {
+		return err
+	}
+	cmdRestore, err := dump.RestoreCommand(dumpSpec, username, password)
+	if err != nil {
+		return err
+	}
+	podObj, err := dump.Pod(restoreObj.GetNamespace(), restoreTo.Resource, restoreTo.Name)
+	if err != nil {
+		return err
+	}
+
+	lookupPod, err := createLookupExecutor(restoreObj, backupObj, storage)
+	if err != nil {
+		return err
+	}
+	snapshot, err := findSnapshot(logger, lookupPod, restoreObj, snapshotHost(restoreObj, backupObj), snapshotTags(restoreObj, backupObj, key))
+	if err != nil {
+		return errors.Wrapf(err, "find snapshot for %s failed", key)
+	}
+	logger.Infof("%s will be restored from snapshot %s", key, snapshot.ShortID)
+
+	r := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(lookupPod, false))
+	cmdDump := r.Command(res.Dump{}.SetArgs(snapshot.ID, "/"+dump.Filename(dumpSpec.Type))).String()
+	logger.Debug(cmdDump)
+	if err := dump.Stream(lookupPod, "", dump.Exec{Command: strings.Split(cmdDump, " ")}, podObj, dumpSpec.Container, cmdRestore, io.Discard); err != nil {
+		return errors.Wrapf(err, "restore %s from snapshot %s failed", key, snapshot.ShortID)
+	}
+
+	restoredBytes, sizeErr := snapshotSize(logger, lookupPod, snapshot)
+	if sizeErr != nil {
+		logger.Warnf("get the restore size of snapshot %s failed: %s", snapshot.ShortID, sizeErr.Error())
+	}
+	status.PVCs = append(status.PVCs, storagev1alpha1.RestoredPVC{
+		Name:          key,
+		Snapshot:      snapshot.ShortID,
+		RestoredBytes: restoredBytes,
+	})
+	status.RestoredBytes += restoredBytes
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully restore %s to pod/%s from snapshot %s", key, podObj.GetName(), snapshot.ShortID)
+	return nil
+}
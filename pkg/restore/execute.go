@@ -0,0 +1,182 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/backup"
+	"github.com/forbearing/horus-operator/pkg/dump"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/horus-operator/pkg/util"
+	res "github.com/forbearing/restic"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lookupExecutorName returns the name of deployment that used to find restic snapshots.
+func lookupExecutorName(restoreObj *storagev1alpha1.Restore) string {
+	return fmt.Sprintf("%s-%s", restoreExecutorName, restoreObj.GetName())
+}
+
+// restoreExecutorNameFor returns the name of deployment that used to restore the index-th persistentvolumeclaim.
+func restoreExecutorNameFor(restoreObj *storagev1alpha1.Restore, index int) string {
+	return fmt.Sprintf("%s-%s-%d", restoreExecutorName, restoreObj.GetName(), index)
+}
+
+// newExecutor construct a deployment to run restic command from the executor
+// deployment used by backup. The deployment doesn't mount the k8s node root
+// directory, it will run on the k8s node nodeName, let nodeName empty if the
+// deployment should be scheduled by kube-scheduler. If the restic repository is
+// a ReadWriteOnce persistentvolumeclaim and nodeName is empty, the deployment is
+// pinned to the k8s node where the persistentvolumeclaim is attached.
+func newExecutor(restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, storage types.Storage, name, nodeName string) (*appsv1.Deployment, error) {
+	deployObj, err := backup.ExecutorDeployment(backupObj, storage, name, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	// The executor deployments for backup share the same selector, we should
+	// use our own labels to prevent them from selecting each other's pods.
+	labels := map[string]string{
+		types.LabelName:      restoreExecutorName,
+		types.LabelInstance:  string(restoreObj.GetUID()),
+		types.LabelPartOf:    "horus",
+		types.LabelManagedBy: "horus-operator",
+	}
+	deployObj.SetLabels(labels)
+	deployObj.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+	deployObj.Spec.Template.SetLabels(labels)
+
+	backup.RemoveHostRoot(&deployObj.Spec.Template.Spec)
+	return deployObj, nil
+}
+
+// createLookupExecutor creates the deployment in the operator namespace to find
+// restic snapshots and returns its running pod.
+func createLookupExecutor(restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, storage types.Storage) (*corev1.Pod, error) {
+	deployObj, err := newExecutor(restoreObj, backupObj, storage, lookupExecutorName(restoreObj), "")
+	if err != nil {
+		return nil, err
+	}
+	return backup.CreateExecutor(util.GetOperatorNamespace(), deployObj)
+}
+
+// deleteLookupExecutor deletes the deployment created by createLookupExecutor.
+func deleteLookupExecutor(restoreObj *storagev1alpha1.Restore) {
+	depHandler.WithNamespace(util.GetOperatorNamespace()).Delete(lookupExecutorName(restoreObj))
+}
+
+// restorePVC restores the snapshot data to the persistentvolume bound to the
+// persistentvolumeclaim in its k8s node directory.
+//
+// The persistentvolume data directory in the k8s node is found by the findpvdir
+// job the same as backup, see backup.VolumeDir. The executor deployment in the
+// operator namespace runs on that k8s node and mounts the directory at
+// restoreTargetPath, then `restic restore <snapshot>:<snapshot path> --target restoreTargetPath`
+// writes the data backed up from the snapshot path to the directory.
+func restorePVC(logger *logrus.Entry, restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, storage types.Storage,
+	index int, pvc string, snapshot *restic.NodeSnapshot) error {
+	nodeName, pvpath, cleanup, err := backup.VolumeDir(backupObj, restoreObj.GetNamespace(), pvc)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	logger.Debugf("the data directory of pvc/%s in k8s node %s: %s", pvc, nodeName, pvpath)
+
+	operatorNamespace := util.GetOperatorNamespace()
+	name := restoreExecutorNameFor(restoreObj, index)
+	deployObj, err := newExecutor(restoreObj, backupObj, storage, name, nodeName)
+	if err != nil {
+		return err
+	}
+	hostPathType := corev1.HostPathDirectory
+	podSpec := &deployObj.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: restoreTargetVolume,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: pvpath, Type: &hostPathType},
+		},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      restoreTargetVolume,
+			MountPath: restoreTargetPath,
+		})
+	}
+
+	execPod, err := backup.CreateExecutor(operatorNamespace, deployObj)
+	defer depHandler.WithNamespace(operatorNamespace).Delete(name)
+	if err != nil {
+		return err
+	}
+
+	r := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, false))
+	cmdRestore := r.Command(res.Restore{Target: restoreTargetPath}.SetArgs(snapshot.ID + ":" + snapshot.Paths[0])).String()
+	logger.Debug(cmdRestore)
+	stderr := new(bytes.Buffer)
+	if err := podHandler.WithNamespace(operatorNamespace).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdRestore, " "), dump.NoStdin(), io.Discard, stderr); err != nil {
+		return fmt.Errorf("restic restore snapshot %s failed: %s", snapshot.ShortID, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// findSnapshot execute `restic snapshots` within the pod and returns the snapshot
+// matched Restore.spec.snapshot, host and tags. The latest snapshot will be returned
+// if Restore.spec.snapshot is empty or "latest".
+func findSnapshot(logger *logrus.Entry, execPod *corev1.Pod, restoreObj *storagev1alpha1.Restore, host string, tags []string) (*restic.NodeSnapshot, error) {
+	r := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, true))
+	cmdSnapshots := r.Command(res.Snapshots{Host: []string{host}, Tag: tags}).String()
+	logger.Debug(cmdSnapshots)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if err := podHandler.WithNamespace(execPod.GetNamespace()).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdSnapshots, " "), dump.NoStdin(), stdout, stderr); err != nil {
+		return nil, fmt.Errorf("restic snapshots failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	var snapshots []restic.NodeSnapshot
+	if err := json.Unmarshal(stdout.Bytes(), &snapshots); err != nil {
+		return nil, errors.Wrap(err, "decode restic snapshots output failed")
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshot found with host %q and tags %v", host, tags)
+	}
+
+	if !isSpecifiedSnapshot(restoreObj) {
+		latest := &snapshots[0]
+		for i := range snapshots {
+			if snapshots[i].Time.After(latest.Time) {
+				latest = &snapshots[i]
+			}
+		}
+		return latest, nil
+	}
+	for i := range snapshots {
+		if strings.HasPrefix(snapshots[i].ID, restoreObj.Spec.Snapshot) {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot %s not found with host %q and tags %v", restoreObj.Spec.Snapshot, host, tags)
+}
+
+// snapshotSize execute `restic stats` within the pod and returns the restore size of the snapshot.
+func snapshotSize(logger *logrus.Entry, execPod *corev1.Pod, snapshot *restic.NodeSnapshot) (int64, error) {
+	r := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(execPod, true))
+	cmdStats := r.Command(res.Stats{Mode: "restore-size"}.SetArgs(snapshot.ID)).String()
+	logger.Debug(cmdStats)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if err := podHandler.WithNamespace(execPod.GetNamespace()).ExecuteWithStream(execPod.GetName(), "", strings.Split(cmdStats, " "), dump.NoStdin(), stdout, stderr); err != nil {
+		return 0, fmt.Errorf("restic stats failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	stat := restic.NodeStat{}
+	if err := json.Unmarshal(stdout.Bytes(), &stat); err != nil {
+		return 0, errors.Wrap(err, "decode restic stats output failed")
+	}
+	return stat.TotalSize, nil
+}
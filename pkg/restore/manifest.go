@@ -0,0 +1,66 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/dump"
+	"github.com/forbearing/horus-operator/pkg/manifest"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	res "github.com/forbearing/restic"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// restoreManifests creates the k8s objects backed up by Backup.spec.manifests in the
+// namespace of the Restore object, the objects already exist are not changed.
+// The manifests are always restored from the latest snapshot, Restore.spec.snapshot
+// and Restore.spec.tags only select the persistentvolumeclaim snapshots.
+func restoreManifests(logger *logrus.Entry, restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, storage types.Storage) error {
+	begin := time.Now()
+	var key []byte
+	credentialName := restoreObj.Spec.Manifests.EncryptionCredentialName
+	if len(credentialName) == 0 && backupObj.Spec.Manifests != nil {
+		credentialName = backupObj.Spec.Manifests.EncryptionCredentialName
+	}
+	if len(credentialName) != 0 {
+		var err error
+		if key, err = manifest.EncryptionKey(credentialName); err != nil {
+			return err
+		}
+	}
+
+	lookupPod, err := createLookupExecutor(restoreObj, backupObj, storage)
+	if err != nil {
+		return err
+	}
+	lookupObj := restoreObj.DeepCopy()
+	lookupObj.Spec.Snapshot, lookupObj.Spec.Tags = "", nil
+	snapshot, err := findSnapshot(logger, lookupPod, lookupObj, snapshotHost(restoreObj, backupObj), snapshotTags(lookupObj, backupObj, manifest.Key))
+	if err != nil {
+		return errors.Wrap(err, "find snapshot for manifests failed")
+	}
+	logger.Infof("manifests will be restored from snapshot %s", snapshot.ShortID)
+
+	r := res.NewIgnoreNotFound(context.TODO(), restic.NewGlobalFlags(lookupPod, false))
+	cmdDump := r.Command(res.Dump{}.SetArgs(snapshot.ID, "/"+manifest.Filename)).String()
+	logger.Debug(cmdDump)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if err := podHandler.WithNamespace(lookupPod.GetNamespace()).ExecuteWithStream(lookupPod.GetName(), "", strings.Split(cmdDump, " "), dump.NoStdin(), stdout, stderr); err != nil {
+		return fmt.Errorf("restic dump manifests from snapshot %s failed: %s", snapshot.ShortID, strings.TrimSpace(stderr.String()))
+	}
+	created, err := manifest.Apply(stdout.Bytes(), restoreObj.GetNamespace(), key)
+	for _, object := range created {
+		logger.Infof("Successfully create %s", object)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "restore manifests from snapshot %s failed", snapshot.ShortID)
+	}
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully restore manifests from snapshot %s", snapshot.ShortID)
+	return nil
+}
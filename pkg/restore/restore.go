@@ -0,0 +1,297 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/backup"
+	"github.com/forbearing/horus-operator/pkg/restic"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/forbearing/k8s/deployment"
+	"github.com/forbearing/k8s/dynamic"
+	"github.com/forbearing/k8s/persistentvolumeclaim"
+	"github.com/forbearing/k8s/pod"
+	"github.com/forbearing/k8s/statefulset"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// restoreExecutorName is the name prefix of the deployments that run restic
+	// command to restore persistentvolumeclaim data.
+	restoreExecutorName = "restore-to-pvc"
+	// restoreTargetPath is the directory restic restore data to, the persistentvolume
+	// data directory in the k8s node is mounted at restoreTargetPath.
+	restoreTargetPath   = "/restore-target"
+	restoreTargetVolume = "restore-target"
+
+	latestSnapshot = "latest"
+)
+
+var (
+	ctx        = context.TODO()
+	podHandler = pod.NewOrDie(ctx, "", "")
+	depHandler = deployment.NewOrDie(ctx, "", "")
+	stsHandler = statefulset.NewOrDie(ctx, "", "")
+	pvcHandler = persistentvolumeclaim.NewOrDie(ctx, "", "")
+	dynHandler = dynamic.NewOrDie(ctx, "", "")
+)
+
+// logger is the base logger, every restore derives its own *logrus.Entry from
+// it and passes it down, so the fields don't pile up across restores.
+var (
+	logger = logrus.WithFields(logrus.Fields{})
+)
+
+// Do start to restore the persistentvolumeclaim data of the deployment/statefulset/persistentvolumeclaim
+// defined in Restore object.
+// namespace is the Restore object namespace
+// name is the Restore object name
+func Do(ctx context.Context, namespace, name string) (err error) {
+	begin := time.Now()
+	restoreObj, err := getRestore(namespace, name)
+	if err != nil {
+		logger.WithField("namespace", namespace).Error(err)
+		return err
+	}
+	if restoreObj.Spec.RestoreFrom == nil || restoreObj.Spec.RestoreTo == nil {
+		err = errors.New("Restore.spec.restoreFrom and Restore.spec.restoreTo are required")
+		logger.Error(err)
+		return err
+	}
+	// setup logger
+	restoreTo := restoreObj.Spec.RestoreTo
+	logger := logger.WithFields(logrus.Fields{
+		"name":      name,
+		"namespace": namespace,
+		"resource":  restoreTo.Resource,
+	})
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully get Restore object")
+
+	// The Restore status is updated when restore begin and finish, whether success or failure.
+	status := restoreObj.Status.DeepCopy()
+	startTime := metav1.Now()
+	status.Phase = storagev1alpha1.RestoreRunning
+	status.StartTime = &startTime
+	status.CompletionTime = nil
+	status.RestoredBytes = 0
+	status.PVCs = nil
+	status.Message = ""
+	if err := patchStatus(restoreObj, status); err != nil {
+		logger.Warn(err)
+	}
+	defer func() {
+		completionTime := metav1.Now()
+		status.CompletionTime = &completionTime
+		if err != nil {
+			logger.Error(err)
+			status.Phase = storagev1alpha1.RestoreFailed
+			status.Message = err.Error()
+		} else {
+			status.Phase = storagev1alpha1.RestoreSucceeded
+			status.Message = fmt.Sprintf("Successfully restore %d persistentvolumeclaim(s)", len(status.PVCs))
+		}
+		if err := patchStatus(restoreObj, status); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	backupObj, err := sourceBackup(restoreObj)
+	if err != nil {
+		return err
+	}
+	return Run(ctx, restoreObj, backupObj, status)
+}
+
+// Run restores the persistentvolumeclaim data of the k8s resource defined in Restore
+// object from the restic repository defined in Backup object, and append the restored
+// persistentvolumeclaims to status.
+// The Restore object is not required to exist in k8s, Clone and Migration construct
+// a Restore object to restore data to the new persistentvolumeclaims.
+func Run(ctx context.Context, restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, status *storagev1alpha1.RestoreStatus) (err error) {
+	// ==============================
+	// 1. prepare the restic repository and the persistentvolumeclaims to restore
+	// ==============================
+	begin := time.Now()
+	storage, err := sourceStorage(restoreObj, backupObj)
+	if err != nil {
+		return err
+	}
+	logger := logger.WithFields(logrus.Fields{
+		"name":      restoreObj.GetName(),
+		"namespace": restoreObj.GetNamespace(),
+		"storage":   storage,
+	})
+	if restoreTo := restoreObj.Spec.RestoreTo; restoreTo != nil {
+		logger = logger.WithField("resource", restoreTo.Resource)
+	}
+	// the lookup executor is created by the first step needs it and shared by the
+	// following steps.
+	defer deleteLookupExecutor(restoreObj)
+	// the manifests are restored first, the k8s resource to restore may not exist.
+	if restoreObj.Spec.Manifests != nil {
+		if err = restoreManifests(logger, restoreObj, backupObj, storage); err != nil {
+			return err
+		}
+	}
+	if backupObj.Spec.Dump != nil {
+		return runDump(logger, restoreObj, backupObj, storage, status)
+	}
+	target, err := newRestoreTarget(restoreObj)
+	if err != nil {
+		return err
+	}
+	if len(target.pvcs) > 1 && (len(restoreObj.Spec.Tags) != 0 || isSpecifiedSnapshot(restoreObj)) {
+		return fmt.Errorf("%s/%s has %d persistentvolumeclaims, Restore.spec.snapshot and Restore.spec.tags can only be used to restore one persistentvolumeclaim",
+			target.resource, target.name, len(target.pvcs))
+	}
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("The persistentvolumeclaims to restore are: %v", target.pvcs)
+
+	// ==============================
+	// 2. find the restic snapshot for every persistentvolumeclaim
+	// ==============================
+	begin = time.Now()
+	lookupPod, err := createLookupExecutor(restoreObj, backupObj, storage)
+	if err != nil {
+		return err
+	}
+	snapshots := make(map[string]*restic.NodeSnapshot)
+	restoredBytes := make(map[string]int64)
+	for _, pvc := range target.pvcs {
+		snapshot, err := findSnapshot(logger, lookupPod, restoreObj, snapshotHost(restoreObj, backupObj), snapshotTags(restoreObj, backupObj, pvc))
+		if err != nil {
+			return errors.Wrapf(err, "find snapshot for pvc/%s failed", pvc)
+		}
+		if len(snapshot.Paths) == 0 {
+			return fmt.Errorf("snapshot %s for pvc/%s has no path", snapshot.ShortID, pvc)
+		}
+		logger.Infof("pvc/%s will be restored from snapshot %s", pvc, snapshot.ShortID)
+		snapshots[pvc] = snapshot
+		if restoredBytes[pvc], err = snapshotSize(logger, lookupPod, snapshot); err != nil {
+			logger.Warnf("get the restore size of snapshot %s failed: %s", snapshot.ShortID, err.Error())
+		}
+	}
+	// the restore executors run on the k8s nodes of the persistentvolumes, delete the
+	// lookup executor first, it may hold the ReadWriteOnce restic repository.
+	deleteLookupExecutor(restoreObj)
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully find snapshots")
+
+	// ==============================
+	// 3. scale down the deployment/statefulset, and restore the persistentvolumeclaim data.
+	// ==============================
+	begin = time.Now()
+	// always scale up the deployment/statefulset to the original replicas,
+	// even if scale down failed.
+	defer func() {
+		if err := target.scaleUp(); err != nil {
+			logger.Error(err)
+		}
+	}()
+	if err = target.scaleDown(); err != nil {
+		return err
+	}
+	logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully scale down %s/%s", target.resource, target.name)
+
+	for i, pvc := range target.pvcs {
+		begin := time.Now()
+		snapshot := snapshots[pvc]
+		if err = restorePVC(logger, restoreObj, backupObj, storage, i, pvc, snapshot); err != nil {
+			return errors.Wrapf(err, "restore pvc/%s failed", pvc)
+		}
+		status.PVCs = append(status.PVCs, storagev1alpha1.RestoredPVC{
+			Name:          pvc,
+			Snapshot:      snapshot.ShortID,
+			RestoredBytes: restoredBytes[pvc],
+		})
+		status.RestoredBytes += restoredBytes[pvc]
+		logger.WithField("cost", time.Now().Sub(begin).String()).Infof("Successfully restore pvc/%s from snapshot %s", pvc, snapshot.ShortID)
+	}
+
+	logger.Infof("Successfully restore %s/%s", target.resource, target.name)
+	return nil
+}
+
+// sourceBackup returns the Backup object defined in Restore.spec.restoreFrom.backup.
+// If Restore.spec.restoreFrom.backup is empty, a Backup object will be constructed
+// from the Restore.spec.restoreFrom.repository.
+func sourceBackup(restoreObj *storagev1alpha1.Restore) (*storagev1alpha1.Backup, error) {
+	restoreFrom := restoreObj.Spec.RestoreFrom
+	if len(restoreFrom.Backup) != 0 {
+		return getBackup(restoreObj.GetNamespace(), restoreFrom.Backup)
+	}
+	if restoreFrom.Repository == nil {
+		return nil, errors.New("either Restore.spec.restoreFrom.backup or Restore.spec.restoreFrom.repository must be specified")
+	}
+	if len(restoreFrom.CredentialName) == 0 {
+		return nil, errors.New("Restore.spec.restoreFrom.credentialName is required when restore from repository")
+	}
+	return &storagev1alpha1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restoreObj.GetName(),
+			Namespace: restoreObj.GetNamespace(),
+		},
+		Spec: storagev1alpha1.BackupSpec{
+			TimeZone:       restoreObj.Spec.TimeZone,
+			CredentialName: restoreFrom.CredentialName,
+			BackupTo:       restoreFrom.Repository,
+		},
+	}, nil
+}
+
+// sourceStorage returns the storage that restore from.
+func sourceStorage(restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup) (types.Storage, error) {
+	if backupObj.Spec.BackupTo == nil {
+		return "", errors.New("no storage defined in Backup.spec.backupTo or Restore.spec.restoreFrom.repository")
+	}
+	storages := backup.ParseStorage(backupObj)
+	if len(storages) == 0 {
+		return "", errors.New("no storage defined in Backup.spec.backupTo or Restore.spec.restoreFrom.repository")
+	}
+	storage := types.Storage(restoreObj.Spec.RestoreFrom.Storage)
+	if len(storage) == 0 {
+		return storages[0], nil
+	}
+	for _, s := range storages {
+		if s == storage {
+			return storage, nil
+		}
+	}
+	return "", fmt.Errorf("storage %s not defined in Backup.spec.backupTo", storage)
+}
+
+// snapshotHost returns the hostname used to filter restic snapshots.
+func snapshotHost(restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup) string {
+	if len(restoreObj.Spec.Host) != 0 {
+		return restoreObj.Spec.Host
+	}
+	if len(backupObj.Spec.Cluster) != 0 {
+		return backupObj.Spec.Cluster
+	}
+	return types.DefaultClusterName
+}
+
+// snapshotTags returns the tags used to filter restic snapshots.
+// The default tags are the same as the Backup object set, see pkg/backup/execute.go.
+// If the Backup object backup many targets, the restore target is treated as the
+// backup target.
+func snapshotTags(restoreObj *storagev1alpha1.Restore, backupObj *storagev1alpha1.Backup, pvc string) []string {
+	if len(restoreObj.Spec.Tags) != 0 {
+		return restoreObj.Spec.Tags
+	}
+	if backupFrom := backupObj.Spec.BackupFrom; backupFrom != nil && len(backupFrom.Name) != 0 {
+		return []string{string(backupFrom.Resource), backupObj.GetNamespace(), backupFrom.Name, pvc}
+	}
+	if restoreTo := restoreObj.Spec.RestoreTo; restoreTo != nil {
+		return []string{string(restoreTo.Resource), restoreObj.GetNamespace(), restoreTo.Name, pvc}
+	}
+	return []string{pvc}
+}
+
+// isSpecifiedSnapshot returns true if Restore.spec.snapshot is set and not "latest".
+func isSpecifiedSnapshot(restoreObj *storagev1alpha1.Restore) bool {
+	snapshot := restoreObj.Spec.Snapshot
+	return len(snapshot) != 0 && snapshot != latestSnapshot
+}
@@ -0,0 +1,68 @@
+package restore
+
+import (
+	"encoding/json"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// getRestore get the Restore object by dynamic handler.
+func getRestore(namespace, name string) (*storagev1alpha1.Restore, error) {
+	gvk := schema.GroupVersionKind{
+		Group:   types.GroupStorage,
+		Version: types.GroupVersionStorage.Version,
+		Kind:    types.KindRestore,
+	}
+	unstructObj, err := dynHandler.WithNamespace(namespace).WithGVK(gvk).Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, `dynamic handler get "%s.%s" resource object failed`, types.ResourceRestore, types.GroupStorage)
+	}
+	restoreObj := &storagev1alpha1.Restore{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructObj.UnstructuredContent(), restoreObj); err != nil {
+		return nil, errors.Wrapf(err, "convert unstructured object to %s.%s resource object failed", types.ResourceRestore, types.GroupStorage)
+	}
+	return restoreObj, nil
+}
+
+// getBackup get the Backup object by dynamic handler.
+func getBackup(namespace, name string) (*storagev1alpha1.Backup, error) {
+	gvk := schema.GroupVersionKind{
+		Group:   types.GroupStorage,
+		Version: types.GroupVersionStorage.Version,
+		Kind:    types.KindBackup,
+	}
+	unstructObj, err := dynHandler.WithNamespace(namespace).WithGVK(gvk).Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, `dynamic handler get "%s.%s" resource object failed`, types.ResourceBackup, types.GroupStorage)
+	}
+	backupObj := &storagev1alpha1.Backup{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructObj.UnstructuredContent(), backupObj); err != nil {
+		return nil, errors.Wrapf(err, "convert unstructured object to %s.%s resource object failed", types.ResourceBackup, types.GroupStorage)
+	}
+	return backupObj, nil
+}
+
+// patchStatus patch the status subresource of the Restore object.
+// dynamic handler doesn't support update status, so we patch it by the dynamic client.
+func patchStatus(restoreObj *storagev1alpha1.Restore, status *storagev1alpha1.RestoreStatus) error {
+	data, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return errors.Wrap(err, "marshal Restore status failed")
+	}
+	gvr := schema.GroupVersionResource{
+		Group:    types.GroupStorage,
+		Version:  types.GroupVersionStorage.Version,
+		Resource: types.ResourceRestore,
+	}
+	if _, err := dynHandler.DynamicClient().Resource(gvr).Namespace(restoreObj.GetNamespace()).
+		Patch(ctx, restoreObj.GetName(), apitypes.MergePatchType, data, metav1.PatchOptions{}, "status"); err != nil {
+		return errors.Wrapf(err, "patch %s.%s status failed", types.ResourceRestore, types.GroupStorage)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package restore
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/horus-operator/pkg/workload"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restoreTarget is the k8s resource that persistentvolumeclaim data restore to.
+// workload is the deployment/statefulset that will be scaled down to zero before
+// restore and scaled up to the original replicas after restore, it's nil if
+// restore to a persistentvolumeclaim.
+// pvcs is all persistentvolumeclaims should be restored.
+type restoreTarget struct {
+	namespace string
+	name      string
+	resource  storagev1alpha1.Resource
+	workload  *workload.Workload
+	pvcs      []string
+}
+
+// newRestoreTarget find out all persistentvolumeclaims mounted by the k8s resource
+// defined in Restore.spec.restoreTo.
+func newRestoreTarget(restoreObj *storagev1alpha1.Restore) (*restoreTarget, error) {
+	restoreTo := restoreObj.Spec.RestoreTo
+	target := &restoreTarget{
+		namespace: restoreObj.GetNamespace(),
+		name:      restoreTo.Name,
+		resource:  restoreTo.Resource,
+	}
+
+	switch restoreTo.Resource {
+	case storagev1alpha1.DeploymentResource, storagev1alpha1.StatefulSetResource:
+		w, err := workload.Get(target.namespace, restoreTo.Resource, restoreTo.Name)
+		if err != nil {
+			return nil, err
+		}
+		target.workload = w
+		target.pvcs = w.PVCs
+	case storagev1alpha1.PersistentVolumeClaim:
+		if _, err := pvcHandler.WithNamespace(target.namespace).Get(restoreTo.Name); err != nil {
+			return nil, errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", restoreTo.Name)
+		}
+		// restore data to persistentvolumeclaim which is mounted by running pod may
+		// corrupt the data of the application, so we require it not mounted.
+		podObjs, err := podHandler.WithNamespace(target.namespace).List()
+		if err != nil {
+			return nil, errors.Wrap(err, "pod handler list pods failed")
+		}
+		for _, podObj := range podObjs {
+			if podObj.Status.Phase == corev1.PodSucceeded || podObj.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			for _, pvc := range workload.PodTemplatePVC(corev1.PodTemplateSpec{Spec: podObj.Spec}) {
+				if pvc == restoreTo.Name {
+					return nil, fmt.Errorf("pvc/%s is mounted by pod/%s, scale down the workload before restore", pvc, podObj.GetName())
+				}
+			}
+		}
+		target.pvcs = []string{restoreTo.Name}
+	case storagev1alpha1.PodResource, storagev1alpha1.DaemonSetResource:
+		return nil, fmt.Errorf("restore to %s is not supported, only deployment, statefulset and persistentvolumeclaim are supported", restoreTo.Resource)
+	default:
+		return nil, fmt.Errorf("not support restore resource: %s", restoreTo.Resource)
+	}
+
+	if len(target.pvcs) == 0 {
+		return nil, fmt.Errorf("There is no pvc mounted by the %s/%s, skip restore", restoreTo.Resource, restoreTo.Name)
+	}
+	return target, nil
+}
+
+// scaleDown scale the deployment/statefulset replicas to zero and wait for all pods terminated.
+func (t *restoreTarget) scaleDown() error {
+	if t.workload == nil {
+		return nil
+	}
+	return t.workload.ScaleDown()
+}
+
+// scaleUp scale the deployment/statefulset to the original replicas.
+func (t *restoreTarget) scaleUp() error {
+	if t.workload == nil {
+		return nil
+	}
+	return t.workload.ScaleUp()
+}
@@ -1,13 +1,62 @@
 package template
 
 var (
-	// The ClusterRole for horusctl to backup/restore/clone/migration pvc data.
+	// The ClusterRole bound to horusctl by ClusterRoleBinding, it only contains the
+	// permissions of the cluster-scoped resources. The permissions of the namespaced
+	// resources are in NamespacedRoleForBackup and only granted in the namespaces
+	// horusctl works in.
 	ClusterRoleForBackup = `
 apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRole
 metadata:
   name: horusctl-role
 rules:
+# permissions for horusctl to find the persistentvolumes of persistentvolumeclaims when backup,
+# recreate persistentvolumes when restore manifests, and swap persistentvolumes when migration.
+- apiGroups:
+  - ""
+  resources:
+  - persistentvolumes
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+  - delete
+  - update
+  - patch
+# permissions for horusctl to select namespaces when backup and create namespaces when clone.
+- apiGroups:
+  - ""
+  resources:
+  - namespaces
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+# permissions for horusctl to take volumesnapshots of persistentvolumeclaims when backup.
+- apiGroups:
+  - snapshot.storage.k8s.io
+  resources:
+  - volumesnapshots
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+  - delete
+`
+
+	// The ClusterRole bound to horusctl by RoleBinding in every namespace horusctl
+	// works in, such as the namespace of the Backup object, the backup targets and
+	// the horus-operator.
+	NamespacedRoleForBackup = `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: horusctl-namespaced-role
+rules:
 # permissions for horusctl to view backups,restores,clones,migrations,traffics.
 - apiGroups:
   - storage.hybfkuf.io
@@ -22,7 +71,7 @@ rules:
   - get
   - list
   - watch
-# permissions for horusctl to view status subresources of backups,restores,clones,migrations,traffics.
+# permissions for horusctl to view and update status subresources of backups,restores,clones,migrations,traffics.
 - apiGroups:
   - storage.hybfkuf.io
   - networking.hybfkuf.io
@@ -34,14 +83,21 @@ rules:
   - traffics/status
   verbs:
   - get
-# permissions for horusctl to create/update/delete deployments,
-# and for horusctl-operator-controller-manager to create namespaces.
+  - update
+  - patch
+# permissions for horusctl to create/update/delete deployments, scale statefulsets,
+# copy credential secrets to the executors, create the configmaps owning the executors,
+# create persistentvolumeclaims when clone,
+# swap persistentvolumeclaims when migration and recreate the manifests when restore.
 - apiGroups:
   - ""
   - apps
   resources:
   - deployments
-  - namespaces
+  - statefulsets
+  - secrets
+  - configmaps
+  - persistentvolumeclaims
   verbs:
   - get
   - list
@@ -50,24 +106,35 @@ rules:
   - delete
   - update
   - patch
-# permissions for horusctl to view pods,deployments,statefulsets,daemonsets,replicasets,
-# secrets,persistentvolumes, persistentvolumeclaims.
+# permissions for horusctl to view pods,daemonsets,replicasets and backup and recreate
+# the manifests of the backup target.
 - apiGroups:
   - ""
   - apps
   resources:
   - pods
-  - deployments
-  - statefulsets
   - daemonsets
   - replicasets
-  - secrets
-  - persistentvolumes
-  - persistentvolumeclaims
+  - services
+  - serviceaccounts
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+# permissions for horusctl to run restic within the executor jobs and pods.
+- apiGroups:
+  - ""
+  - batch
+  resources:
+  - pods
+  - jobs
   verbs:
   - get
   - list
   - watch
+  - create
+  - delete
 # permissions for horusctl to execute command within pod.
 - apiGroups:
   - ""
@@ -80,7 +147,7 @@ rules:
 - apiGroups:
   - ""
   resources:
-  - pods/logs
+  - pods/log
   verbs:
   - get
 `
@@ -1,16 +1,20 @@
 package template
 
 var (
+	// CronJobForBackup is the cronjob template that periodically run horusctl
+	// to backup the k8s resource defined in Backup object.
+	// .spec.jobTemplate.spec.activeDeadlineSeconds and the environment variables
+	// defined in Backup.spec.env are set by the Backup controller.
 	CronJobForBackup = `
 apiVersion: batch/v1
 kind: CronJob
 metadata:
   name: backup-{{.ObjectMeta.Name}}
-  namespace: default
+  namespace: {{.ObjectMeta.Namespace}}
 spec:
-  schedule: '*/1 * * * *'
-  successfulJobsHistoryLimit: 1
-  failedJobsHistoryLimit: 1
+  schedule: '{{.Spec.Schedule}}'
+  successfulJobsHistoryLimit: {{.Spec.SuccessfulJobsHistoryLimit}}
+  failedJobsHistoryLimit: {{.Spec.FailedJobsHistoryLimit}}
   concurrencyPolicy: Forbid
   suspend: false
   jobTemplate:
@@ -25,10 +29,19 @@ spec:
             - --log-format={{.Spec.LogFormat}}
             - backup
             - --namespace={{.ObjectMeta.Namespace}}
-            - {{.Spec.BackupFrom.Name}}
+            - {{.ObjectMeta.Name}}
             env:
             - name: TZ
-              value: {{.Spec.TimeZone}}
+              value: '{{.Spec.TimeZone}}'
+            # the executors created by horusctl are owned by the horusctl pod.
+            - name: POD_NAME
+              valueFrom:
+                fieldRef:
+                  fieldPath: metadata.name
+            - name: POD_NAMESPACE
+              valueFrom:
+                fieldRef:
+                  fieldPath: metadata.namespace
             image: hybfkuf/horusctl:latest
             imagePullPolicy: Always
             name: horusctl
@@ -0,0 +1,72 @@
+package template
+
+var (
+	TemplateBackup2pvc = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: "%s"
+  namespace: "%s"
+  labels:
+    app.kubernetes.io/name: backup-to-pvc
+    app.kubernetes.io/part-of: horus
+    app.kubernetes.io/managed-by: horus-operator
+spec:
+  replicas: 1
+  # the ReadWriteOnce persistentvolumeclaim can't be mounted by the old and new pods
+  # on different k8s nodes at the same time.
+  strategy:
+    type: Recreate
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: backup-to-pvc
+      app.kubernetes.io/part-of: horus
+      app.kubernetes.io/managed-by: horus-operator
+  template:
+    metadata:
+      annotations:
+      #  %s: %s
+        sidecar.istio.io/inject: "false"
+      labels:
+        app.kubernetes.io/name: backup-to-pvc
+        app.kubernetes.io/role: backup
+        app.kubernetes.io/backup-method: restic
+        app.kubernetes.io/part-of: horus
+        app.kubernetes.io/managed-by: horus-operator
+    spec:
+      nodeName: "%s"
+      tolerations:
+      - operator: Exists
+      terminationGracePeriodSeconds: 0
+      containers:
+      - name: backup-to-pvc
+        image: "%s"
+        env:
+        - name: TZ
+          value: %s
+        - name: STORAGE
+          value: %s
+        - name: RESTIC_REPOSITORY
+          value: %s
+        - name: RESTIC_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: RESTIC_PASSWORD
+        volumeMounts:
+        - name: host-root
+          mountPath: /host-root
+          readOnly: true
+        - name: restic-repo
+          mountPath: "%s"
+          readOnly: false
+      volumes:
+      - name: host-root
+        hostPath:
+          path: /
+          type: Directory
+      - name: restic-repo
+        persistentVolumeClaim:
+          claimName: "%s"
+`
+)
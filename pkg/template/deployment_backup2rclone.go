@@ -0,0 +1,73 @@
+package template
+
+var (
+	TemplateBackup2rclone = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: "%s"
+  namespace: "%s"
+  labels:
+    app.kubernetes.io/name: backup-to-rclone
+    app.kubernetes.io/part-of: horus
+    app.kubernetes.io/managed-by: horus-operator
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: backup-to-rclone
+      app.kubernetes.io/part-of: horus
+      app.kubernetes.io/managed-by: horus-operator
+  template:
+    metadata:
+      annotations:
+      #  %s: %s
+        sidecar.istio.io/inject: "false"
+      labels:
+        app.kubernetes.io/name: backup-to-rclone
+        app.kubernetes.io/role: backup
+        app.kubernetes.io/backup-method: restic
+        app.kubernetes.io/part-of: horus
+        app.kubernetes.io/managed-by: horus-operator
+    spec:
+      nodeName: "%s"
+      tolerations:
+      - operator: Exists
+      terminationGracePeriodSeconds: 0
+      containers:
+      - name: backup-to-rclone
+        image: "%s"
+        env:
+        - name: TZ
+          value: %s
+        - name: STORAGE
+          value: %s
+        - name: RESTIC_REPOSITORY
+          value: "%s"
+        - name: RESTIC_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: RESTIC_PASSWORD
+        - name: RCLONE_CONFIG
+          value: "%s"
+        volumeMounts:
+        - name: host-root
+          mountPath: /host-root
+          readOnly: true
+        - name: rclone-config
+          mountPath: "%s"
+          readOnly: true
+      volumes:
+      - name: host-root
+        hostPath:
+          path: /
+          type: Directory
+      - name: rclone-config
+        secret:
+          secretName: "%s"
+          items:
+          - key: rclone.conf
+            path: rclone.conf
+`
+)
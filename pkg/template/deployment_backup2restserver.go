@@ -1 +1,78 @@
 package template
+
+var (
+	// restic reads the rest server credential from RESTIC_REST_USERNAME and
+	// RESTIC_REST_PASSWORD, they're not in RESTIC_REPOSITORY.
+	TemplateBackup2restserver = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: "%s"
+  namespace: "%s"
+  labels:
+    app.kubernetes.io/name: backup-to-restserver
+    app.kubernetes.io/part-of: horus
+    app.kubernetes.io/managed-by: horus-operator
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: backup-to-restserver
+      app.kubernetes.io/part-of: horus
+      app.kubernetes.io/managed-by: horus-operator
+  template:
+    metadata:
+      annotations:
+      #  %s: %s
+        %s: "%t"
+        %s: "%t"
+        sidecar.istio.io/inject: "false"
+      labels:
+        app.kubernetes.io/name: backup-to-restserver
+        app.kubernetes.io/role: backup
+        app.kubernetes.io/backup-method: restic
+        app.kubernetes.io/part-of: horus
+        app.kubernetes.io/managed-by: horus-operator
+    spec:
+      nodeName: "%s"
+      tolerations:
+      - operator: Exists
+      terminationGracePeriodSeconds: 0
+      containers:
+      - name: backup-to-restserver
+        image: "%s"
+        env:
+        - name: TZ
+          value: %s
+        - name: STORAGE
+          value: %s
+        - name: RESTIC_REST_USERNAME
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: RESTIC_REST_USERNAME
+              optional: true
+        - name: RESTIC_REST_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: RESTIC_REST_PASSWORD
+              optional: true
+        - name: RESTIC_REPOSITORY
+          value: "%s"
+        - name: RESTIC_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: RESTIC_PASSWORD
+        volumeMounts:
+        - name: host-root
+          mountPath: /host-root
+          readOnly: true
+      volumes:
+      - name: host-root
+        hostPath:
+          path: /
+          type: Directory
+`
+)
@@ -0,0 +1,81 @@
+package template
+
+var (
+	TemplateBackup2s3 = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: "%s"
+  namespace: "%s"
+  labels:
+    app.kubernetes.io/name: backup-to-s3
+    app.kubernetes.io/part-of: horus
+    app.kubernetes.io/managed-by: horus-operator
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: backup-to-s3
+      app.kubernetes.io/part-of: horus
+      app.kubernetes.io/managed-by: horus-operator
+  template:
+    metadata:
+      annotations:
+      #  %s: %s
+        %s: "%t"
+        sidecar.istio.io/inject: "false"
+      labels:
+        app.kubernetes.io/name: backup-to-s3
+        app.kubernetes.io/role: backup
+        app.kubernetes.io/backup-method: restic
+        app.kubernetes.io/part-of: horus
+        app.kubernetes.io/managed-by: horus-operator
+    spec:
+      nodeName: "%s"
+      tolerations:
+      - operator: Exists
+      terminationGracePeriodSeconds: 0
+      containers:
+      - name: backup-to-s3
+        image: "%s"
+        env:
+        - name: TZ
+          value: %s
+        - name: STORAGE
+          value: %s
+        - name: RESTIC_REPOSITORY
+          value: %s
+        - name: RESTIC_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: RESTIC_PASSWORD
+        - name: AWS_ACCESS_KEY_ID
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: AWS_ACCESS_KEY_ID
+        - name: AWS_SECRET_ACCESS_KEY
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: AWS_SECRET_ACCESS_KEY
+        - name: AWS_SESSION_TOKEN
+          valueFrom:
+            secretKeyRef:
+              name: %s
+              key: AWS_SESSION_TOKEN
+              optional: true
+        - name: AWS_DEFAULT_REGION
+          value: "%s"
+        volumeMounts:
+        - name: host-root
+          mountPath: /host-root
+          readOnly: true
+      volumes:
+      - name: host-root
+        hostPath:
+          path: /
+          type: Directory
+`
+)
@@ -0,0 +1,36 @@
+package template
+
+var (
+	// JobForClone is the job template that run horusctl to clone the persistentvolumeclaims
+	// defined in Clone object.
+	// .spec.activeDeadlineSeconds is set by the Clone controller.
+	JobForClone = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: clone-{{.ObjectMeta.Name}}
+  namespace: {{.ObjectMeta.Namespace}}
+spec:
+  backoffLimit: 0
+  template:
+    spec:
+      containers:
+      - command:
+        - horusctl
+        args:
+        - --log-level={{.Spec.LogLevel}}
+        - --log-format={{.Spec.LogFormat}}
+        - clone
+        - --namespace={{.ObjectMeta.Namespace}}
+        - {{.ObjectMeta.Name}}
+        env:
+        - name: TZ
+          value: '{{.Spec.TimeZone}}'
+        image: hybfkuf/horusctl:latest
+        imagePullPolicy: Always
+        name: horusctl
+      restartPolicy: Never
+      serviceAccount: horusctl
+      serviceAccountName: horusctl
+`
+)
@@ -0,0 +1,36 @@
+package template
+
+var (
+	// JobForMigration is the job template that run horusctl to migrate the persistentvolumeclaims
+	// defined in Migration object.
+	// .spec.activeDeadlineSeconds is set by the Migration controller.
+	JobForMigration = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migration-{{.ObjectMeta.Name}}
+  namespace: {{.ObjectMeta.Namespace}}
+spec:
+  backoffLimit: 0
+  template:
+    spec:
+      containers:
+      - command:
+        - horusctl
+        args:
+        - --log-level={{.Spec.LogLevel}}
+        - --log-format={{.Spec.LogFormat}}
+        - migrate
+        - --namespace={{.ObjectMeta.Namespace}}
+        - {{.ObjectMeta.Name}}
+        env:
+        - name: TZ
+          value: '{{.Spec.TimeZone}}'
+        image: hybfkuf/horusctl:latest
+        imagePullPolicy: Always
+        name: horusctl
+      restartPolicy: Never
+      serviceAccount: horusctl
+      serviceAccountName: horusctl
+`
+)
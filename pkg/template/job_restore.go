@@ -0,0 +1,36 @@
+package template
+
+var (
+	// JobForRestore is the job template that run horusctl to restore the k8s
+	// resource defined in Restore object.
+	// .spec.activeDeadlineSeconds is set by the Restore controller.
+	JobForRestore = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: restore-{{.ObjectMeta.Name}}
+  namespace: {{.ObjectMeta.Namespace}}
+spec:
+  backoffLimit: 0
+  template:
+    spec:
+      containers:
+      - command:
+        - horusctl
+        args:
+        - --log-level={{.Spec.LogLevel}}
+        - --log-format={{.Spec.LogFormat}}
+        - restore
+        - --namespace={{.ObjectMeta.Namespace}}
+        - {{.ObjectMeta.Name}}
+        env:
+        - name: TZ
+          value: '{{.Spec.TimeZone}}'
+        image: hybfkuf/horusctl:latest
+        imagePullPolicy: Always
+        name: horusctl
+      restartPolicy: Never
+      serviceAccount: horusctl
+      serviceAccountName: horusctl
+`
+)
@@ -0,0 +1,70 @@
+package template
+
+import (
+	"testing"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestParseCronJobForBackup(t *testing.T) {
+	successfulJobsHistoryLimit, failedJobsHistoryLimit := int32(5), int32(0)
+	backupObj := &storagev1alpha1.Backup{
+		ObjectMeta: metav1.ObjectMeta{Name: "mybackup", Namespace: "test"},
+		Spec: storagev1alpha1.BackupSpec{
+			Schedule:                   "*/10 * * * *",
+			TimeZone:                   "Asia/Shanghai",
+			SuccessfulJobsHistoryLimit: &successfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     &failedJobsHistoryLimit,
+			BackupFrom:                 &storagev1alpha1.BackupFrom{Name: "nginx", Resource: storagev1alpha1.DeploymentResource},
+		},
+	}
+	data, err := Parse(CronJobForBackup, backupObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cronjob := &batchv1.CronJob{}
+	if err := yaml.Unmarshal(data, cronjob); err != nil {
+		t.Fatal(err)
+	}
+	if cronjob.GetName() != "backup-mybackup" || cronjob.GetNamespace() != "test" {
+		t.Fatalf("unexpected cronjob %s/%s", cronjob.GetNamespace(), cronjob.GetName())
+	}
+	if cronjob.Spec.Schedule != "*/10 * * * *" {
+		t.Fatalf("unexpected schedule: %s", cronjob.Spec.Schedule)
+	}
+	if *cronjob.Spec.SuccessfulJobsHistoryLimit != 5 || *cronjob.Spec.FailedJobsHistoryLimit != 0 {
+		t.Fatal("unexpected jobs history limit")
+	}
+	args := cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Args
+	if args[len(args)-1] != "mybackup" {
+		t.Fatalf("horusctl should backup the Backup object, got: %s", args[len(args)-1])
+	}
+}
+
+func TestParseJobForRestore(t *testing.T) {
+	restoreObj := &storagev1alpha1.Restore{
+		ObjectMeta: metav1.ObjectMeta{Name: "myrestore", Namespace: "test"},
+		Spec: storagev1alpha1.RestoreSpec{
+			TimeZone:  "Asia/Shanghai",
+			RestoreTo: &storagev1alpha1.RestoreTo{Name: "nginx", Resource: storagev1alpha1.DeploymentResource},
+		},
+	}
+	data, err := Parse(JobForRestore, restoreObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &batchv1.Job{}
+	if err := yaml.Unmarshal(data, job); err != nil {
+		t.Fatal(err)
+	}
+	if job.GetName() != "restore-myrestore" || job.GetNamespace() != "test" {
+		t.Fatalf("unexpected job %s/%s", job.GetNamespace(), job.GetName())
+	}
+	args := job.Spec.Template.Spec.Containers[0].Args
+	if args[len(args)-1] != "myrestore" || args[len(args)-3] != "restore" {
+		t.Fatalf("horusctl should restore the Restore object, got: %v", args)
+	}
+}
@@ -0,0 +1,20 @@
+package template
+
+var (
+	// RoleBindingForBackup grants horusctl the namespaced permissions, it's created
+	// in every namespace horusctl works in, the namespace is set by the controller.
+	RoleBindingForBackup = `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: horusctl-{{.ObjectMeta.Namespace}}-binding
+subjects:
+- kind: ServiceAccount
+  name: horusctl
+  namespace: {{.ObjectMeta.Namespace}}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: horusctl-namespaced-role
+`
+)
@@ -10,6 +10,7 @@ const (
 	StorageRestServer Storage = "restServer"
 	StorageSFTP       Storage = "sftp"
 	StorageRClone     Storage = "rclone"
+	StoragePVC        Storage = "pvc"
 )
 
 const (
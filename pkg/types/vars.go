@@ -26,11 +26,34 @@ var (
 	DefaultCloneTimeout     = time.Hour
 	DefaultMigrationTimeout = time.Hour
 
+	DefaultBackupSchedule                   = "0 0 * * *"
+	DefaultBackupSuccessfulJobsHistoryLimit = int32(3)
+	DefaultBackupFailedJobsHistoryLimit     = int32(1)
+
 	DefaultServiceAccountName = "horus-jobs"
 
 	AnnotationCreatedTime   = "hybfkuf.io/createdAt"
 	AnnotationUpdatedTime   = "hybfkuf.io/updatedAt"
 	AnnotationRestartedTime = "hybfkuf.io/restartedAt"
+	// AnnotationInsecureTLS is set on the executor pod to run restic command
+	// with --insecure-tls, the TLS certificate of the restic repository will not be verified.
+	AnnotationInsecureTLS = "hybfkuf.io/insecureTLS"
+	// AnnotationAppendOnly is set on the executor pod if the restic repository
+	// is append-only, no snapshots can be removed.
+	AnnotationAppendOnly = "hybfkuf.io/appendOnly"
+
+	// The annotations set on the pod to backup define the hooks executed in the pod
+	// before and after backup, they override Backup.spec.hooks.
+	// The command is a json array, such as '["/bin/sh", "-c", "sync"]', or a string
+	// executed by "/bin/sh -c".
+	AnnotationPreHookContainer  = "pre.hook.backup.hybfkuf.io/container"
+	AnnotationPreHookCommand    = "pre.hook.backup.hybfkuf.io/command"
+	AnnotationPreHookTimeout    = "pre.hook.backup.hybfkuf.io/timeout"
+	AnnotationPreHookOnError    = "pre.hook.backup.hybfkuf.io/on-error"
+	AnnotationPostHookContainer = "post.hook.backup.hybfkuf.io/container"
+	AnnotationPostHookCommand   = "post.hook.backup.hybfkuf.io/command"
+	AnnotationPostHookTimeout   = "post.hook.backup.hybfkuf.io/timeout"
+	AnnotationPostHookOnError   = "post.hook.backup.hybfkuf.io/on-error"
 
 	LabelName          = "app.kubernetes.io/name"
 	LabelInstance      = "app.kubernetes.io/instance"
@@ -39,13 +62,29 @@ var (
 	LabelManagedBy     = "app.kubernetes.io/managed-by"
 	LabelPairPartOf    = fmt.Sprintf("%s=%s", LabelPartOf, "horus")
 	LabelPairManagedBy = fmt.Sprintf("%s=%s", LabelManagedBy, "horus-operator")
+	// LabelHorusctlNamespace is set on the rolebindings granting the horusctl
+	// serviceaccount permissions, the value is the namespace of the serviceaccount.
+	LabelHorusctlNamespace = "hybfkuf.io/horusctl-namespace"
+	// LabelExecutorAnchor is set on the configmaps owning the executors of one
+	// horusctl run, see backup.executorAnchor.
+	LabelExecutorAnchor = "hybfkuf.io/executor-anchor"
 )
 
 var (
-	Backup2NFSDeployName    = "backup-to-nfs"
-	Backup2NFSDeployLabel   = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2NFSDeployName)
-	Backup2MinioDeployName  = "backup-to-minio"
-	Backup2MinioDeployLabel = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2MinioDeployName)
-	Backup2S3DeployName     = "backup-to-s3"
-	Backup2S3DeployLabel    = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2S3DeployName)
+	Backup2NFSDeployName         = "backup-to-nfs"
+	Backup2NFSDeployLabel        = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2NFSDeployName)
+	Backup2MinioDeployName       = "backup-to-minio"
+	Backup2MinioDeployLabel      = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2MinioDeployName)
+	Backup2S3DeployName          = "backup-to-s3"
+	Backup2S3DeployLabel         = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2S3DeployName)
+	Backup2RestServerDeployName  = "backup-to-restserver"
+	Backup2RestServerDeployLabel = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2RestServerDeployName)
+	Backup2CephFSDeployName      = "backup-to-cephfs"
+	Backup2CephFSDeployLabel     = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2CephFSDeployName)
+	Backup2RcloneDeployName      = "backup-to-rclone"
+	Backup2RcloneDeployLabel     = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2RcloneDeployName)
+	Backup2PVCDeployName         = "backup-to-pvc"
+	Backup2PVCDeployLabel        = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2PVCDeployName)
+	Backup2SFTPDeployName        = "backup-to-sftp"
+	Backup2SFTPDeployLabel       = fmt.Sprintf("app.kubernetes.io/name=%s", Backup2SFTPDeployName)
 )
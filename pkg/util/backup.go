@@ -3,9 +3,12 @@ package util
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
 	"github.com/forbearing/horus-operator/pkg/types"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
 )
 
 // GetBackupToStorage find the storage which should data backup to.
@@ -38,3 +41,16 @@ func GetBackupToStorage(backupObj *storagev1alpha1.Backup) types.Storage {
 	}
 	return ""
 }
+
+// NextBackupTime returns the next time the Backup.spec.schedule activated after now.
+// The schedule is a standard cron expression, the same as the CronJob schedule.
+func NextBackupTime(schedule string, now time.Time) (time.Time, error) {
+	if len(schedule) == 0 {
+		schedule = types.DefaultBackupSchedule
+	}
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parse schedule %q failed", schedule)
+	}
+	return sched.Next(now), nil
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextBackupTime(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"", time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2022, 10, 1, 12, 35, 0, 0, time.UTC)},
+		{"0 3 * * 0", time.Date(2022, 10, 2, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := NextBackupTime(test.schedule, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("schedule %q: expected %s, got %s", test.schedule, test.want, got)
+		}
+	}
+	if _, err := NextBackupTime("invalid", now); err == nil {
+		t.Error("expected error for invalid schedule")
+	}
+}
@@ -18,7 +18,11 @@ const (
 	EnvOperatorNamespace = "OPERATOR_NAMESPACE"
 	EnvWatchNamespace    = "WATCH_NAMESPACE"
 	EnvCurrentNamespace  = "NAMESPACE"
-	namespaceFile        = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// EnvPodName and EnvPodNamespace are the name and namespace of the horusctl pod,
+	// they are passed to horusctl by the downward API.
+	EnvPodName      = "POD_NAME"
+	EnvPodNamespace = "POD_NAMESPACE"
+	namespaceFile   = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
 
 // GetCurrentNamespace
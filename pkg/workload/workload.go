@@ -0,0 +1,176 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagev1alpha1 "github.com/forbearing/horus-operator/apis/storage/v1alpha1"
+	"github.com/forbearing/k8s/deployment"
+	"github.com/forbearing/k8s/persistentvolumeclaim"
+	"github.com/forbearing/k8s/statefulset"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// scaleDownTimeout is the max time to wait for all pods of the deployment/statefulset terminated.
+	scaleDownTimeout = 10 * time.Minute
+)
+
+var (
+	ctx        = context.TODO()
+	depHandler = deployment.NewOrDie(ctx, "", "")
+	stsHandler = statefulset.NewOrDie(ctx, "", "")
+	pvcHandler = persistentvolumeclaim.NewOrDie(ctx, "", "")
+)
+
+// Workload is the deployment/statefulset whose persistentvolumeclaims will be
+// restored, cloned or migrated.
+//
+// Replicas is the original replicas of the deployment/statefulset, it will be
+// scaled down to zero before writing its persistentvolumeclaims, and scaled up
+// to Replicas after that.
+// PVCs is all persistentvolumeclaims mounted by the deployment/statefulset.
+// ClaimTemplates maps the persistentvolumeclaim created from statefulset
+// volumeClaimTemplates to its template name and ordinal.
+type Workload struct {
+	Namespace      string
+	Name           string
+	Resource       storagev1alpha1.Resource
+	Replicas       int32
+	PVCs           []string
+	ClaimTemplates map[string]ClaimTemplate
+
+	Deployment  *appsv1.Deployment
+	StatefulSet *appsv1.StatefulSet
+}
+
+// ClaimTemplate indicates the persistentvolumeclaim is created from the statefulset
+// volumeClaimTemplate named Name for the pod with ordinal Ordinal.
+type ClaimTemplate struct {
+	Name    string
+	Ordinal int32
+}
+
+// Get find out the deployment/statefulset and all persistentvolumeclaims mounted by it.
+// Only deployment and statefulset are supported.
+func Get(namespace string, resource storagev1alpha1.Resource, name string) (*Workload, error) {
+	w := &Workload{
+		Namespace:      namespace,
+		Name:           name,
+		Resource:       resource,
+		Replicas:       1,
+		ClaimTemplates: make(map[string]ClaimTemplate),
+	}
+
+	switch resource {
+	case storagev1alpha1.DeploymentResource:
+		deployObj, err := depHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "deployment handler get deployment/%s failed", name)
+		}
+		if deployObj.Spec.Replicas != nil {
+			w.Replicas = *deployObj.Spec.Replicas
+		}
+		w.PVCs = PodTemplatePVC(deployObj.Spec.Template)
+		w.Deployment = deployObj
+	case storagev1alpha1.StatefulSetResource:
+		stsObj, err := stsHandler.WithNamespace(namespace).Get(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "statefulset handler get statefulset/%s failed", name)
+		}
+		if stsObj.Spec.Replicas != nil {
+			w.Replicas = *stsObj.Spec.Replicas
+		}
+		w.PVCs = PodTemplatePVC(stsObj.Spec.Template)
+		// The persistentvolumeclaims created from volumeClaimTemplates are named
+		// "<volumeClaimTemplate name>-<statefulset name>-<ordinal>".
+		for _, tpl := range stsObj.Spec.VolumeClaimTemplates {
+			for i := int32(0); i < w.Replicas; i++ {
+				pvc := ClaimTemplatePVC(tpl.GetName(), stsObj.GetName(), i)
+				if _, err := pvcHandler.WithNamespace(namespace).Get(pvc); err != nil {
+					if apierrors.IsNotFound(err) {
+						logrus.Warnf("pvc/%s not found, skip it", pvc)
+						continue
+					}
+					return nil, errors.Wrapf(err, "persistentvolumeclaim handler get pvc/%s failed", pvc)
+				}
+				w.PVCs = append(w.PVCs, pvc)
+				w.ClaimTemplates[pvc] = ClaimTemplate{Name: tpl.GetName(), Ordinal: i}
+			}
+		}
+		w.StatefulSet = stsObj
+	default:
+		return nil, fmt.Errorf("not support resource: %s, only deployment and statefulset are supported", resource)
+	}
+
+	return w, nil
+}
+
+// ScaleDown scale the deployment/statefulset replicas to zero and wait for all pods terminated,
+// so that no application writes to the persistentvolumeclaims.
+func (w *Workload) ScaleDown() error {
+	switch w.Resource {
+	case storagev1alpha1.DeploymentResource:
+		handler := depHandler.WithNamespace(w.Namespace)
+		if _, err := handler.Scale(w.Name, 0); err != nil {
+			return errors.Wrapf(err, "deployment handler scale deployment/%s failed", w.Name)
+		}
+		return wait.PollImmediate(2*time.Second, scaleDownTimeout, func() (bool, error) {
+			podObjs, err := handler.GetPods(w.Name)
+			if err != nil {
+				return false, err
+			}
+			return len(podObjs) == 0, nil
+		})
+	case storagev1alpha1.StatefulSetResource:
+		handler := stsHandler.WithNamespace(w.Namespace)
+		if _, err := handler.Scale(w.Name, 0); err != nil {
+			return errors.Wrapf(err, "statefulset handler scale statefulset/%s failed", w.Name)
+		}
+		return wait.PollImmediate(2*time.Second, scaleDownTimeout, func() (bool, error) {
+			podObjs, err := handler.GetPods(w.Name)
+			if err != nil {
+				return false, err
+			}
+			return len(podObjs) == 0, nil
+		})
+	}
+	return nil
+}
+
+// ScaleUp scale the deployment/statefulset to the original replicas.
+func (w *Workload) ScaleUp() error {
+	switch w.Resource {
+	case storagev1alpha1.DeploymentResource:
+		if _, err := depHandler.WithNamespace(w.Namespace).Scale(w.Name, w.Replicas); err != nil {
+			return errors.Wrapf(err, "deployment handler scale deployment/%s failed", w.Name)
+		}
+	case storagev1alpha1.StatefulSetResource:
+		if _, err := stsHandler.WithNamespace(w.Namespace).Scale(w.Name, w.Replicas); err != nil {
+			return errors.Wrapf(err, "statefulset handler scale statefulset/%s failed", w.Name)
+		}
+	}
+	return nil
+}
+
+// PodTemplatePVC returns all persistentvolumeclaims defined in pod template volumes.
+func PodTemplatePVC(template corev1.PodTemplateSpec) []string {
+	var pvcs []string
+	for _, volume := range template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			pvcs = append(pvcs, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return pvcs
+}
+
+// ClaimTemplatePVC returns the name of persistentvolumeclaim created from statefulset volumeClaimTemplate.
+func ClaimTemplatePVC(template, statefulset string, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", template, statefulset, ordinal)
+}